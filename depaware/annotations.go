@@ -0,0 +1,105 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// annotation describes the new dependencies an import statement
+// introduces, for editors to show next to it.
+type annotation struct {
+	Import     string   `json:"import"`
+	Message    string   `json:"message"`
+	NewDeps    []string `json:"newDeps"`    // dependencies not in the existing file
+	Transitive int      `json:"transitive"` // packages transitively imported, including Import
+}
+
+// Annotations returns annotations for the import statements in the
+// main module's packages whose imports introduce dependencies that
+// aren't in oldDeps, keyed by "file:line" of the import statement.
+func (d *deps) Annotations(oldDeps map[string]bool) (map[string]annotation, error) {
+	isDep := make(map[string]bool, len(d.Deps))
+	for _, dep := range d.Deps {
+		isDep[dep] = true
+	}
+	anns := make(map[string]annotation)
+	fset := token.NewFileSet()
+	for pkg, files := range d.GoFiles {
+		if d.MainModule == "" || !inModule(pkg, d.MainModule) {
+			continue
+		}
+		for _, file := range files {
+			f, err := parser.ParseFile(fset, file, nil, parser.ImportsOnly)
+			if err != nil {
+				return nil, err
+			}
+			for _, spec := range f.Imports {
+				imp, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					continue
+				}
+				newDeps := d.newDepsVia(imp, isDep, oldDeps)
+				if len(newDeps) == 0 {
+					continue
+				}
+				n := d.TransitiveCount(imp)
+				var msg string
+				if len(newDeps) == 1 {
+					msg = fmt.Sprintf("introduces new dependency %s (%d transitive packages)", newDeps[0], n)
+				} else {
+					msg = fmt.Sprintf("introduces %d new dependencies: %s (%d transitive packages)", len(newDeps), strings.Join(newDeps, ", "), n)
+				}
+				pos := fset.Position(spec.Pos())
+				anns[fmt.Sprintf("%s:%d", pos.Filename, pos.Line)] = annotation{
+					Import:     imp,
+					Message:    msg,
+					NewDeps:    newDeps,
+					Transitive: n,
+				}
+			}
+		}
+	}
+	return anns, nil
+}
+
+// newDepsVia returns the sorted packages reachable from imp (including
+// imp itself) that are dependencies listed in isDep but not in oldDeps.
+func (d *deps) newDepsVia(imp string, isDep, oldDeps map[string]bool) []string {
+	var newDeps []string
+	seen := map[string]bool{imp: true}
+	queue := []string{imp}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if isDep[p] && !oldDeps[p] {
+			newDeps = append(newDeps, p)
+		}
+		for _, next := range d.Imports[p] {
+			if !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	sort.Strings(newDeps)
+	return newDeps
+}
+
+// writeAnnotations writes anns as JSON to the named file.
+func writeAnnotations(name string, anns map[string]annotation) error {
+	b, err := json.MarshalIndent(anns, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, append(b, '\n'), 0644)
+}
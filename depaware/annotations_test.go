@@ -0,0 +1,48 @@
+package depaware
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAnnotations(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "main.go")
+	src := `package main
+
+import (
+	"fmt"
+
+	"github.com/new/dep"
+)
+`
+	if err := ioutil.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d := &deps{MainModule: "example.com/m"}
+	d.AddEdge("example.com/m/cmd", "fmt")
+	d.AddEdge("example.com/m/cmd", "github.com/new/dep")
+	d.AddEdge("github.com/new/dep", "github.com/new/dep/sub")
+	d.AddEdge("github.com/new/dep", "fmt")
+	for _, pkg := range []string{"fmt", "github.com/new/dep", "github.com/new/dep/sub"} {
+		d.AddDep(pkg, "linux")
+	}
+	d.AddGoFiles("example.com/m/cmd", []string{file})
+
+	got, err := d.Annotations(map[string]bool{"fmt": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]annotation{
+		file + ":6": {
+			Import:     "github.com/new/dep",
+			Message:    "introduces 2 new dependencies: github.com/new/dep, github.com/new/dep/sub (3 transitive packages)",
+			NewDeps:    []string{"github.com/new/dep", "github.com/new/dep/sub"},
+			Transitive: 3,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+}
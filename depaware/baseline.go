@@ -0,0 +1,107 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultBaselineFile is the baseline file written by
+// "depaware policy baseline" when -baseline isn't set.
+const defaultBaselineFile = "depaware.baseline"
+
+// A baseline is a set of accepted ("grandfathered") policy violations.
+// Violations in the baseline are ignored by -check, so that strict rules
+// can be introduced in code that doesn't follow them yet and only new
+// violations fail.
+//
+// A baseline file has one violation per line, as three tab-separated
+// fields: the package whose dependencies violate the policy, the rule,
+// and the offending dependency (which is empty for rules about all
+// dependencies, like max-orgs). Blank lines and lines starting with
+// "#" are ignored.
+type baseline map[baselineKey]bool
+
+type baselineKey struct {
+	Pkg  string // package being analyzed
+	Rule string // policyRule.Text
+	Dep  string // violation.Package
+}
+
+// readBaseline reads the named baseline file.
+func readBaseline(name string) (baseline, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b, err := parseBaseline(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return b, nil
+}
+
+func parseBaseline(r io.Reader) (baseline, error) {
+	b := baseline{}
+	scan := bufio.NewScanner(r)
+	lineNum := 0
+	for scan.Scan() {
+		lineNum++
+		line := scan.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Split(line, "\t")
+		if len(f) != 3 {
+			return nil, fmt.Errorf("line %d: want 3 tab-separated fields, got %d", lineNum, len(f))
+		}
+		b[baselineKey{f[0], f[1], f[2]}] = true
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Add adds pkg's violations vs to b.
+func (b baseline) Add(pkg string, vs []violation) {
+	for _, v := range vs {
+		b[baselineKey{pkg, v.Rule, v.Package}] = true
+	}
+}
+
+// Filter returns the violations in vs by pkg that aren't in b.
+func (b baseline) Filter(pkg string, vs []violation) []violation {
+	var out []violation
+	for _, v := range vs {
+		if !b[baselineKey{pkg, v.Rule, v.Package}] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// write writes b to w in the baseline file format, sorted.
+func (b baseline) write(w io.Writer) error {
+	lines := make([]string, 0, len(b))
+	for k := range b {
+		lines = append(lines, k.Pkg+"\t"+k.Rule+"\t"+k.Dep)
+	}
+	sort.Strings(lines)
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# depaware policy baseline: accepted violations, one per line as\n")
+	bw.WriteString("# package, rule and dependency separated by tabs.\n")
+	for _, line := range lines {
+		bw.WriteString(line)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,37 @@
+package depaware
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestBaselineRoundTrip(t *testing.T) {
+	vs := []violation{
+		{Rule: "deny github.com/evil/...", Package: "github.com/evil"},
+		{Rule: "max-orgs 3"},
+	}
+	b := baseline{}
+	b.Add("example.com/cmd", vs)
+
+	var buf bytes.Buffer
+	if err := b.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseBaseline(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, b) {
+		t.Errorf("round trip: got %v; want %v", got, b)
+	}
+
+	newViolation := violation{Rule: "deny github.com/evil/...", Package: "github.com/evil/sub"}
+	filtered := got.Filter("example.com/cmd", append(vs, newViolation))
+	if want := []violation{newViolation}; !reflect.DeepEqual(filtered, want) {
+		t.Errorf("Filter = %v; want %v", filtered, want)
+	}
+	if filtered := got.Filter("example.com/other", vs); len(filtered) != len(vs) {
+		t.Errorf("Filter for another package = %v; want all violations", filtered)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// blankImport is an import for side effects only, such as
+// import _ "github.com/lib/pq", in the main module.
+type blankImport struct {
+	Importer string `json:"importer"`
+	Import   string `json:"import"`
+	Pos      string `json:"pos"` // "file:line", relative to the main module's root
+
+	// Transitive is the number of packages Import transitively
+	// imports, including itself, and Exclusive the number of
+	// dependencies that nothing but this import pulls in, which is
+	// what removing it would save.
+	Transitive int `json:"transitive"`
+	Exclusive  int `json:"exclusive"`
+}
+
+func (b blankImport) String() string {
+	return fmt.Sprintf("blank import of %s at %s pulls in %d dependencies that nothing else imports (%d packages transitively)",
+		b.Import, b.Pos, b.Exclusive, b.Transitive)
+}
+
+// BlankImports returns the blank imports in the main module's packages
+// that root depends on, most expensive first. A package that's also
+// imported by name elsewhere in the importer has no exclusive cost.
+func (d *deps) BlankImports(root string) ([]blankImport, error) {
+	var blanks []blankImport
+	fset := token.NewFileSet()
+	for pkg, files := range d.GoFiles {
+		if d.MainModule == "" || !inModule(pkg, d.MainModule) {
+			continue
+		}
+		named := make(map[string]bool)
+		byImport := make(map[string]blankImport)
+		for _, file := range files {
+			f, err := parser.ParseFile(fset, file, nil, parser.ImportsOnly)
+			if err != nil {
+				return nil, err
+			}
+			for _, spec := range f.Imports {
+				imp, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					continue
+				}
+				if spec.Name == nil || spec.Name.Name != "_" {
+					named[imp] = true
+					continue
+				}
+				if _, ok := byImport[imp]; ok {
+					continue
+				}
+				pos := fset.Position(spec.Pos())
+				name := pos.Filename
+				if rel, err := filepath.Rel(d.MainModuleDir, name); err == nil && d.MainModuleDir != "" {
+					name = filepath.ToSlash(rel)
+				}
+				byImport[imp] = blankImport{
+					Importer:   pkg,
+					Import:     imp,
+					Pos:        fmt.Sprintf("%s:%d", name, pos.Line),
+					Transitive: d.TransitiveCount(imp),
+				}
+			}
+		}
+		for imp, b := range byImport {
+			if !named[imp] {
+				b.Exclusive = d.exclusiveDeps(root, pkg, imp)
+			}
+			blanks = append(blanks, b)
+		}
+	}
+	sort.Slice(blanks, func(i, j int) bool {
+		if blanks[i].Exclusive != blanks[j].Exclusive {
+			return blanks[i].Exclusive > blanks[j].Exclusive
+		}
+		return blanks[i].Pos < blanks[j].Pos
+	})
+	return blanks, nil
+}
+
+// exclusiveDeps returns the number of dependencies in d.Deps that root
+// and d.OtherRoots only reach through from's import of to.
+func (d *deps) exclusiveDeps(root, from, to string) int {
+	seen := map[string]bool{root: true}
+	queue := []string{root}
+	for _, other := range d.OtherRoots {
+		seen[other] = true
+		queue = append(queue, other)
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, imp := range d.Imports[p] {
+			if !seen[imp] && !(p == from && imp == to) {
+				seen[imp] = true
+				queue = append(queue, imp)
+			}
+		}
+	}
+	n := 0
+	for _, dep := range d.Deps {
+		if !seen[dep] {
+			n++
+		}
+	}
+	return n
+}
+
+// costlyBlankImports returns the blank imports in blanks that pull in at
+// least min dependencies exclusively.
+func costlyBlankImports(blanks []blankImport, min int) []blankImport {
+	var out []blankImport
+	for _, b := range blanks {
+		if b.Exclusive >= min {
+			out = append(out, b)
+		}
+	}
+	return out
+}
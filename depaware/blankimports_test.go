@@ -0,0 +1,69 @@
+package depaware
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBlankImports(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go": "package main\n\nimport (\n\t\"fmt\"\n\n\t_ \"github.com/lib/pq\"\n\t_ \"image/png\"\n)\n",
+		"img.go":  "package main\n\nimport \"image/png\"\n",
+	}
+	var names []string
+	for name, src := range files {
+		names = append(names, filepath.Join(dir, name))
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := &deps{MainModule: "example.com/m", MainModuleDir: dir}
+	d.AddEdge("example.com/m", "fmt")
+	d.AddEdge("example.com/m", "github.com/lib/pq")
+	d.AddEdge("example.com/m", "image/png")
+	d.AddEdge("github.com/lib/pq", "github.com/lib/pq/oid")
+	d.AddEdge("github.com/lib/pq", "fmt")
+	d.AddEdge("image/png", "image")
+	for _, pkg := range []string{"fmt", "github.com/lib/pq", "github.com/lib/pq/oid", "image/png", "image"} {
+		d.AddDep(pkg, "linux")
+	}
+	d.AddGoFiles("example.com/m", names)
+
+	got, err := d.BlankImports("example.com/m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []blankImport{
+		{Importer: "example.com/m", Import: "github.com/lib/pq", Pos: "main.go:6", Transitive: 3, Exclusive: 2},
+		{Importer: "example.com/m", Import: "image/png", Pos: "main.go:7", Transitive: 2, Exclusive: 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+	if got := costlyBlankImports(got, 2); len(got) != 1 || got[0].Import != "github.com/lib/pq" {
+		t.Errorf("costlyBlankImports = %+v; want github.com/lib/pq only", got)
+	}
+}
+
+func TestPolicyBlankImports(t *testing.T) {
+	p, err := parsePolicy(strings.NewReader("declare-blank-imports 2\nallow-blank-import image/...\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.needsBlankImports() {
+		t.Error("needsBlankImports = false")
+	}
+	in := &policyInput{Pkg: "example.com/m", BlankImports: []blankImport{
+		{Import: "github.com/lib/pq", Pos: "main.go:6", Transitive: 3, Exclusive: 2},
+		{Import: "image/png", Pos: "main.go:7", Transitive: 2, Exclusive: 2},
+		{Import: "embed", Pos: "main.go:8", Transitive: 1, Exclusive: 1},
+	}}
+	vs := p.Evaluate(in)
+	if len(vs) != 1 || vs[0].Package != "github.com/lib/pq" || !strings.Contains(vs[0].Message, "isn't declared") {
+		t.Errorf("got %v; want a violation for github.com/lib/pq only", vs)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// buildAction is the subset of an action in the JSON written by
+// "go build -debug-actiongraph" that depaware uses.
+type buildAction struct {
+	Mode      string
+	Package   string
+	TimeStart time.Time
+	TimeDone  time.Time
+	CmdReal   time.Duration // wall time of the compiler; not set by old Go versions
+}
+
+// compileTimes builds pkg for goos from scratch and returns the time it
+// took to compile each package, from the go command's action graph.
+func (r *runner) compileTimes(pkg, goos string) (map[string]time.Duration, error) {
+	dir, err := ioutil.TempDir("", "depaware-buildtime")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	graph := filepath.Join(dir, "actiongraph.json")
+	// -a, so that nothing comes from the build cache and every
+	// dependency gets compiled and timed.
+	args := []string{"build", "-a", "-o", filepath.Join(dir, "bin"), "-debug-actiongraph=" + graph}
+	if r.Tags != "" {
+		args = append(args, "-tags", r.Tags)
+	}
+	build := exec.Command("go", append(args, pkg)...)
+	build.Env = goEnv(goos)
+	if out, err := build.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("go build for GOOS=%s: %v\n%s", goos, err, out)
+	}
+	f, err := os.Open(graph)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseActionGraph(f)
+}
+
+// parseActionGraph parses the JSON written by "go build
+// -debug-actiongraph" and returns the compile time of each package.
+func parseActionGraph(r io.Reader) (map[string]time.Duration, error) {
+	var actions []buildAction
+	if err := json.NewDecoder(r).Decode(&actions); err != nil {
+		return nil, fmt.Errorf("parsing action graph: %v", err)
+	}
+	times := make(map[string]time.Duration)
+	for _, a := range actions {
+		if a.Mode != "build" || a.Package == "" {
+			continue
+		}
+		dur := a.CmdReal
+		if dur == 0 && !a.TimeStart.IsZero() {
+			dur = a.TimeDone.Sub(a.TimeStart)
+		}
+		times[a.Package] += dur
+	}
+	return times, nil
+}
+
+// moduleTime is the compile time of the packages of a module.
+type moduleTime struct {
+	Module   string
+	Packages int
+	Dur      time.Duration
+}
+
+// ModuleCompileTimes attributes times, as returned by compileTimes, to
+// the modules of d's packages, sorted by decreasing time. Packages that
+// aren't in a module are attributed to stdModule, and packages that d
+// doesn't know about are ignored.
+func (d *deps) ModuleCompileTimes(pkg string, times map[string]time.Duration) []moduleTime {
+	known := d.allPackages(pkg)
+	byMod := make(map[string]*moduleTime)
+	for p, dur := range times {
+		if !known[p] {
+			continue
+		}
+		mod := stdModule
+		if m, ok := d.Module[p]; ok {
+			mod = m.Path
+		}
+		mt, ok := byMod[mod]
+		if !ok {
+			mt = &moduleTime{Module: mod}
+			byMod[mod] = mt
+		}
+		mt.Packages++
+		mt.Dur += dur
+	}
+	mods := make([]moduleTime, 0, len(byMod))
+	for _, mt := range byMod {
+		mods = append(mods, *mt)
+	}
+	sort.Slice(mods, func(i, j int) bool {
+		if mods[i].Dur != mods[j].Dur {
+			return mods[i].Dur > mods[j].Dur
+		}
+		return mods[i].Module < mods[j].Module
+	})
+	return mods
+}
+
+// writeCompileTimes writes the -format=buildtime report of pkg, built
+// for goos, to w.
+func writeCompileTimes(w io.Writer, pkg, goos string, mods []moduleTime) {
+	fmt.Fprintf(w, "%s compile time by module for GOOS=%s:\n\n", pkg, goos)
+	var total time.Duration
+	for _, mt := range mods {
+		fmt.Fprintf(w, " %8v %4d pkgs %s\n", mt.Dur.Round(time.Millisecond), mt.Packages, mt.Module)
+		total += mt.Dur
+	}
+	fmt.Fprintf(w, "\n%v total compile time, summed over packages; the go command compiles packages in parallel, so builds take less wall time\n", total.Round(time.Millisecond))
+}
@@ -0,0 +1,70 @@
+package depaware
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/mod/module"
+)
+
+func TestParseActionGraph(t *testing.T) {
+	const graph = `[
+	{"ID": 0, "Mode": "link", "Package": "example.com/cmd", "CmdReal": 900000000},
+	{"ID": 1, "Mode": "build", "Package": "example.com/cmd", "CmdReal": 100000000},
+	{"ID": 2, "Mode": "build check cache", "Package": "fmt"},
+	{"ID": 3, "Mode": "build", "Package": "fmt",
+	 "TimeStart": "2020-01-01T00:00:00Z", "TimeDone": "2020-01-01T00:00:00.25Z"},
+	{"ID": 4, "Mode": "build", "Package": "github.com/foo/bar", "CmdReal": 50000000}
+]`
+	got, err := parseActionGraph(strings.NewReader(graph))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]time.Duration{
+		"example.com/cmd":    100 * time.Millisecond,
+		"fmt":                250 * time.Millisecond,
+		"github.com/foo/bar": 50 * time.Millisecond,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestModuleCompileTimes(t *testing.T) {
+	d := &deps{
+		Imports: map[string][]string{
+			"example.com/cmd":        {"github.com/foo/bar", "fmt"},
+			"github.com/foo/bar":     {"github.com/foo/bar/sub"},
+			"github.com/foo/bar/sub": {"fmt"},
+		},
+		Module: map[string]module.Version{
+			"example.com/cmd":        {Path: "example.com/cmd"},
+			"github.com/foo/bar":     {Path: "github.com/foo/bar", Version: "v1.0.0"},
+			"github.com/foo/bar/sub": {Path: "github.com/foo/bar", Version: "v1.0.0"},
+		},
+	}
+	times := map[string]time.Duration{
+		"example.com/cmd":        10 * time.Millisecond,
+		"github.com/foo/bar":     200 * time.Millisecond,
+		"github.com/foo/bar/sub": 100 * time.Millisecond,
+		"fmt":                    250 * time.Millisecond,
+		"example.com/unrelated":  time.Second,
+	}
+	got := d.ModuleCompileTimes("example.com/cmd", times)
+	want := []moduleTime{
+		{"github.com/foo/bar", 2, 300 * time.Millisecond},
+		{"std", 1, 250 * time.Millisecond},
+		{"example.com/cmd", 1, 10 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v; want %+v", got, want)
+	}
+	var buf bytes.Buffer
+	writeCompileTimes(&buf, "example.com/cmd", "linux", got)
+	if !strings.Contains(buf.String(), "    300ms    2 pkgs github.com/foo/bar\n") {
+		t.Errorf("unexpected report:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// runChangelog implements "depaware changelog", which prints a
+// human-readable list of the dependency changes between two versions of
+// a depaware.txt file, suitable for release notes.
+//
+// Usage:
+//
+//	depaware changelog old.txt new.txt
+//	depaware changelog -git=v1.0..v1.1 [depaware.txt]
+func (r *runner) runChangelog(args []string) error {
+	fs := flag.NewFlagSet("changelog", flag.ContinueOnError)
+	fs.SetOutput(r.stderr)
+	gitRange := fs.String("git", "", "git revision range OLD..NEW to compare the file between; NEW defaults to the working tree")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var oldData, newData []byte
+	var err error
+	if *gitRange != "" {
+		name := r.File
+		switch fs.NArg() {
+		case 0:
+		case 1:
+			name = fs.Arg(0)
+		default:
+			return errors.New("usage: depaware changelog -git=OLD..NEW [file]")
+		}
+		oldRev, newRev := *gitRange, ""
+		if i := strings.Index(*gitRange, ".."); i >= 0 {
+			oldRev, newRev = (*gitRange)[:i], (*gitRange)[i+2:]
+		}
+		if oldData, err = gitShow(oldRev, name); err != nil {
+			return err
+		}
+		if newRev == "" {
+			newData, err = ioutil.ReadFile(name)
+		} else {
+			newData, err = gitShow(newRev, name)
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		if fs.NArg() != 2 {
+			return errors.New("usage: depaware changelog old.txt new.txt")
+		}
+		if oldData, err = ioutil.ReadFile(fs.Arg(0)); err != nil {
+			return err
+		}
+		if newData, err = ioutil.ReadFile(fs.Arg(1)); err != nil {
+			return err
+		}
+	}
+
+	oldFile, err := parseDepsFile(bytes.NewReader(oldData), r.MaxLineBytes)
+	if err != nil {
+		return fmt.Errorf("old file: %v", err)
+	}
+	newFile, err := parseDepsFile(bytes.NewReader(newData), r.MaxLineBytes)
+	if err != nil {
+		return fmt.Errorf("new file: %v", err)
+	}
+	writeChangelog(r.stdout, oldFile, newFile)
+	return nil
+}
+
+// gitShow returns the contents of the named file at git revision rev.
+// The name is relative to the current directory.
+func gitShow(rev, name string) ([]byte, error) {
+	out, err := exec.Command("git", "show", rev+":./"+name).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("git show %s:%s: %s", rev, name, bytes.TrimSpace(ee.Stderr))
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// writeChangelog writes a bullet list of the differences between the
+// old and new files to w. It writes nothing if the dependencies
+// are unchanged.
+func writeChangelog(w io.Writer, oldFile, newFile *depsFile) {
+	oldEntries := make(map[string]fileEntry)
+	for _, e := range oldFile.Entries {
+		oldEntries[e.Pkg] = e
+	}
+	newEntries := make(map[string]fileEntry)
+	for _, e := range newFile.Entries {
+		newEntries[e.Pkg] = e
+	}
+
+	var added, removed, changed []string
+	for _, e := range newFile.Entries {
+		old, ok := oldEntries[e.Pkg]
+		if !ok {
+			added = append(added, fmt.Sprintf("* Added %s%s", e.Pkg, entryDetails(e)))
+			continue
+		}
+		if d := entryChanges(old, e); d != "" {
+			changed = append(changed, fmt.Sprintf("* Changed %s: %s", e.Pkg, d))
+		}
+	}
+	for _, e := range oldFile.Entries {
+		if _, ok := newEntries[e.Pkg]; !ok {
+			removed = append(removed, fmt.Sprintf("* Removed %s", e.Pkg))
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	for _, lines := range [][]string{added, removed, changed} {
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+// entryDetails returns a parenthesized description of the entry's
+// platforms and flags, or the empty string if there's nothing notable.
+func entryDetails(e fileEntry) string {
+	var details []string
+	if e.OS != "" {
+		details = append(details, "only "+e.OS)
+	}
+	if e.Unsafe {
+		details = append(details, "unsafe")
+	}
+	if e.CGO {
+		details = append(details, "cgo")
+	}
+	if e.Why != "" {
+		details = append(details, "from "+e.Why)
+	}
+	if e.Version != "" {
+		details = append(details, e.Version)
+	}
+	if e.Count > 0 {
+		details = append(details, fmt.Sprintf("%d pkgs", e.Count))
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(details, ", ") + ")"
+}
+
+// entryChanges describes how the platforms, flags and, for modules,
+// version and package count of a dependency changed between old and
+// new. It returns the empty string if they didn't. Changes to the
+// "from" column alone aren't reported.
+func entryChanges(old, cur fileEntry) string {
+	var changes []string
+	if old.Version != cur.Version {
+		changes = append(changes, fmt.Sprintf("%s -> %s", versionOrNone(old.Version), versionOrNone(cur.Version)))
+	}
+	if old.Count != cur.Count {
+		changes = append(changes, fmt.Sprintf("%d -> %d pkgs", old.Count, cur.Count))
+	}
+	if old.OS != cur.OS {
+		changes = append(changes, fmt.Sprintf("platforms %s -> %s", osOrAll(old.OS), osOrAll(cur.OS)))
+	}
+	if old.Unsafe != cur.Unsafe {
+		if cur.Unsafe {
+			changes = append(changes, "now uses unsafe")
+		} else {
+			changes = append(changes, "no longer uses unsafe")
+		}
+	}
+	if old.CGO != cur.CGO {
+		if cur.CGO {
+			changes = append(changes, "now uses cgo")
+		} else {
+			changes = append(changes, "no longer uses cgo")
+		}
+	}
+	return strings.Join(changes, "; ")
+}
+
+func osOrAll(letters string) string {
+	if letters == "" {
+		return "all"
+	}
+	return letters
+}
+
+func versionOrNone(v string) string {
+	if v == "" {
+		return "(none)"
+	}
+	return v
+}
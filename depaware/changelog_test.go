@@ -0,0 +1,55 @@
+package depaware
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteChangelog(t *testing.T) {
+	oldFile := &depsFile{Entries: []fileEntry{
+		{Pkg: "github.com/a/kept", Why: "x"},
+		{Pkg: "github.com/a/gone", Why: "x"},
+		{Pkg: "github.com/a/flags", OS: "L", Why: "x"},
+		{Pkg: "github.com/a/why", Why: "x"},
+	}}
+	newFile := &depsFile{Entries: []fileEntry{
+		{Pkg: "github.com/a/kept", Why: "x"},
+		{Pkg: "github.com/a/flags", Unsafe: true, Why: "x"},
+		{Pkg: "github.com/a/why", Why: "y", More: true},
+		{Pkg: "github.com/a/new", OS: "W", CGO: true, Why: "github.com/a/kept"},
+	}}
+	var buf bytes.Buffer
+	writeChangelog(&buf, oldFile, newFile)
+	want := strings.Join([]string{
+		"* Added github.com/a/new (only W, cgo, from github.com/a/kept)",
+		"* Removed github.com/a/gone",
+		"* Changed github.com/a/flags: platforms L -> all; now uses unsafe",
+		"",
+	}, "\n")
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteChangelogModules(t *testing.T) {
+	oldFile := &depsFile{Entries: []fileEntry{
+		{Pkg: "github.com/foo/bar", Version: "v1.0.0", Count: 17},
+		{Pkg: "std", Count: 40},
+	}}
+	newFile := &depsFile{Entries: []fileEntry{
+		{Pkg: "github.com/foo/bar", Version: "v1.1.0", Count: 23},
+		{Pkg: "github.com/foo/baz", Version: "v0.2.0", Count: 2},
+		{Pkg: "std", Count: 40},
+	}}
+	var buf bytes.Buffer
+	writeChangelog(&buf, oldFile, newFile)
+	want := strings.Join([]string{
+		"* Added github.com/foo/baz (v0.2.0, 2 pkgs)",
+		"* Changed github.com/foo/bar: v1.0.0 -> v1.1.0; 17 -> 23 pkgs",
+		"",
+	}, "\n")
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
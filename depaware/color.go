@@ -0,0 +1,93 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/diff/write"
+)
+
+// diffColorOptions returns the options for writing the diffs of -check
+// in color, according to -color and where they go: stderr, or stdout
+// with -diff-output=stdout. Diffs written to files are never colored.
+func (r *runner) diffColorOptions() []write.Option {
+	w := r.stderr
+	if r.diffOut != nil {
+		if r.diffOut != r.stdout {
+			return nil
+		}
+		w = r.stdout
+	}
+	if !useColor(r.Color, w) {
+		return nil
+	}
+	return []write.Option{write.TerminalColor()}
+}
+
+// useColor reports whether to write escape sequences for color to w,
+// given the -color mode. Only files can be terminals. On Windows, it
+// turns on the console's support for them, which is off by default.
+func useColor(mode string, w io.Writer) bool {
+	f, isFile := w.(*os.File)
+	switch mode {
+	case "always":
+		if isFile {
+			enableVirtualTerminal(f)
+		}
+		return true
+	case "auto":
+		return isFile && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" &&
+			isTerminal(f) && enableVirtualTerminal(f)
+	}
+	return false
+}
+
+// padRight returns s followed by enough spaces to fill n columns of a
+// terminal, like fmt's "%-*s" but counting wide characters, as used in
+// Chinese, Japanese and Korean, as two columns and combining marks as
+// none, so that columns line up in editors and consoles.
+func padRight(s string, n int) string {
+	if w := displayWidth(s); w < n {
+		return s + strings.Repeat(" ", n-w)
+	}
+	return s
+}
+
+// displayWidth returns the number of terminal columns s takes.
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		switch {
+		case r < 0x1100:
+			if !unicode.Is(unicode.Mn, r) {
+				w++
+			}
+		case unicode.Is(unicode.Mn, r):
+		case isWide(r):
+			w += 2
+		default:
+			w++
+		}
+	}
+	return w
+}
+
+// isWide reports whether r is an East Asian wide or fullwidth
+// character, roughly as in Unicode Standard Annex #11.
+func isWide(r rune) bool {
+	return r >= 0x1100 && r <= 0x115F || // Hangul Jamo
+		r >= 0x2E80 && r <= 0xA4CF && r != 0x303F || // CJK ... Yi
+		r >= 0xAC00 && r <= 0xD7A3 || // Hangul syllables
+		r >= 0xF900 && r <= 0xFAFF || // CJK compatibility ideographs
+		r >= 0xFE30 && r <= 0xFE4F || // CJK compatibility forms
+		r >= 0xFF00 && r <= 0xFF60 || // fullwidth forms
+		r >= 0xFFE0 && r <= 0xFFE6 ||
+		r >= 0x1F300 && r <= 0x1F64F || // pictographs and emoticons
+		r >= 0x20000 && r <= 0x3FFFD // CJK extensions
+}
@@ -0,0 +1,42 @@
+package depaware
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestPadRight(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"abc", 5, "abc  "},
+		{"example.com/工具", 18, "example.com/工具  "}, // each ideograph takes two columns
+		{"café", 6, "café  "},                    // combining accent takes none
+		{"toolong", 3, "toolong"},
+	}
+	for _, tt := range tests {
+		if got := padRight(tt.s, tt.width); got != tt.want {
+			t.Errorf("padRight(%q, %d) = %q; want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	f, err := ioutil.TempFile(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if useColor("auto", f) {
+		t.Error(`useColor("auto") = true for a file`)
+	}
+	if !useColor("always", f) {
+		t.Error(`useColor("always") = false`)
+	}
+	if useColor("never", os.Stderr) {
+		t.Error(`useColor("never") = true`)
+	}
+}
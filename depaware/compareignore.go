@@ -0,0 +1,78 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// compareIgnoreDirective is the depaware.txt directive that records the
+// patterns of -compare-ignore.
+const compareIgnoreDirective = "compare-ignore"
+
+// compareIgnorePatterns returns the patterns of volatile dependencies,
+// whose presence or absence doesn't make -check fail: those of the
+// -compare-ignore flag value flagVal, or if it's empty, of the existing
+// file's directives, plus those of compare-ignore rules in p, if any.
+// It also returns the patterns to record in the file, which don't
+// include the policy's.
+func compareIgnorePatterns(flagVal string, directives map[string]string, p *policy) (all patternSet, recorded []string) {
+	if flagVal == "" {
+		flagVal = directives[compareIgnoreDirective]
+	}
+	recorded = splitPatterns(flagVal)
+	patterns := append([]string(nil), recorded...)
+	if p != nil {
+		for _, r := range p.Rules {
+			if r.Kind == "compare-ignore" {
+				patterns = append(patterns, r.Args[0])
+			}
+		}
+	}
+	return newPatternSet(patterns), recorded
+}
+
+// sameIgnoring reports whether the depaware.txt contents old list the
+// same dependencies as f, other than those matching patterns. The
+// footer isn't compared, as the per-OS counts include ignored
+// dependencies too. Lines of old may be up to maxLine bytes long.
+func sameIgnoring(old []byte, f *depsFile, patterns patternSet, maxLine int) bool {
+	of, err := parseDepsFile(bytes.NewReader(old), maxLine)
+	if err != nil || of.Pkg != f.Pkg || !reflect.DeepEqual(of.Directives, f.Directives) {
+		return false
+	}
+	return reflect.DeepEqual(withoutIgnored(of.Entries, patterns), withoutIgnored(f.Entries, patterns))
+}
+
+// withoutIgnored returns the entries that don't match patterns.
+func withoutIgnored(entries []fileEntry, patterns patternSet) []fileEntry {
+	var out []fileEntry
+	for _, e := range entries {
+		if !patterns.Match(e.Pkg) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// ignoredChanges returns the dependencies matching patterns that were
+// added to or removed from old in entries, for reporting what -check
+// let through.
+func ignoredChanges(old []byte, entries []fileEntry, patterns patternSet) []string {
+	added, removed := depChanges(old, entries)
+	var msgs []string
+	for _, pkg := range added {
+		if patterns.Match(pkg) {
+			msgs = append(msgs, "+"+pkg)
+		}
+	}
+	for _, pkg := range removed {
+		if patterns.Match(pkg) {
+			msgs = append(msgs, "-"+pkg)
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,52 @@
+package depaware
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompareIgnorePatterns(t *testing.T) {
+	p, err := parsePolicy(strings.NewReader("deny example.com/evil/...\ncompare-ignore golang.org/x/exp/...\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, recorded := compareIgnorePatterns("", map[string]string{"compare-ignore": "internal/goexperiment"}, p)
+	if want := []string{"internal/goexperiment", "golang.org/x/exp/..."}; !reflect.DeepEqual(all.patterns, want) {
+		t.Errorf("all = %q; want %q", all.patterns, want)
+	}
+	if want := []string{"internal/goexperiment"}; !reflect.DeepEqual(recorded, want) {
+		t.Errorf("recorded = %q; want %q", recorded, want)
+	}
+	if all, recorded := compareIgnorePatterns("a,b", map[string]string{"compare-ignore": "c"}, nil); !reflect.DeepEqual(all.patterns, []string{"a", "b"}) || !reflect.DeepEqual(recorded, all.patterns) {
+		t.Errorf("with the flag: got %q, %q; want the flag's patterns only", all.patterns, recorded)
+	}
+	if vs := p.Evaluate(&policyInput{Pkg: "example.com/cmd", Entries: []fileEntry{{Pkg: "golang.org/x/exp/slices"}}}); len(vs) != 0 {
+		t.Errorf("compare-ignore rule reported violations: %v", vs)
+	}
+}
+
+func TestSameIgnoring(t *testing.T) {
+	dirs := map[string]string{"compare-ignore": "golang.org/x/exp/..."}
+	var old bytes.Buffer
+	writeDepsFile(&old, &depsFile{Pkg: "example.com/cmd", Directives: dirs, Entries: []fileEntry{
+		{Pkg: "fmt", Why: "example.com/cmd"},
+		{Pkg: "golang.org/x/exp/slices", Why: "example.com/cmd"},
+	}})
+	patterns := newPatternSet([]string{"golang.org/x/exp/..."})
+	cur := &depsFile{Pkg: "example.com/cmd", Directives: dirs, Entries: []fileEntry{
+		{Pkg: "fmt", Why: "example.com/cmd"},
+		{Pkg: "golang.org/x/exp/maps", Why: "example.com/cmd"},
+	}}
+	if !sameIgnoring(old.Bytes(), cur, patterns, defaultMaxLineBytes) {
+		t.Error("files differing by ignored dependencies aren't the same")
+	}
+	if got, want := ignoredChanges(old.Bytes(), cur.Entries, patterns), []string{"+golang.org/x/exp/maps", "-golang.org/x/exp/slices"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ignoredChanges = %q; want %q", got, want)
+	}
+	cur.Entries = append(cur.Entries, fileEntry{Pkg: "os", Why: "example.com/cmd"})
+	if sameIgnoring(old.Bytes(), cur, patterns, defaultMaxLineBytes) {
+		t.Error("a new dependency that isn't ignored was accepted")
+	}
+}
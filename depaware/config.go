@@ -0,0 +1,206 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// configSetting is a line of "depaware config": the effective value of
+// a setting and where it came from.
+type configSetting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"` // "default", "flag", "environment", "build", or the file it's from
+}
+
+// configDirective is the depaware.txt directive that supplies a flag's
+// value when the flag isn't set. For boolean flags, the directive turns
+// the flag on if its first word is On, or if On is empty, any value.
+type configDirective struct {
+	Name string
+	On   string
+}
+
+// configDirectives are the flags that fall back to the directives of
+// the existing depaware.txt file, by flag name.
+var configDirectives = map[string]configDirective{
+	"compare-ignore": {Name: compareIgnoreDirective},
+	"granularity":    {Name: "granularity"},
+	"hide":           {Name: "hide"},
+	"internal":       {Name: "internal", On: "show"},
+	"native":         {Name: "native", On: "badge"},
+	"own-internal":   {Name: "own-internal"},
+	"record-version": {Name: versionDirective},
+	"roots":          {Name: rootsDirective},
+	"show":           {Name: "show"},
+	"symbols":        {Name: "symbols", On: "count"},
+}
+
+// configEnv are the environment variables that change what depaware
+// does: GOFLAGS when loading packages, and NO_COLOR and TERM for
+// -color=auto.
+var configEnv = []string{"GOFLAGS", "NO_COLOR", "TERM"}
+
+// runConfig implements "depaware config", which prints the effective
+// configuration of a run with the same flags, and where each value came
+// from, to debug why two runs, such as CI's and a local one, behave
+// differently. Flags take precedence over the directives of the
+// package's existing depaware.txt file, which take precedence over the
+// defaults; compare-ignore rules of the -policy file add to
+// -compare-ignore. Without a package, no depaware.txt file is read.
+//
+// Usage:
+//
+//	depaware [flags] config [-json] [package]
+func (r *runner) runConfig(args []string) error {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.SetOutput(r.stderr)
+	asJSON := fs.Bool("json", false, "print the configuration as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return errors.New("usage: depaware [flags] config [-json] [package]")
+	}
+	var file string
+	var directives map[string]string
+	if fs.NArg() == 1 {
+		var err error
+		if file, err = r.configFile(fs.Arg(0)); err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err == nil {
+			if directives, err = parseDirectives(bytes.NewReader(data), r.MaxLineBytes); err != nil {
+				return fmt.Errorf("%s: %v", file, err)
+			}
+		}
+	}
+	var p *policy
+	if r.Policy != "" {
+		var err error
+		if p, err = readPolicy(r.Policy); err != nil {
+			return err
+		}
+	}
+	settings := r.effectiveConfig(file, directives, p, os.Getenv)
+	if *asJSON {
+		enc := json.NewEncoder(r.stdout)
+		enc.SetIndent("", "\t")
+		return enc.Encode(settings)
+	}
+	writeConfig(r.stdout, settings)
+	return nil
+}
+
+// configFile returns the name of the depaware.txt file of the package
+// named by the pattern pkg, which needn't exist.
+func (r *runner) configFile(pkg string) (string, error) {
+	ipaths, err := pkgPaths(pkg)
+	if err != nil {
+		return "", err
+	}
+	if len(ipaths) != 1 {
+		return "", fmt.Errorf("%s doesn't name a single package", pkg)
+	}
+	// Load it as a run would, with -goos and -tags, so that its
+	// directory is the one the run would find.
+	_, dir, err := r.loadDepsConfig(ipaths[0], strings.Split(r.GOOS, ","), loadConfig{})
+	if err != nil {
+		return "", err
+	}
+	if r.OutputTemplate != "" {
+		if r.outputTmpl, err = parseOutputTemplate(r.OutputTemplate); err != nil {
+			return "", fmt.Errorf("-output-template: %v", err)
+		}
+	}
+	return r.depawareFile(ipaths[0], dir)
+}
+
+// effectiveConfig returns the value and source of every flag, given the
+// directives of the depaware.txt file named file and the policy p, if
+// any, followed by the environment variables in configEnv that getenv
+// says are set and depaware's version.
+func (r *runner) effectiveConfig(file string, directives map[string]string, p *policy, getenv func(string) string) []configSetting {
+	// Register the flags on a copy of the options, to get their
+	// defaults, and then set the copy to the actual options.
+	o := new(Options)
+	fs := flag.NewFlagSet("depaware", flag.ContinueOnError)
+	o.RegisterFlags(fs)
+	*o = r.Options
+
+	given := make(map[string]bool)
+	if r.FlagSet != nil {
+		r.FlagSet.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	}
+
+	var settings []configSetting
+	fs.VisitAll(func(f *flag.Flag) {
+		s := configSetting{Name: "-" + f.Name, Value: f.Value.String(), Source: "default"}
+		d, ok := configDirectives[f.Name]
+		recorded, inFile := directives[d.Name]
+		inFile = ok && inFile
+		// A flag given its default value doesn't override the file's
+		// directive: the run falls back to it just the same.
+		switch {
+		case s.Value != f.DefValue:
+			s.Source = "flag"
+		case inFile && !isBoolFlag(f):
+			s.Value, s.Source = recorded, file
+		case inFile && (d.On == "" || strings.HasPrefix(recorded+" ", d.On+" ")):
+			s.Value, s.Source = "true", file
+		case given[f.Name]:
+			s.Source = "flag"
+		}
+		if f.Name == "compare-ignore" && p != nil {
+			all, _ := compareIgnorePatterns(s.Value, nil, p)
+			if added := all.String(); added != s.Value {
+				s.Value = added
+				if s.Source == "default" {
+					s.Source = r.Policy
+				} else {
+					s.Source += ", " + r.Policy
+				}
+			}
+		}
+		settings = append(settings, s)
+	})
+	for _, name := range configEnv {
+		if v := getenv(name); v != "" {
+			settings = append(settings, configSetting{Name: name, Value: v, Source: "environment"})
+		}
+	}
+	return append(settings, configSetting{Name: "version", Value: toolVersion(), Source: "build"})
+}
+
+// isBoolFlag reports whether f is a boolean flag, which doesn't need a
+// value on the command line.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// writeConfig writes settings to w as a table.
+func writeConfig(w io.Writer, settings []configSetting) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "setting\tvalue\tsource\n")
+	for _, s := range settings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Name, orDash(s.Value), s.Source)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,71 @@
+package depaware
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestEffectiveConfig(t *testing.T) {
+	r := &runner{Options: *NewOptions()}
+	r.Hide = "example.com/flag/..."
+	r.Policy = "depaware.policy"
+	p, err := parsePolicy(strings.NewReader("compare-ignore example.com/policy/...\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	directives := map[string]string{
+		"hide":                 "example.com/file/...",
+		"show":                 "runtime/cgo",
+		"native":               "badge",
+		"symbols":              "count on linux",
+		compareIgnoreDirective: "example.com/flaky",
+	}
+	env := map[string]string{"GOFLAGS": "-mod=vendor"}
+	got := make(map[string]configSetting)
+	for _, s := range r.effectiveConfig("cmd/depaware.txt", directives, p, func(k string) string { return env[k] }) {
+		got[s.Name] = s
+	}
+	for _, want := range []configSetting{
+		{"-hide", "example.com/flag/...", "flag"},
+		{"-show", "runtime/cgo", "cmd/depaware.txt"},
+		{"-native", "true", "cmd/depaware.txt"},
+		{"-symbols", "true", "cmd/depaware.txt"},
+		{"-compare-ignore", "example.com/flaky,example.com/policy/...", "cmd/depaware.txt, depaware.policy"},
+		{"-check", "false", "default"},
+		{"-file", "depaware.txt", "default"},
+		{"GOFLAGS", "-mod=vendor", "environment"},
+	} {
+		if got[want.Name] != want {
+			t.Errorf("got %+v; want %+v", got[want.Name], want)
+		}
+	}
+	if _, ok := got["TERM"]; ok {
+		t.Error("unset environment variable TERM is listed")
+	}
+	if got["version"].Source != "build" {
+		t.Errorf("got version %+v; want it from the build", got["version"])
+	}
+
+	// Flags given their default values come from the flag, unless the
+	// run falls back to a directive.
+	r.FlagSet = flag.NewFlagSet("depaware", flag.ContinueOnError)
+	r.FlagSet.String("goos", "", "")
+	r.FlagSet.String("show", "", "")
+	if err := r.FlagSet.Parse([]string{"-goos=" + r.GOOS, "-show="}); err != nil {
+		t.Fatal(err)
+	}
+	got = make(map[string]configSetting)
+	for _, s := range r.effectiveConfig("cmd/depaware.txt", directives, p, func(string) string { return "" }) {
+		got[s.Name] = s
+	}
+	for _, want := range []configSetting{
+		{"-goos", r.GOOS, "flag"},
+		{"-show", "runtime/cgo", "cmd/depaware.txt"},
+		{"-file", "depaware.txt", "default"},
+	} {
+		if got[want.Name] != want {
+			t.Errorf("with given flags: got %+v; want %+v", got[want.Name], want)
+		}
+	}
+}
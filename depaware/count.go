@@ -0,0 +1,47 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// runCount implements "depaware count", which prints the number of
+// dependencies of each package, its modules and per GOOS, without
+// reading or writing depaware.txt files. It skips loading file lists
+// and loads all GOOS values concurrently, so it's faster than a full
+// run, for quick interactive checks and scripts.
+//
+// Usage:
+//
+//	depaware count packages
+func (r *runner) runCount(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: depaware count packages")
+	}
+	ipaths, err := pkgPaths(args...)
+	if err != nil {
+		return err
+	}
+	geese := strings.Split(r.GOOS, ",")
+	for _, pkg := range ipaths {
+		d, _, err := r.loadDepsConfig(pkg, geese, loadConfig{Parallel: true, NoFiles: true})
+		if err != nil {
+			return fmt.Errorf("%s: %v", pkg, err)
+		}
+		d.hideDeps(r.visibility(nil))
+		writeCount(r.stdout, pkg, d, geese)
+	}
+	return nil
+}
+
+// writeCount writes the "depaware count" line for pkg to w, such as
+// "example.com/cmd: 412 packages, 37 modules (linux: 400, darwin: 398)".
+// The main module and the standard library aren't counted as modules.
+func writeCount(w io.Writer, pkg string, d *deps, geese []string) {
+	fmt.Fprintf(w, "%s: %d packages, %d modules (%s)\n", pkg, len(d.Deps), len(d.Modules()), osSummary(geese, d.OSCounts(geese)))
+}
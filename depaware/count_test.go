@@ -0,0 +1,22 @@
+package depaware
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+func TestWriteCount(t *testing.T) {
+	d := testModuleDeps()
+	d.MainModule = "example.com/cmd"
+	d.Module["example.com/cmd/util"] = module.Version{Path: "example.com/cmd"}
+	d.Deps = append(d.Deps, "example.com/cmd/util")
+	d.DepOnOS[pkgGOOS{"example.com/cmd/util", "linux"}] = true
+	var buf bytes.Buffer
+	writeCount(&buf, "example.com/cmd", d, []string{"linux", "windows"})
+	const want = "example.com/cmd: 6 packages, 2 modules (linux: 6, windows: 3)\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// newDepNote returns the note on the new dependency pkg of root for
+// the -check diff, such as "new module github.com/foo/bar v1.2.3, 7
+// transitive pkgs, pulled by example.com/cmd -> github.com/foo/bar",
+// so that reviewers can see what it costs and where it comes from
+// without running other commands. oldPkgs are the entries of the
+// existing depaware.txt file, to tell whether pkg's module is new.
+func (d *deps) newDepNote(root, pkg string, oldPkgs map[string]bool) string {
+	var parts []string
+	if m, ok := d.Module[pkg]; ok && m.Path != d.MainModule {
+		isNew := true
+		for old := range oldPkgs {
+			if inModule(old, m.Path) {
+				isNew = false
+				break
+			}
+		}
+		if isNew {
+			parts = append(parts, strings.TrimSpace("new module "+m.Path+" "+m.Version))
+		}
+	}
+	parts = append(parts, fmt.Sprintf("%d transitive pkgs", d.TransitiveCount(pkg)))
+	if chain := d.shortestChain(root, pkg); len(chain) > 1 {
+		parts = append(parts, "pulled by "+strings.Join(chain[:len(chain)-1], " -> "))
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// annotateNewDeps returns the depaware.txt contents generated for
+// root with the line of each package dependency that isn't in the
+// existing file's contents old annotated with newDepNote. The result is
+// only for showing in the -check diff, as it can't be parsed back.
+// Lines for modules, with -granularity=module, aren't annotated.
+func (d *deps) annotateNewDeps(contents, old []byte, root string) []byte {
+	oldPkgs := entryPkgs(old)
+	var buf bytes.Buffer
+	for _, line := range strings.SplitAfter(string(contents), "\n") {
+		e, err := parseFileEntry(strings.TrimSuffix(line, "\n"))
+		if err != nil || e.Count > 0 || oldPkgs[e.Pkg] || !stringsContains(d.Deps, e.Pkg) {
+			buf.WriteString(line)
+			continue
+		}
+		buf.WriteString(strings.TrimSuffix(line, "\n"))
+		buf.WriteString("  " + d.newDepNote(root, e.Pkg, oldPkgs))
+		if strings.HasSuffix(line, "\n") {
+			buf.WriteString("\n")
+		}
+	}
+	return buf.Bytes()
+}
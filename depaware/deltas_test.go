@@ -0,0 +1,39 @@
+package depaware
+
+import (
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+func TestAnnotateNewDeps(t *testing.T) {
+	d := testSnapshotDeps()
+	d.Module = map[string]module.Version{
+		"github.com/a/lib":      {Path: "github.com/a/lib", Version: "v1.2.3"},
+		"github.com/a/lib/util": {Path: "github.com/a/lib", Version: "v1.2.3"},
+	}
+	const header = "example.com/cmd dependencies: (generated by github.com/tailscale/depaware)\n\n"
+	old := []byte(header +
+		"        fmt from example.com/cmd\n" +
+		"        io  from fmt\n")
+	contents := []byte(header +
+		"        github.com/a/lib      from example.com/cmd\n" +
+		"   W    github.com/a/lib/util from github.com/a/lib\n" +
+		"        fmt                   from example.com/cmd\n" +
+		"        io                    from fmt\n")
+	want := header +
+		"        github.com/a/lib      from example.com/cmd  (new module github.com/a/lib v1.2.3, 5 transitive pkgs, pulled by example.com/cmd)\n" +
+		"   W    github.com/a/lib/util from github.com/a/lib  (new module github.com/a/lib v1.2.3, 3 transitive pkgs, pulled by example.com/cmd -> github.com/a/lib)\n" +
+		"        fmt                   from example.com/cmd\n" +
+		"        io                    from fmt\n"
+	if got := string(d.annotateNewDeps(contents, old, "example.com/cmd")); got != want {
+		t.Errorf("annotateNewDeps =\n%s\nwant:\n%s", got, want)
+	}
+
+	// Once the module is a dependency, its new packages aren't a new
+	// module.
+	old = []byte(string(old) + "        github.com/a/lib from example.com/cmd\n")
+	if got, want := d.newDepNote("example.com/cmd", "github.com/a/lib/util", entryPkgs(old)), "(3 transitive pkgs, pulled by example.com/cmd -> github.com/a/lib)"; got != want {
+		t.Errorf("newDepNote = %q; want %q", got, want)
+	}
+}
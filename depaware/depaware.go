@@ -9,173 +9,943 @@
 package depaware
 
 import (
-	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 	"unicode"
 
 	"github.com/pkg/diff"
 	"github.com/pkg/diff/write"
+	"golang.org/x/mod/module"
 	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/imports"
 )
 
-var (
-	check    = flag.Bool("check", false, "if true, check whether dependencies match the depaware.txt file")
-	update   = flag.Bool("update", false, "if true, update the depaware.txt file")
-	fileName = flag.String("file", "depaware.txt", "name of the file to write")
-	osList   = flag.String("goos", "linux,darwin,windows", "comma-separated list of GOOS values")
-	tags     = flag.String("tags", "", "comma-separated list of build tags to use when loading packages")
-	internal = flag.Bool("internal", false, "if true, include internal packages in the output")
-)
+// runner is a single run of depaware: its options, and what it derives
+// from them or accumulates while processing packages.
+type runner struct {
+	Options
+
+	stdout, stderr io.Writer
+	logger         *slog.Logger
+
+	activePolicy   *policy     // the parsed -policy file, if any
+	activeBaseline baseline    // the parsed -baseline file, if any
+	stdRenames     []stdRename // loaded from -std-renames, if any
+	roots          []string    // import paths of the -roots packages
+
+	// outputTmpl is the parsed -output-template, if any, and
+	// outputFiles maps the files it named so far to the package they're
+	// for, so that two packages don't silently share one.
+	outputTmpl  *template.Template
+	outputFiles map[string]string
+
+	// diffOut is where -check writes the diffs of out-of-date files, as
+	// opened from -diff-output. If it's nil, they go to stderr along
+	// with the rest of the messages, as they always have.
+	diffOut io.Writer
+
+	// stdoutHeaders is whether process precedes the depaware.txt
+	// contents it prints with a header naming the file, when it prints
+	// several packages' files, so that scripts can split the output.
+	stdoutHeaders bool
+
+	allAnnotations map[string]annotation // -annotations output of all packages, keyed by "file:line"
+	explanations   []explanation         // failures recorded for -explain
+
+	args           []string // the arguments Run was given, for provenances
+	provenanceOnce sync.Once
+	runProvenance  provenance // the parts of every provenance that don't change during the run
+
+	timingsMu sync.Mutex
+	timings   []timing
+	loads     loadCounts // for -stats-file, guarded by timingsMu
+}
 
+// commands are the depaware subcommands, selected by the first
+// non-flag argument. Anything else is treated as a package pattern.
+var commands = map[string]func(r *runner, args []string) error{
+	"changelog":     (*runner).runChangelog,
+	"config":        (*runner).runConfig,
+	"count":         (*runner).runCount,
+	"git-config":    (*runner).runGitConfig,
+	"git-diff":      (*runner).runGitDiff,
+	"merge":         (*runner).runMerge,
+	"policy":        (*runner).runPolicy,
+	"query":         (*runner).runQuery,
+	"rdeps":         (*runner).runRdeps,
+	"reach":         (*runner).runReach,
+	"release-notes": (*runner).runReleaseNotes,
+	"selftest":      (*runner).runSelftest,
+	"snapshot-diff": (*runner).runSnapshotDiff,
+	"stats":         (*runner).runStats,
+	"status":        (*runner).runStatus,
+	"todos":         (*runner).runTodos,
+	"top":           (*runner).runTop,
+	"why":           (*runner).runWhy,
+	"workspace":     (*runner).runWorkspace,
+}
+
+// Main runs the depaware command: it parses the command-line flags into
+// Options and runs depaware with them, exiting with status 1 if it
+// fails.
 func Main() {
+	opts := new(Options)
+	opts.RegisterFlags(flag.CommandLine)
 	flag.Parse()
-	if *check && *update {
-		log.Fatalf("-check and -update can't be used together")
+	opts.FlagSet = flag.CommandLine
+	var err error
+	if opts.Logger, err = newLogger(opts.LogFormat, os.Stderr, opts.Verbose); err != nil {
+		l, _ := newLogger("plain", os.Stderr, false)
+		l.Error(err.Error())
+		os.Exit(1)
+	}
+	if err = Run(opts, flag.Args()); err != nil {
+		if err != errReported {
+			opts.Logger.Error(err.Error())
+		}
+		os.Exit(1)
+	}
+}
+
+// Run runs depaware with opts on args, which are what follows the flags
+// on the command line: package patterns, or a subcommand and its
+// arguments. It's Main without the flag parsing and exiting, for
+// programs that embed depaware. Concurrent runs don't share any state,
+// other than the files they read and write, which are relative to the
+// current directory.
+//
+// Problems with packages are logged as they're found, and if there are
+// any, Run returns an error once it's done with all the packages.
+func Run(opts *Options, args []string) error {
+	r := &runner{
+		Options:        *opts,
+		args:           args,
+		stdout:         opts.Stdout,
+		stderr:         opts.Stderr,
+		logger:         opts.Logger,
+		outputFiles:    make(map[string]string),
+		allAnnotations: make(map[string]annotation),
+	}
+	if r.stdout == nil {
+		r.stdout = os.Stdout
+	}
+	if r.stderr == nil {
+		r.stderr = os.Stderr
+	}
+	if r.logger == nil {
+		var err error
+		if r.logger, err = newLogger(r.LogFormat, r.stderr, r.Verbose); err != nil {
+			return err
+		}
+	}
+	if r.StatsFile == "" {
+		return r.run(args)
+	}
+	start := time.Now()
+	err := r.run(args)
+	r.saveStats(args, start, err)
+	return err
+}
+
+// run is Run once the runner is set up.
+func (r *runner) run(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			if err := cmd(r, args[1:]); err != nil {
+				return fmt.Errorf("%s: %v", args[0], err)
+			}
+			return nil
+		}
+	}
+	if r.Check && r.Update {
+		return errors.New("-check and -update can't be used together")
+	}
+	if r.Explain != "" && !r.Check {
+		return errors.New("-explain requires -check")
+	}
+	switch r.Color {
+	case "never", "always", "auto":
+	default:
+		return fmt.Errorf("unknown -color %q", r.Color)
+	}
+	if r.DiffOutput != "stderr" && !r.Check {
+		return errors.New("-diff-output requires -check")
+	}
+	switch r.Format {
+	case "text":
+	case "json", "metrics-json", "treemap", "orgs", "modules", "platforms", "buildtime", "vex":
+		if r.Check || r.Update {
+			return errors.New("-check and -update require -format=text")
+		}
+	default:
+		return fmt.Errorf("unknown -format %q", r.Format)
+	}
+	switch r.Granularity {
+	case "", "package", "module", "hybrid":
+	default:
+		return fmt.Errorf("unknown -granularity %q", r.Granularity)
+	}
+	switch r.OwnInternal {
+	case "", "show", "badge", "hide", "collapse":
+	default:
+		return fmt.Errorf("unknown -own-internal %q", r.OwnInternal)
+	}
+	if r.Compat != 0 && !r.Check {
+		return errors.New("-compat requires -check")
+	}
+	if r.Compat < 0 {
+		return errors.New("-compat must not be negative")
+	}
+	if r.StdRenames != "" && !r.Check {
+		return errors.New("-std-renames requires -check")
+	}
+	var err error
+	if r.stdRenames, err = loadStdRenames(r.StdRenames); err != nil {
+		return fmt.Errorf("-std-renames: %v", err)
+	}
+	if r.Policy != "" {
+		if r.activePolicy, err = readPolicy(r.Policy); err != nil {
+			return fmt.Errorf("policy: %v", err)
+		}
+	}
+	if r.Baseline != "" {
+		if r.activeBaseline, err = readBaseline(r.Baseline); err != nil {
+			return fmt.Errorf("baseline: %v", err)
+		}
 	}
 
-	ipaths, err := pkgPaths(flag.Args()...)
+	ipaths, err := pkgPaths(args...)
 	if err != nil {
-		log.Fatalf("could not resolve packages: %v", err)
+		return fmt.Errorf("could not resolve packages: %v", err)
 	}
 	for _, pkg := range ipaths {
 		if strings.HasPrefix(pkg, "-") {
-			log.Fatalf("bogus package argument %q; flags go before packages", pkg)
+			return fmt.Errorf("bogus package argument %q; flags go before packages", pkg)
+		}
+	}
+	if r.OutputTemplate != "" {
+		if r.outputTmpl, err = parseOutputTemplate(r.OutputTemplate); err != nil {
+			return fmt.Errorf("-output-template: %v", err)
 		}
 	}
+	if r.Roots != "" {
+		if len(ipaths) != 1 {
+			return fmt.Errorf("-roots requires a single package; got %d", len(ipaths))
+		}
+		if r.roots, err = pkgPaths(splitPatterns(r.Roots)...); err != nil {
+			return fmt.Errorf("-roots: %v", err)
+		}
+	}
+	if r.Snapshot != "" && len(ipaths) != 1 {
+		return fmt.Errorf("-snapshot requires a single package; got %d", len(ipaths))
+	}
+	sort.Strings(ipaths)
+	r.stdoutHeaders = len(ipaths) > 1 && r.Format == "text" && !r.Check && !r.Update
+	var closeDiff func() error
+	if r.diffOut, closeDiff, err = r.openOutput(r.DiffOutput); err != nil {
+		return fmt.Errorf("-diff-output: %v", err)
+	}
+	// Keep going after a package fails, so that a single run reports
+	// all the problems, and fail at the end.
+	var failed []string
 	for i, pkg := range ipaths {
-		process(pkg)
+		if err := r.process(pkg); err != nil {
+			if err != errReported {
+				r.logger.Error(err.Error(), errorAttrs(pkg, err)...)
+				if r.Explain != "" {
+					r.explainError(pkg, err)
+				}
+			}
+			failed = append(failed, pkg)
+		}
 		// If we're printing to stdout, and there are more packages to come,
-		// add an extra newline.
-		if i != len(ipaths)-1 && !*check && !*update {
-			fmt.Println()
+		// add an extra newline before the next one's header. Metrics are
+		// one line per package, though.
+		if i != len(ipaths)-1 && !r.Check && !r.Update && r.Format != "metrics-json" {
+			fmt.Fprintln(r.stdout)
+		}
+	}
+	if err := closeDiff(); err != nil {
+		return fmt.Errorf("-diff-output: %v", err)
+	}
+	if r.Annotations != "" {
+		if err := writeAnnotations(r.Annotations, r.allAnnotations); err != nil {
+			return err
+		}
+	}
+	if r.Explain != "" && len(r.explanations) > 0 {
+		if err := r.writeExplainBundle(r.Explain, r.explanations); err != nil {
+			return err
+		}
+	}
+	if r.Verbose {
+		writeSlowest(r.stderr, r.timings, r.Slowest)
+	}
+	if len(failed) > 0 {
+		if len(ipaths) > 1 {
+			fmt.Fprintf(r.stderr, "\n%d of %d packages failed:\n", len(failed), len(ipaths))
+			for _, pkg := range failed {
+				fmt.Fprintf(r.stderr, "\t%s\n", pkg)
+			}
 		}
+		return errReported
 	}
+	return nil
 }
 
-func process(pkg string) {
-	geese := strings.Split(*osList, ",")
-	var d deps
-	var dir string
-	var buildFlags []string
-	if *tags != "" {
-		buildFlags = append(buildFlags, "-tags", *tags)
+// errReported is returned by process for failures that it has already
+// reported on stderr, such as an out-of-date depaware.txt file, and by
+// Run if any package failed.
+var errReported = errors.New("failed")
+
+// process generates, checks or updates the dependencies of pkg,
+// according to the flags.
+func (r *runner) process(pkg string) error {
+	geese := strings.Split(r.GOOS, ",")
+	roots := otherRoots(pkg, r.roots)
+	d, dir, err := r.loadDepsConfig(pkg, geese, loadConfig{Roots: roots})
+	if err != nil {
+		return err
+	}
+
+	daFile, err := r.depawareFile(pkg, dir)
+	if err != nil {
+		return err
 	}
-	for _, goos := range geese {
-		env := os.Environ()
-		env = append(env, "GOARCH=amd64", "GOOS="+goos, "CGO_ENABLED=1")
-		cfg := &packages.Config{
-			Mode:       packages.NeedImports | packages.NeedDeps | packages.NeedFiles | packages.NeedName | packages.NeedCompiledGoFiles,
-			Env:        env,
-			BuildFlags: buildFlags,
+	// Parse existing depaware.txt, if present,
+	// to get the existing dependency source the file lists.
+	daContents, daErr := ioutil.ReadFile(daFile)
+	var preferredWhy, comments, oldDirectives map[string]string
+	if daErr == nil {
+		if preferredWhy, err = parsePreferredWhy(bytes.NewReader(daContents), r.MaxLineBytes); err != nil {
+			return fmt.Errorf("%s: %v", daFile, err)
 		}
+		if comments, err = parseComments(bytes.NewReader(daContents), r.MaxLineBytes); err != nil {
+			return fmt.Errorf("%s: %v", daFile, err)
+		}
+		if oldDirectives, err = parseDirectives(bytes.NewReader(daContents), r.MaxLineBytes); err != nil {
+			return fmt.Errorf("%s: %v", daFile, err)
+		}
+	}
+	if r.Roots == "" && oldDirectives[rootsDirective] != "" {
+		// The file covers other binaries too, which only it says, so
+		// load them along with pkg now.
+		if roots = otherRoots(pkg, splitPatterns(oldDirectives[rootsDirective])); len(roots) > 0 {
+			if d, _, err = r.loadDepsConfig(pkg, geese, loadConfig{Roots: roots}); err != nil {
+				return err
+			}
+		}
+	}
+	if d.RootName != "" && d.RootName != "main" {
+		msg := fmt.Sprintf("is package %s, not a main package; depaware is usually run on binaries, whose files list everything they link", d.RootName)
+		if r.RequireMain {
+			return errors.New(msg)
+		}
+		if daErr != nil {
+			// Only new files, as existing ones for libraries were
+			// presumably made on purpose.
+			r.logger.Info(msg+"; -require-main makes this an error", "package", pkg)
+		}
+	}
+	vis := r.visibility(oldDirectives)
+	d.hideDeps(vis)
+	if r.Snapshot != "" {
+		s := newSnapshot(pkg, geese, d)
+		s.Provenance = r.newProvenance(time.Now())
+		if err := writeSnapshot(r.Snapshot, s); err != nil {
+			return err
+		}
+	}
 
-		pkgs, err := packages.Load(cfg, pkg)
-		if err != nil {
-			log.Fatalf("for GOOS=%v: %v", goos, err)
+	if r.MaxOrgs > 0 {
+		if orgs := d.OrgCounts(); len(orgs) > r.MaxOrgs {
+			return fmt.Errorf("depends on %d distinct third-party orgs; -max-orgs is %d", len(orgs), r.MaxOrgs)
 		}
+	}
 
-		packages.Visit(pkgs, nil, func(p *packages.Package) {
-			for imp := range p.Imports {
-				d.AddEdge(p.PkgPath, imp)
-			}
-			if p.PkgPath == pkg {
-				if dir == "" && len(p.GoFiles) > 0 {
-					dir = filepath.Dir(p.GoFiles[0])
-				}
-				return
+	var dups []nearDup
+	if r.NearDups {
+		dups = findNearDups(d.Modules())
+	}
+
+	switch r.Format {
+	case "treemap":
+		var fileGeese []string
+		if r.Deep {
+			fileGeese = geese
+		}
+		var sizes map[string]symbolStat
+		if r.Sizes {
+			if sizes, err = r.binarySymbols(pkg, sizeGOOS(geese), d.allPackages(pkg)); err != nil {
+				return err
 			}
-			d.AddDep(p.PkgPath, goos)
+		}
+		writeTreemap(r.stdout, pkg, d, fileGeese, sizes)
+		return nil
+	case "orgs":
+		writeOrgCounts(r.stdout, pkg, d)
+		return nil
+	case "vex":
+		vr := newVEXReport(pkg, d, geese)
+		vr.Provenance = r.newProvenance(time.Now())
+		return writeVEXReport(r.stdout, vr)
+	case "buildtime":
+		goos := sizeGOOS(geese)
+		times, err := r.compileTimes(pkg, goos)
+		if err != nil {
+			return err
+		}
+		writeCompileTimes(r.stdout, pkg, goos, d.ModuleCompileTimes(pkg, times))
+		return nil
+	case "modules":
+		writeDepsFile(r.stdout, &depsFile{
+			Pkg:        pkg,
+			Directives: map[string]string{"granularity": "module"},
+			Entries:    d.ModuleEntries(geese),
 		})
+		return nil
 	}
 
-	if dir == "" {
-		log.Fatalf("no .go files found for package %s", pkg)
+	gran := r.Granularity
+	if gran == "" {
+		gran = oldDirectives["granularity"]
+	}
+	var entries []fileEntry
+	var directives map[string]string
+	switch gran {
+	case "module":
+		entries = d.ModuleEntries(geese)
+		directives = map[string]string{"granularity": "module"}
+	case "hybrid":
+		entries = d.HybridEntries(geese, preferredWhy)
+		directives = map[string]string{"granularity": "hybrid"}
+	case "", "package":
+		entries = d.Entries(geese, preferredWhy)
+	default:
+		return fmt.Errorf("%s: unknown granularity %q", daFile, gran)
+	}
+	for i, e := range entries {
+		entries[i].Comment = comments[e.Pkg]
+	}
+	directives = vis.addDirectives(directives)
+	ignored, recordedIgnored := compareIgnorePatterns(r.CompareIgnore, oldDirectives, r.activePolicy)
+	if len(recordedIgnored) > 0 {
+		if directives == nil {
+			directives = make(map[string]string)
+		}
+		directives[compareIgnoreDirective] = strings.Join(recordedIgnored, ",")
+	}
+	if len(roots) > 0 {
+		if directives == nil {
+			directives = make(map[string]string)
+		}
+		directives[rootsDirective] = rootsDirectiveValue(pkg, roots)
+	}
+	ownInternal := r.OwnInternal
+	if ownInternal == "" {
+		ownInternal = oldDirectives["own-internal"]
+	}
+	if entries, err = d.ownInternalEntries(entries, geese, ownInternal); err != nil {
+		return fmt.Errorf("%s: %v", daFile, err)
+	}
+	if ownInternal != "" && ownInternal != "show" {
+		if directives == nil {
+			directives = make(map[string]string)
+		}
+		directives["own-internal"] = ownInternal
 	}
 
-	sort.Slice(d.Deps, func(i, j int) bool {
-		d1, d2 := d.Deps[i], d.Deps[j]
-		if p1, p2 := strings.Contains(d1, "."), strings.Contains(d2, "."); p1 != p2 {
-			return p1
+	var sizes map[string]symbolStat
+	sizeOS := sizeGOOS(geese)
+	symbolsOn, symbolsOS := parseSymbolsDirective(oldDirectives["symbols"])
+	withSymbols := r.Symbols || symbolsOn
+	if withSymbols && r.Check && symbolsOS != "" && symbolsOS != sizeOS {
+		return fmt.Errorf("%s: symbol counts are for GOOS=%s, but -check attributes them for GOOS=%s, the first of -goos; run -update", daFile, symbolsOS, sizeOS)
+	}
+	if withSymbols || r.Sizes && (r.Check || r.Update) {
+		if sizes, err = r.binarySymbols(pkg, sizeOS, d.allPackages(pkg)); err != nil {
+			return err
 		}
-		if x1, x2 := strings.Contains(d1, "golang.org/x/"), strings.Contains(d2, "golang.org/x/"); x1 != x2 {
-			return x2
+	}
+	if r.Native || oldDirectives["native"] == "badge" {
+		d.setNativeKinds(entries)
+		if directives == nil {
+			directives = make(map[string]string)
 		}
-		return d1 < d2
-	})
+		directives["native"] = "badge"
+	}
+	if withSymbols {
+		d.setSymbolCounts(entries, sizes)
+		if directives == nil {
+			directives = make(map[string]string)
+		}
+		directives["symbols"] = symbolsDirectiveValue(sizeOS)
+	}
+	if recorded := oldDirectives[versionDirective]; r.RecordVersion || recorded != "" {
+		if msg := versionMismatch(recorded, toolVersion()); msg != "" {
+			r.logger.Warn(daFile+" "+msg, "package", pkg)
+		}
+		if directives == nil {
+			directives = make(map[string]string)
+		}
+		directives[versionDirective] = toolVersion()
+		if r.Check && recorded != "" {
+			// A different version is only a warning, not a diff.
+			directives[versionDirective] = recorded
+		}
+	}
+	if r.Annotations != "" {
+		oldDeps := make(map[string]bool)
+		for dep := range preferredWhy {
+			oldDeps[dep] = true
+		}
+		anns, err := d.Annotations(oldDeps)
+		if err != nil {
+			return err
+		}
+		for k, v := range anns {
+			r.allAnnotations[k] = v
+		}
+	}
+	var blanks []blankImport
+	if r.BlankImports > 0 || r.activePolicy != nil && r.activePolicy.needsBlankImports() {
+		if blanks, err = d.BlankImports(pkg); err != nil {
+			return err
+		}
+	}
+	var violations []violation
+	if r.activePolicy != nil {
+		violations = r.activePolicy.Evaluate(&policyInput{
+			Pkg:          pkg,
+			Entries:      entries,
+			MainModule:   d.MainModule,
+			BlankImports: blanks,
+		})
+		violations = r.activeBaseline.Filter(pkg, violations)
+	}
 
-	// Parse existing depaware.txt, if present,
-	// to get the existing dependency source the file lists.
-	daFile := filepath.Join(dir, *fileName)
-	daContents, daErr := ioutil.ReadFile(daFile)
-	var preferredWhy map[string]string
-	if daErr == nil {
-		preferredWhy = parsePreferredWhy(bytes.NewReader(daContents))
+	var cacheErrs []string
+	if r.VerifyCache != 0 {
+		if cacheErrs, err = d.verifyModuleCache(r.VerifyCache); err != nil {
+			return err
+		}
+		for _, msg := range cacheErrs {
+			r.logger.Error("module cache: "+msg, "package", pkg)
+		}
 	}
 
-	var buf bytes.Buffer
-	fmt.Fprintf(&buf, "%s dependencies: (generated by github.com/tailscale/depaware)\n\n", pkg)
-	var osBuf bytes.Buffer
+	graph, err := d.ModuleGraph()
+	if err != nil {
+		return err
+	}
 
-	for _, pkg := range d.Deps {
-		unsafeIcon := " "
-		cgoIcon := " "
-		if d.UsesUnsafe[pkg] && !isGoPackage(pkg) {
-			unsafeIcon = "U"
+	if r.Format == "platforms" {
+		writeExclusiveDeps(r.stdout, pkg, d, geese, d.Entries(geese, preferredWhy))
+		return nil
+	}
+	if r.Format == "metrics-json" {
+		now := time.Now()
+		m := newMetrics(pkg, d, entries, now)
+		m.Provenance = r.newProvenance(now)
+		return json.NewEncoder(r.stdout).Encode(m)
+	}
+	if r.Format == "json" {
+		rep := newReport(pkg, d, geese, entries, violations)
+		if r.Deep {
+			rep.addFiles(d)
 		}
-		if d.UsesCGO[pkg] && !isGoPackage(pkg) {
-			cgoIcon = "C"
+		rep.NearDups = dups
+		if r.BlankImports > 0 {
+			rep.BlankImports = costlyBlankImports(blanks, r.BlankImports)
 		}
-		osBuf.Reset()
-		for _, goos := range geese {
-			if d.DepOnOS[pkgGOOS{pkg, goos}] {
-				osBuf.WriteRune(unicode.ToUpper(rune(goos[0])))
-			}
+		rep.CacheErrors = cacheErrs
+		rep.ModuleGraph = graph
+		rep.Provenance = r.newProvenance(time.Now())
+		return writeJSONReport(r.stdout, rep)
+	}
+
+	for _, nd := range dups {
+		r.logger.Warn(nd.String(), "package", pkg)
+	}
+	if graph != nil {
+		for _, msg := range graph.Warnings() {
+			r.logger.Warn(msg, "package", pkg)
 		}
-		if osBuf.Len() == len(geese) {
-			osBuf.Reset()
+		if len(graph.GraphOnly) > 0 {
+			r.logger.Debug(fmt.Sprintf("go.mod requires %d modules that provide no dependencies, which are only in the module graph: %s",
+				len(graph.GraphOnly), strings.Join(graph.GraphOnly, ", ")), "package", pkg)
+		}
+	}
+	if r.BlankImports > 0 {
+		for _, b := range costlyBlankImports(blanks, r.BlankImports) {
+			r.logger.Warn(b.String(), "package", pkg)
 		}
-		fmt.Fprintf(&buf, " %3s %s%s %-60s %s\n", osBuf.Bytes(), unsafeIcon, cgoIcon, pkg, d.Why(pkg, preferredWhy))
 	}
 
-	if *check {
+	for _, v := range violations {
+		r.logger.Log(context.Background(), v.Severity.logLevel(), v.detail(), "package", pkg, "rule", v.Rule)
+	}
+	policyFailed := r.Check && hasErrors(violations)
+	if r.Check && r.NoSyso {
+		for _, msg := range d.sysoViolations(pkg) {
+			r.logger.Error(msg, "package", pkg)
+			policyFailed = true
+		}
+	}
+	if r.Check && r.NoPlugin {
+		for _, msg := range d.pluginViolations(pkg) {
+			r.logger.Error(msg, "package", pkg)
+			policyFailed = true
+		}
+	} else if stringsContains(d.Deps, "plugin") {
+		r.logger.Warn("depends on the plugin package, which rules out static builds; -check -no-plugin forbids it", "package", pkg)
+	}
+	if r.Check && len(cacheErrs) > 0 {
+		policyFailed = true
+	}
+	if r.Check && r.EnforceTodos {
+		for _, e := range overdueEntries(entries, time.Now()) {
+			r.logger.Error(fmt.Sprintf("%s should have been removed by %s", e.Pkg, annotationValue(e.Comment, "remove-by")), "package", pkg)
+			policyFailed = true
+		}
+	}
+
+	if r.Sizes && r.Check {
+		sizesFile := sizesFileName(daFile)
+		data, err := ioutil.ReadFile(sizesFile)
+		if err != nil {
+			return errors.New(missingFileHint(sizesFile, "-sizes "+pkg, err))
+		}
+		goos, old, err := parseSizes(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("%s: %v", sizesFile, err)
+		}
+		if goos != sizeOS {
+			return fmt.Errorf("%s: sizes are for GOOS=%s, but -check attributes them for GOOS=%s, the first of -goos; run -update -sizes", sizesFile, goos, sizeOS)
+		}
+		for _, msg := range sizeRegressions(old, sizes, r.MaxSizeGrowth) {
+			if r.SizeWarn {
+				r.logger.Warn(msg, "package", pkg)
+			} else {
+				r.logger.Error(msg, "package", pkg)
+				policyFailed = true
+			}
+		}
+	}
+
+	writeStart := time.Now()
+	var buf bytes.Buffer
+	var footer []string
+	if r.OSSummary {
+		footer = append(footer, osSummary(geese, d.OSCounts(geese)))
+	}
+	df := &depsFile{Pkg: pkg, Directives: directives, Entries: entries, Footer: footer}
+	writeDepsFile(&buf, df)
+	if r.Check {
+		// Comparing with the existing file isn't writing it.
+		r.recordTiming(pkg, "", "write", writeStart)
+	} else {
+		defer r.recordTiming(pkg, "", "write", writeStart)
+	}
+
+	if r.Check {
 		if daErr != nil {
-			log.Fatal(daErr)
+			return errors.New(missingFileHint(daFile, pkg, daErr))
+		}
+		same := bytes.Equal(daContents, buf.Bytes())
+		if !same && r.stdRenames != nil && sameUpToRenames(daContents, df, r.stdRenames, r.MaxLineBytes) {
+			r.logger.Info(daFile+" only differs by standard library packages renamed between Go releases; run -update once everyone uses the new release", "package", pkg)
+			same = true
+		}
+		if !same && len(ignored.patterns) > 0 && sameIgnoring(daContents, df, ignored, r.MaxLineBytes) {
+			r.logger.Info(daFile+" only differs by dependencies matching -compare-ignore: "+strings.Join(ignoredChanges(daContents, entries, ignored), ", "), "package", pkg)
+			same = true
 		}
-		if bytes.Equal(daContents, buf.Bytes()) {
+		if recorded := oldDirectives[versionDirective]; !same && withinCompat(recorded, toolVersion(), r.Compat) && sameDeps(daContents, entries) {
+			r.logger.Warn(daFile+" was generated by depaware "+recorded+" in an older format, which -compat accepts; run -update to regenerate it", "package", pkg)
+			same = true
+		}
+		if same {
+			if policyFailed {
+				if r.Explain != "" {
+					r.explainCheck(pkg, daFile, daContents, "", entries, violations)
+				}
+				return errReported
+			}
 			// Success. No changes.
-			return
+			return nil
 		}
-		var opts []write.Option
-		const wantColor = false // https://github.com/tailscale/depaware/issues/11
-		if wantColor && os.Getenv("TERM") != "dumb" {
-			opts = append(opts, write.TerminalColor())
+		diffText := func(from, to string, after []byte, opts ...write.Option) (string, error) {
+			var diffBuf bytes.Buffer
+			err := diff.Text(from, to, daContents, after, &diffBuf, opts...)
+			return diffBuf.String(), err
 		}
-		fmt.Fprintf(os.Stderr, "The list of dependencies in %s is out of date.\n\n", daFile)
-		err := diff.Text("before", "after", daContents, buf.Bytes(), os.Stderr, opts...)
-		if err != nil {
-			log.Fatal(err)
+		// Diffs on stderr are for people, so annotate the new
+		// dependencies there; patches written elsewhere must apply.
+		after := buf.Bytes()
+		if r.diffOut == nil {
+			after = d.annotateNewDeps(after, daContents, pkg)
 		}
-		os.Exit(1)
+		if err := r.reportOutOfDate(daFile, daContents, entries, func(from, to string) (string, error) {
+			return diffText(from, to, after, r.diffColorOptions()...)
+		}); err != nil {
+			return err
+		}
+		if r.Explain != "" {
+			plain, err := diffText("before", "after", buf.Bytes())
+			if err != nil {
+				return err
+			}
+			r.explainCheck(pkg, daFile, daContents, plain, entries, violations)
+		}
+		return errReported
 	}
 
-	if *update {
+	if r.Update {
+		if r.SafeUpdate && daErr == nil {
+			if err := checkSafeToUpdate(daContents, r.MaxLineBytes); err != nil {
+				return fmt.Errorf("refusing to update %s: %v", daFile, err)
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(daFile), 0755); err != nil {
+			return err
+		}
 		if err := ioutil.WriteFile(daFile, buf.Bytes(), 0644); err != nil {
-			log.Fatal(err)
+			return err
 		}
-		return
+		if r.Sizes {
+			var sbuf bytes.Buffer
+			writeSizes(&sbuf, pkg, sizeOS, sizes)
+			return ioutil.WriteFile(sizesFileName(daFile), sbuf.Bytes(), 0644)
+		}
+		return nil
+	}
+
+	if r.stdoutHeaders {
+		fmt.Fprint(r.stdout, stdoutHeader(daFile))
+	}
+	_, err = r.stdout.Write(buf.Bytes())
+	return err
+}
+
+// loadDeps loads pkg and its dependencies for each of the given GOOS
+// values. It returns the merged dependencies and the package's directory.
+func (r *runner) loadDeps(pkg string, geese []string) (*deps, string, error) {
+	return r.loadDepsConfig(pkg, geese, loadConfig{})
+}
+
+// loadConfig controls how loadDepsConfig loads packages.
+type loadConfig struct {
+	Env      []string // extra environment variables for the go command
+	Parallel bool     // load all GOOS values concurrently
+	NoFiles  bool     // don't load the packages' file lists, which is faster
+	Roots    []string // other root packages whose dependencies to merge with pkg's
+	Dir      string   // directory to run the go command in; empty means the current one
+}
+
+// loadDepsConfig is like loadDeps, but with additional options.
+//
+// The packages for each GOOS are loaded independently and then merged
+// by AddPackages in the order of geese, and the result is normalized,
+// so the output only depends on the packages loaded, not on the order
+// in which the loads finish.
+func (r *runner) loadDepsConfig(pkg string, geese []string, conf loadConfig) (*deps, string, error) {
+	var buildFlags []string
+	if r.Tags != "" {
+		buildFlags = append(buildFlags, "-tags", r.Tags)
+	}
+	loaded := make([][]*packages.Package, len(geese))
+	errs := make([]error, len(geese))
+	var wg sync.WaitGroup
+	for i, goos := range geese {
+		load := func(i int, goos string) {
+			r.logger.Debug("loading packages", "package", pkg, "goos", goos)
+			defer r.recordTiming(pkg, goos, "load", time.Now())
+			loaded[i], errs[i] = loadGOOS(append([]string{pkg}, conf.Roots...), goos, buildFlags, conf)
+		}
+		if !conf.Parallel {
+			load(i, goos)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, goos string) {
+			defer wg.Done()
+			load(i, goos)
+		}(i, goos)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, "", &goosError{geese[i], withHint(err, pkg, geese[i])}
+		}
+	}
+
+	mergeStart := time.Now()
+	d := &deps{OtherRoots: conf.Roots}
+	var dir string
+	for i, goos := range geese {
+		if pkgDir := d.AddPackages(pkg, goos, loaded[i]); dir == "" {
+			dir = pkgDir
+		}
+	}
+	// Without file lists, dir is always empty, so only fail if the
+	// package couldn't be loaded for any GOOS.
+	if dir == "" && (!conf.NoFiles || allFailed(loaded)) {
+		var errs []string
+		for i, goos := range geese {
+			errs = append(errs, rootErrors(loaded[i], goos)...)
+		}
+		if len(errs) == 0 {
+			return nil, "", fmt.Errorf("no .go files found for package %s", pkg)
+		}
+		return nil, "", fmt.Errorf("no .go files found for package %s:\n%s", pkg, strings.Join(errs, "\n"))
+	}
+	for _, root := range conf.Roots {
+		if !conf.NoFiles && len(d.GoFiles[root]) == 0 {
+			return nil, "", fmt.Errorf("no .go files found for root package %s", root)
+		}
+	}
+	d.normalize()
+	r.recordTiming(pkg, "", "merge", mergeStart)
+	r.recordLoad(conf.Parallel && len(geese) > 1)
+	return d, dir, nil
+}
+
+// allFailed reports whether the root packages loaded for each GOOS all
+// have errors.
+func allFailed(loaded [][]*packages.Package) bool {
+	for _, pkgs := range loaded {
+		for _, p := range pkgs {
+			if len(p.Errors) == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// goEnv returns the environment to run the go command in for goos,
+// followed by extra. Cgo is enabled even when cross-compiling, where
+// it's off by default, so that loading packages and building binaries
+// for goos see the same packages on every host.
+func goEnv(goos string, extra ...string) []string {
+	env := append(os.Environ(), "GOARCH=amd64", "GOOS="+goos, "CGO_ENABLED=1")
+	return append(env, extra...)
+}
+
+// loadGOOS loads pkgs and their dependencies for goos.
+func loadGOOS(pkgs []string, goos string, buildFlags []string, conf loadConfig) ([]*packages.Package, error) {
+	env := goEnv(goos, conf.Env...)
+	mode := packages.NeedImports | packages.NeedDeps | packages.NeedName | packages.NeedModule
+	if !conf.NoFiles {
+		// NeedCompiledGoFiles makes the go command run cgo.
+		mode |= packages.NeedFiles | packages.NeedCompiledGoFiles
 	}
+	cfg := &packages.Config{
+		Mode:       mode,
+		Env:        env,
+		BuildFlags: buildFlags,
+		Dir:        conf.Dir,
+	}
+	return packages.Load(cfg, pkgs...)
+}
+
+// AddPackages adds pkgs, as loaded for pkg (and d.OtherRoots) and goos,
+// and their dependencies to d. It returns the directory of pkg, or the
+// empty string if it has no .go files.
+func (d *deps) AddPackages(pkg, goos string, pkgs []*packages.Package) (dir string) {
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		imps := make([]string, 0, len(p.Imports))
+		for imp := range p.Imports {
+			imps = append(imps, imp)
+		}
+		sort.Strings(imps)
+		for _, imp := range imps {
+			d.AddEdge(p.PkgPath, imp)
+		}
+		if p.Module != nil {
+			d.AddModule(p.PkgPath, p.Module)
+		}
+		d.AddGoFiles(p.PkgPath, p.GoFiles)
+		d.AddOtherFiles(p.PkgPath, p.OtherFiles)
+		if len(p.CompiledGoFiles) > 0 {
+			d.AddCompiledFiles(p.PkgPath, goos, p.CompiledGoFiles)
+		}
+		if p.PkgPath == pkg {
+			if p.Name != "" {
+				d.RootName = p.Name
+			}
+			if dir == "" && len(p.GoFiles) > 0 {
+				dir = packageDir(p)
+			}
+			return
+		}
+		if stringsContains(d.OtherRoots, p.PkgPath) {
+			return
+		}
+		d.AddDep(p.PkgPath, goos)
+	})
+	return dir
+}
+
+// packageDir returns the directory of p, which has Go files: the
+// directory of its import path within its module, if it's in one, so
+// that it doesn't depend on which files the build constraints of the
+// loaded GOOS select, and otherwise the directory of its first Go file.
+func packageDir(p *packages.Package) string {
+	if m := p.Module; m != nil {
+		modDir := m.Dir
+		if m.Replace != nil && m.Replace.Dir != "" {
+			modDir = m.Replace.Dir
+		}
+		if modDir != "" && (p.PkgPath == m.Path || strings.HasPrefix(p.PkgPath, m.Path+"/")) {
+			return filepath.Join(modDir, filepath.FromSlash(strings.TrimPrefix(p.PkgPath, m.Path)))
+		}
+	}
+	return filepath.Dir(p.GoFiles[0])
+}
+
+// normalize sorts the slices in d, so that d doesn't depend on the
+// order in which packages were added.
+func (d *deps) normalize() {
+	sort.Slice(d.Deps, func(i, j int) bool {
+		return depLess(d.Deps[i], d.Deps[j])
+	})
+	for _, m := range []map[string][]string{d.DepTo, d.Imports, d.GoFiles, d.OtherFiles} {
+		for _, v := range m {
+			sort.Strings(v)
+		}
+	}
+	for _, v := range d.CompiledFiles {
+		sort.Strings(v)
+	}
+}
 
-	os.Stdout.Write(buf.Bytes())
+// depLess reports whether dependency d1 sorts before d2 in depaware.txt:
+// third-party packages first, then golang.org/x, then the standard
+// library, each sorted by path.
+func depLess(d1, d2 string) bool {
+	if p1, p2 := strings.Contains(d1, "."), strings.Contains(d2, "."); p1 != p2 {
+		return p1
+	}
+	if x1, x2 := strings.Contains(d1, "golang.org/x/"), strings.Contains(d2, "golang.org/x/"); x1 != x2 {
+		return x2
+	}
+	return d1 < d2
 }
 
 type pkgGOOS struct {
@@ -187,49 +957,119 @@ type deps struct {
 	Deps    []string
 	DepOnOS map[pkgGOOS]bool // {pkg, goos} -> true
 
-	DepTo      map[string][]string // pkg in key is imported by packages in value
-	UsesUnsafe map[string]bool
-	UsesCGO    map[string]bool
+	// RootName is the package name of the root package, such as
+	// "main" for a binary, if it was loaded.
+	RootName string
+
+	// OtherRoots are the root packages loaded along with the one
+	// depaware was run on, for a depaware.txt file that covers several
+	// binaries. They aren't dependencies themselves.
+	OtherRoots []string
+
+	DepTo         map[string][]string // pkg in key is imported by packages in value
+	Imports       map[string][]string // pkg in key imports packages in value
+	UsesUnsafe    map[string]bool
+	UsesCGO       map[string]bool
+	Module        map[string]module.Version // pkg -> module it belongs to; absent for std
+	MainModule    string                    // path of the main module, if any
+	MainModuleDir string                    // root directory of the main module, if any
+	ModuleDirs    map[string]string         // module path -> its directory in the module cache
+	GoFiles       map[string][]string       // pkg -> its .go files for any GOOS
+	OtherFiles    map[string][]string       // pkg -> its non-Go files for any GOOS, such as .c and .syso files
+
+	// CompiledFiles are the files each package is compiled from on
+	// each GOOS, including those generated by cgo.
+	CompiledFiles map[pkgGOOS][]string
 }
 
+// Why returns the "from" column for pkg, preferring the importer named
+// by preferredWhy. See the Why function.
 func (d *deps) Why(pkg string, preferredWhy map[string]string) string {
-	from := d.DepTo[pkg]
-	if len(from) == 0 {
+	return Why(d.DepTo[pkg], preferredWhy[pkg])
+}
+
+// Why returns the "from" column of depaware.txt for a dependency
+// imported by the packages in importers, such as "from net/http+".
+// It's exported so that other tools describing dependencies can
+// produce the same strings as depaware.txt.
+//
+// If preferred is one of importers, it's the one named, which keeps
+// depaware.txt stable when a new importer is added; preferred is
+// typically the importer named by the existing file, if any.
+// Otherwise it's the lexicographically first importer. A "+"
+// suffix means there are more importers. Why returns the empty
+// string if importers is empty. It doesn't modify importers.
+func Why(importers []string, preferred string) string {
+	if len(importers) == 0 {
 		return ""
 	}
 	var why string
-	pref := preferredWhy[pkg]
-	// Check whether the preferred "why" package is in from.
-	if pref != "" {
-		for _, f := range from {
-			if pref == f {
-				why = pref
+	// Check whether the preferred "why" package is in importers.
+	if preferred != "" {
+		for _, f := range importers {
+			if preferred == f {
+				why = preferred
 				break
 			}
 		}
 	}
-	// If it's not select the lexigraphically first package in from.
+	// If it's not select the lexigraphically first importer.
 	if why == "" {
-		sort.Strings(from)
-		why = from[0]
+		why = importers[0]
+		for _, f := range importers[1:] {
+			if f < why {
+				why = f
+			}
+		}
 	}
 	plus := ""
-	if len(from) > 1 {
+	if len(importers) > 1 {
 		plus = "+"
 	}
 	return "from " + why + plus
 }
 
+// Entries returns the depaware.txt entries for d.Deps, in order.
+// The OS letters are computed relative to geese, and preferredWhy is
+// as for Why.
+func (d *deps) Entries(geese []string, preferredWhy map[string]string) []fileEntry {
+	entries := make([]fileEntry, 0, len(d.Deps))
+	var osBuf bytes.Buffer
+	for _, pkg := range d.Deps {
+		e := fileEntry{Pkg: pkg}
+		e.Unsafe = d.UsesUnsafe[pkg] && !isGoPackage(pkg)
+		e.CGO = d.UsesCGO[pkg] && !isGoPackage(pkg)
+		osBuf.Reset()
+		for _, goos := range geese {
+			if d.DepOnOS[pkgGOOS{pkg, goos}] {
+				osBuf.WriteRune(unicode.ToUpper(rune(goos[0])))
+			}
+		}
+		if osBuf.Len() != len(geese) {
+			e.OS = osBuf.String()
+		}
+		if why := d.Why(pkg, preferredWhy); why != "" {
+			why = strings.TrimPrefix(why, "from ")
+			e.Why = strings.TrimSuffix(why, "+")
+			e.More = e.Why != why
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
 func (d *deps) AddEdge(from, to string) {
 	from = imports.VendorlessPath(from)
 	to = imports.VendorlessPath(to)
 	if d.DepTo == nil {
 		d.DepTo = make(map[string][]string)
+		d.Imports = make(map[string][]string)
 		d.UsesUnsafe = make(map[string]bool)
 		d.UsesCGO = make(map[string]bool)
 	}
 	if !stringsContains(d.DepTo[to], from) {
 		d.DepTo[to] = append(d.DepTo[to], from)
+		d.Imports[from] = append(d.Imports[from], to)
 	}
 	if to == "unsafe" {
 		d.UsesUnsafe[from] = true
@@ -239,11 +1079,58 @@ func (d *deps) AddEdge(from, to string) {
 	}
 }
 
-func (d *deps) AddDep(pkg, goos string) {
-	pkg = imports.VendorlessPath(pkg)
-	if !*internal && isInternalPackage(pkg) {
+func (d *deps) AddModule(pkg string, m *packages.Module) {
+	if d.Module == nil {
+		d.Module = make(map[string]module.Version)
+	}
+	d.Module[imports.VendorlessPath(pkg)] = module.Version{Path: m.Path, Version: m.Version}
+	if m.Main {
+		d.MainModule = m.Path
+		d.MainModuleDir = m.Dir
 		return
 	}
+	if m.Replace == nil && m.Dir != "" {
+		if d.ModuleDirs == nil {
+			d.ModuleDirs = make(map[string]string)
+		}
+		d.ModuleDirs[m.Path] = m.Dir
+	}
+}
+
+func (d *deps) AddGoFiles(pkg string, files []string) {
+	pkg = imports.VendorlessPath(pkg)
+	if d.GoFiles == nil {
+		d.GoFiles = make(map[string][]string)
+	}
+	for _, f := range files {
+		if !stringsContains(d.GoFiles[pkg], f) {
+			d.GoFiles[pkg] = append(d.GoFiles[pkg], f)
+		}
+	}
+}
+
+func (d *deps) AddOtherFiles(pkg string, files []string) {
+	pkg = imports.VendorlessPath(pkg)
+	if d.OtherFiles == nil {
+		d.OtherFiles = make(map[string][]string)
+	}
+	for _, f := range files {
+		if !stringsContains(d.OtherFiles[pkg], f) {
+			d.OtherFiles[pkg] = append(d.OtherFiles[pkg], f)
+		}
+	}
+}
+
+func (d *deps) AddCompiledFiles(pkg, goos string, files []string) {
+	pkg = imports.VendorlessPath(pkg)
+	if d.CompiledFiles == nil {
+		d.CompiledFiles = make(map[pkgGOOS][]string)
+	}
+	d.CompiledFiles[pkgGOOS{pkg, goos}] = append([]string(nil), files...)
+}
+
+func (d *deps) AddDep(pkg, goos string) {
+	pkg = imports.VendorlessPath(pkg)
 	if !stringsContains(d.Deps, pkg) {
 		d.Deps = append(d.Deps, pkg)
 	}
@@ -298,11 +1185,14 @@ func pkgPaths(pkg ...string) (ipaths []string, err error) {
 // It returns {"encoding": "encoding/json", "encoding/binary": "encoding/base64"}.
 // The goal is to minimize diffs when introducing a new, lexicographically prior dependency source.
 //
-// parsePreferredWhy is best effort only.
-func parsePreferredWhy(r io.Reader) map[string]string {
+// parsePreferredWhy is best effort only, but it returns an error if
+// the file can't be read, or has lines longer than maxLine bytes, rather
+// than a partial result.
+func parsePreferredWhy(r io.Reader, maxLine int) (map[string]string, error) {
 	m := make(map[string]string)
-	scan := bufio.NewScanner(r)
-	for scan.Scan() {
+	scan := lineScanner(r, maxLine)
+	lineNum := 0
+	for ; scan.Scan(); lineNum++ {
 		words := bytes.Fields(scan.Bytes())
 		// look for the word "from". The preceding and succeeding words are the dependency and its source.
 		from := []byte("from")
@@ -320,5 +1210,5 @@ func parsePreferredWhy(r io.Reader) map[string]string {
 		src = bytes.TrimRight(src, "+")
 		m[string(dep)] = string(src)
 	}
-	return m
+	return m, scanErr(scan, lineNum, maxLine)
 }
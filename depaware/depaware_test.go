@@ -1,9 +1,13 @@
 package depaware
 
 import (
+	"bytes"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+
+	"golang.org/x/tools/go/packages"
 )
 
 func TestParsePreferredWhy(t *testing.T) {
@@ -33,8 +37,167 @@ gibberish and a half
 		"github.com/tailscale/wireguard-go/conn": "github.com/tailscale/wireguard-go/device",
 	}
 
-	got := parsePreferredWhy(strings.NewReader(in))
+	got, err := parsePreferredWhy(strings.NewReader(in), defaultMaxLineBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if !reflect.DeepEqual(want, got) {
 		t.Errorf("want=%v got=%v", want, got)
 	}
 }
+
+func TestParsePreferredWhyLongLines(t *testing.T) {
+	long := strings.Repeat("x", 100<<10)
+	in := "example.com/cmd dependencies: (generated by github.com/tailscale/depaware)\n\n" +
+		"        bytes     from example.com/cmd # " + long + "\n" +
+		"        errors    from bytes\n"
+	got, err := parsePreferredWhy(strings.NewReader(in), defaultMaxLineBytes)
+	if err != nil || got["errors"] != "bytes" {
+		t.Errorf("got %v, %v; want errors from bytes despite a long line", got, err)
+	}
+
+	if _, err := parsePreferredWhy(strings.NewReader(in), 64<<10); err == nil || !strings.Contains(err.Error(), "line 3 is longer than -max-line-bytes") {
+		t.Errorf("got error %v; want line 3 too long", err)
+	}
+}
+
+func TestWhy(t *testing.T) {
+	tests := []struct {
+		importers []string
+		preferred string
+		want      string
+	}{
+		{nil, "", ""},
+		{[]string{"net/http"}, "", "from net/http"},
+		{[]string{"os", "bufio"}, "", "from bufio+"},
+		{[]string{"os", "bufio"}, "os", "from os+"},
+		{[]string{"os", "bufio"}, "gone", "from bufio+"},
+	}
+	for _, tt := range tests {
+		before := append([]string(nil), tt.importers...)
+		if got := Why(tt.importers, tt.preferred); got != tt.want {
+			t.Errorf("Why(%q, %q) = %q; want %q", tt.importers, tt.preferred, got, tt.want)
+		}
+		if !reflect.DeepEqual(before, tt.importers) {
+			t.Errorf("Why modified importers: %q -> %q", before, tt.importers)
+		}
+	}
+}
+
+// testPackages returns a synthetic package graph rooted at
+// example.com/cmd, as loaded by packages.Load for goos.
+func testPackages(goos string) []*packages.Package {
+	pkgs := map[string]*packages.Package{}
+	pkg := func(path string, imports ...string) *packages.Package {
+		p := &packages.Package{
+			PkgPath: path,
+			GoFiles: []string{"/src/" + path + "/a.go", "/src/" + path + "/b.go"},
+			Imports: map[string]*packages.Package{},
+		}
+		for _, imp := range imports {
+			p.Imports[imp] = pkgs[imp]
+		}
+		if strings.Contains(path, ".") {
+			mod := strings.Join(strings.SplitN(path, "/", 4)[:3], "/")
+			p.Module = &packages.Module{Path: mod, Version: "v1.0.0", Main: mod == "example.com/cmd"}
+		}
+		pkgs[path] = p
+		return p
+	}
+	pkg("unsafe")
+	pkg("errors")
+	pkg("io", "errors")
+	pkg("bytes", "errors", "io", "unsafe")
+	pkg("github.com/a/lib", "bytes", "io")
+	osSpecific := "golang.org/x/sys/unix"
+	if goos == "windows" {
+		osSpecific = "golang.org/x/sys/windows"
+	}
+	pkg(osSpecific, "unsafe")
+	pkg("github.com/b/lib", "github.com/a/lib", "errors", osSpecific)
+	return []*packages.Package{pkg("example.com/cmd", "github.com/a/lib", "github.com/b/lib", "bytes")}
+}
+
+func TestDeterministicOutput(t *testing.T) {
+	geese := []string{"linux", "darwin", "windows"}
+	run := func(order []int) (*deps, []byte) {
+		d := new(deps)
+		for _, i := range order {
+			d.AddPackages("example.com/cmd", geese[i], testPackages(geese[i]))
+		}
+		d.normalize()
+		var buf bytes.Buffer
+		writeDepsFile(&buf, &depsFile{Pkg: "example.com/cmd", Entries: d.Entries(geese, nil)})
+		return d, buf.Bytes()
+	}
+	wantDeps, want := run([]int{0, 1, 2})
+	// Map iteration order differs between runs, so run each order a few times.
+	for n := 0; n < 10; n++ {
+		for _, order := range [][]int{{0, 1, 2}, {2, 1, 0}, {1, 2, 0}} {
+			gotDeps, got := run(order)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("output for GOOS order %v differs:\n%s\nwant:\n%s", order, got, want)
+			}
+			if !reflect.DeepEqual(gotDeps, wantDeps) {
+				t.Fatalf("deps for GOOS order %v differ", order)
+			}
+		}
+	}
+	if !bytes.Contains(want, []byte("golang.org/x/sys/windows")) {
+		t.Errorf("unexpected output:\n%s", want)
+	}
+}
+
+func TestPackageDir(t *testing.T) {
+	mod := &packages.Module{Path: "example.com/m", Dir: filepath.FromSlash("/src/m")}
+	tests := []struct {
+		p    *packages.Package
+		want string
+	}{
+		{
+			// A file the go command lists from elsewhere doesn't move the
+			// package.
+			&packages.Package{PkgPath: "example.com/m/cmd/foo", Module: mod, GoFiles: []string{filepath.FromSlash("/src/m/cmd/foo/linux/gen.go")}},
+			"/src/m/cmd/foo",
+		},
+		{
+			&packages.Package{PkgPath: "example.com/m", Module: mod, GoFiles: []string{filepath.FromSlash("/src/m/m.go")}},
+			"/src/m",
+		},
+		{
+			&packages.Package{
+				PkgPath: "example.com/r/lib",
+				Module:  &packages.Module{Path: "example.com/r", Replace: &packages.Module{Path: "../r", Dir: filepath.FromSlash("/src/r")}},
+				GoFiles: []string{filepath.FromSlash("/src/r/lib/lib.go")},
+			},
+			"/src/r/lib",
+		},
+		{
+			&packages.Package{PkgPath: "fmt", GoFiles: []string{filepath.FromSlash("/goroot/src/fmt/print.go")}},
+			"/goroot/src/fmt",
+		},
+	}
+	for _, tt := range tests {
+		if got := packageDir(tt.p); got != filepath.FromSlash(tt.want) {
+			t.Errorf("packageDir(%s) = %q; want %q", tt.p.PkgPath, got, tt.want)
+		}
+	}
+}
+
+func TestGoEnv(t *testing.T) {
+	t.Setenv("CGO_ENABLED", "0")
+	env := goEnv("windows", "GOFLAGS=-mod=mod")
+	// Later values win, so check the last of each.
+	last := make(map[string]string)
+	for _, kv := range env {
+		if i := strings.Index(kv, "="); i > 0 {
+			last[kv[:i]] = kv[i+1:]
+		}
+	}
+	want := map[string]string{"GOOS": "windows", "GOARCH": "amd64", "CGO_ENABLED": "1", "GOFLAGS": "-mod=mod"}
+	for k, v := range want {
+		if last[k] != v {
+			t.Errorf("%s = %q; want %q", k, last[k], v)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package depawaretest is a harness for end-to-end tests of depaware.
+//
+// It sets up temporary modules with synthetic dependency trees, wired
+// together with replace directives (Setup) or served by an in-process
+// module proxy (SetupProxy), so that nothing is fetched from the
+// network, and runs the real depaware command on them.
+//
+// The depaware command runs in a subprocess: the test binary itself,
+// re-executed. To make that work, the test package must call Main from
+// its TestMain function:
+//
+//	func TestMain(m *testing.M) {
+//		depawaretest.Main(m)
+//	}
+package depawaretest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tailscale/depaware/depaware"
+)
+
+// runEnv is the environment variable that makes Main run depaware
+// rather than the tests.
+const runEnv = "DEPAWARETEST_RUN_DEPAWARE"
+
+// Main runs the tests in m, or depaware itself if the test binary was
+// executed by Env.Run. It doesn't return.
+func Main(m *testing.M) {
+	if os.Getenv(runEnv) == "1" {
+		depaware.Main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// A Module is a synthetic module for Setup or NewProxy.
+type Module struct {
+	Path    string // module path
+	Version string // version required by the main module; default "v1.0.0"
+
+	// Require are the module's requirements, mapping module paths to
+	// versions. Setup adds requirements of all its dependencies to the
+	// main module.
+	Require map[string]string
+
+	// Retract are versions retracted by this version of the module, and
+	// Deprecated is its deprecation message, if any. They're only useful
+	// for modules served by a Proxy.
+	Retract    []string
+	Deprecated string
+
+	// Packages maps the import paths of the module's packages to the
+	// packages they import. Each package gets a generated source file
+	// with blank imports of those packages.
+	Packages map[string][]string
+
+	// Files are additional files, keyed by slash-separated name relative
+	// to the module root, such as "foo/foo_windows.go".
+	Files map[string]string
+}
+
+// An Env is a set of temporary modules on disk.
+type Env struct {
+	t   testing.TB
+	Dir string // root directory of the main module
+	env []string
+}
+
+// Setup writes main and deps to a temporary directory, which is
+// removed when the test finishes. The main module requires all of
+// deps, which are replaced by their directories.
+func Setup(t testing.TB, main Module, deps ...Module) *Env {
+	t.Helper()
+	root := tempDir(t)
+	e := &Env{t: t, Dir: filepath.Join(root, "main")}
+	main.Require = copyRequire(main.Require)
+	var replace bytes.Buffer
+	for i, m := range deps {
+		dir := fmt.Sprintf("mod%d", i)
+		main.Require[m.Path] = moduleVersion(m)
+		fmt.Fprintf(&replace, "replace %s => ../%s\n", m.Path, dir)
+		writeModule(t, filepath.Join(root, dir), m, goMod(m))
+	}
+	writeModule(t, e.Dir, main, goMod(main)+replace.String())
+	return e
+}
+
+// SetupProxy writes main to a temporary directory, which is removed
+// when the test finishes. Its requirements are downloaded from p,
+// into a temporary module cache, when depaware runs.
+func SetupProxy(t testing.TB, p *Proxy, main Module) *Env {
+	t.Helper()
+	root := tempDir(t)
+	e := &Env{
+		t:   t,
+		Dir: filepath.Join(root, "main"),
+		env: []string{
+			"GOPROXY=" + p.URL,
+			"GOMODCACHE=" + filepath.Join(root, "modcache"),
+			"GOFLAGS=-mod=mod -modcacherw",
+		},
+	}
+	writeModule(t, e.Dir, main, goMod(main))
+	return e
+}
+
+func tempDir(t testing.TB) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "depawaretest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func copyRequire(req map[string]string) map[string]string {
+	m := make(map[string]string, len(req))
+	for k, v := range req {
+		m[k] = v
+	}
+	return m
+}
+
+// moduleVersion returns the version of m, defaulting to v1.0.0.
+func moduleVersion(m Module) string {
+	if m.Version == "" {
+		return "v1.0.0"
+	}
+	return m.Version
+}
+
+// goMod returns the go.mod file contents of m.
+func goMod(m Module) string {
+	var buf bytes.Buffer
+	if m.Deprecated != "" {
+		fmt.Fprintf(&buf, "// Deprecated: %s\n", m.Deprecated)
+	}
+	goVersion := "1.15"
+	if len(m.Retract) > 0 {
+		goVersion = "1.16" // for retract directives
+	}
+	fmt.Fprintf(&buf, "module %s\n\ngo %s\n", m.Path, goVersion)
+	paths := make([]string, 0, len(m.Require))
+	for p := range m.Require {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		fmt.Fprintf(&buf, "\nrequire %s %s\n", p, m.Require[p])
+	}
+	for _, v := range m.Retract {
+		fmt.Fprintf(&buf, "\nretract %s\n", v)
+	}
+	return buf.String()
+}
+
+// writeModule writes m to dir with the given go.mod contents.
+func writeModule(t testing.TB, dir string, m Module, gomod string) {
+	t.Helper()
+	for name, contents := range moduleFiles(m, gomod) {
+		writeFile(t, filepath.Join(dir, filepath.FromSlash(name)), contents)
+	}
+}
+
+// moduleFiles returns the files of m, keyed by slash-separated name
+// relative to the module root, with the given go.mod contents.
+func moduleFiles(m Module, gomod string) map[string]string {
+	files := map[string]string{"go.mod": gomod}
+	for pkg, imports := range m.Packages {
+		rel := strings.TrimPrefix(strings.TrimPrefix(pkg, m.Path), "/")
+		var src bytes.Buffer
+		fmt.Fprintf(&src, "package %s\n", packageName(pkg))
+		sorted := append([]string(nil), imports...)
+		sort.Strings(sorted)
+		for _, imp := range sorted {
+			fmt.Fprintf(&src, "\nimport _ %q\n", imp)
+		}
+		files[path.Join(rel, packageName(pkg)+".go")] = src.String()
+	}
+	for name, contents := range m.Files {
+		files[name] = contents
+	}
+	return files
+}
+
+// packageName returns the package name used for the package with the
+// given import path: its last element, without dots or dashes, and
+// without a major version suffix.
+func packageName(pkg string) string {
+	base := path.Base(pkg)
+	if len(base) > 1 && base[0] == 'v' && strings.Trim(base[1:], "0123456789") == "" {
+		base = path.Base(path.Dir(pkg))
+	}
+	return strings.NewReplacer(".", "", "-", "").Replace(base)
+}
+
+func writeFile(t testing.TB, name, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// WriteFile writes a file in the main module, creating directories as
+// needed. The name is slash-separated and relative to e.Dir.
+func (e *Env) WriteFile(name, contents string) {
+	e.t.Helper()
+	writeFile(e.t, filepath.Join(e.Dir, filepath.FromSlash(name)), contents)
+}
+
+// ReadFile returns the contents of a file in the main module, or the
+// empty string if it doesn't exist. The name is as for WriteFile.
+func (e *Env) ReadFile(name string) string {
+	e.t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join(e.Dir, filepath.FromSlash(name)))
+	if err != nil && !os.IsNotExist(err) {
+		e.t.Fatal(err)
+	}
+	return string(b)
+}
+
+// Result is the outcome of Env.Run.
+type Result struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Run runs depaware with args in the main module's directory.
+// The go command runs without network access, other than to the
+// Proxy given to SetupProxy.
+func (e *Env) Run(args ...string) Result {
+	e.t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = e.Dir
+	cmd.Env = append(os.Environ(),
+		runEnv+"=1",
+		"GOFLAGS=-mod=mod",
+		"GOPROXY=off",
+		"GOSUMDB=off",
+		"GOWORK=off",
+		"GO111MODULE=on",
+		"GOTOOLCHAIN=local",
+	)
+	cmd.Env = append(cmd.Env, e.env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	res := Result{Stdout: stdout.String(), Stderr: stderr.String()}
+	if err != nil {
+		ee, ok := err.(*exec.ExitError)
+		if !ok {
+			e.t.Fatalf("running depaware %s: %v", strings.Join(args, " "), err)
+		}
+		res.ExitCode = ee.ExitCode()
+	}
+	return res
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depawaretest
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// A Proxy is an in-process module proxy (a GOPROXY server) serving
+// fixture modules, for reproducible tests of version-sensitive behavior
+// such as upgrades, retractions and deprecations.
+type Proxy struct {
+	URL string // to use as GOPROXY
+
+	mods map[string]map[string]Module // path -> version -> module
+}
+
+// NewProxy starts a proxy serving mods, which may include several
+// versions of the same module. The proxy is stopped when the test
+// finishes.
+func NewProxy(t testing.TB, mods ...Module) *Proxy {
+	t.Helper()
+	p := &Proxy{mods: make(map[string]map[string]Module)}
+	for _, m := range mods {
+		m.Version = moduleVersion(m)
+		if p.mods[m.Path] == nil {
+			p.mods[m.Path] = make(map[string]Module)
+		}
+		p.mods[m.Path][m.Version] = m
+	}
+	srv := httptest.NewServer(p)
+	t.Cleanup(srv.Close)
+	p.URL = srv.URL
+	return p
+}
+
+// ServeHTTP implements the GOPROXY protocol: for each module path,
+// "@v/list", and "@v/VERSION.info", ".mod" and ".zip".
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	i := strings.Index(r.URL.Path, "/@v/")
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	modPath, err := module.UnescapePath(strings.TrimPrefix(r.URL.Path[:i], "/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	versions := p.mods[modPath]
+	file := r.URL.Path[i+len("/@v/"):]
+	if file == "list" {
+		var list []string
+		for v := range versions {
+			list = append(list, v)
+		}
+		sort.Slice(list, func(i, j int) bool { return semver.Compare(list[i], list[j]) < 0 })
+		for _, v := range list {
+			fmt.Fprintln(w, v)
+		}
+		return
+	}
+	ext := file[strings.LastIndex(file, ".")+1:]
+	v, err := module.UnescapeVersion(strings.TrimSuffix(file, "."+ext))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	m, ok := versions[v]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch ext {
+	case "info":
+		json.NewEncoder(w).Encode(struct {
+			Version string
+			Time    time.Time
+		}{v, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)})
+	case "mod":
+		w.Write([]byte(goMod(m)))
+	case "zip":
+		b, err := moduleZip(m)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(b)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// moduleZip returns the module zip file for m.
+func moduleZip(m Module) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	prefix := m.Path + "@" + m.Version + "/"
+	files := moduleFiles(m, goMod(m))
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f, err := zw.Create(prefix + name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write([]byte(files[name])); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
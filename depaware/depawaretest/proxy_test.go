@@ -0,0 +1,58 @@
+package depawaretest
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProxy(t *testing.T) {
+	p := NewProxy(t,
+		Module{Path: "github.com/Foo/bar", Version: "v1.1.0", Retract: []string{"v1.0.0"}},
+		Module{Path: "github.com/Foo/bar", Packages: map[string][]string{"github.com/Foo/bar": {"errors"}}},
+	)
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(p.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(b)
+	}
+
+	if _, body := get("/github.com/!foo/bar/@v/list"); body != "v1.0.0\nv1.1.0\n" {
+		t.Errorf("list = %q", body)
+	}
+	if _, body := get("/github.com/!foo/bar/@v/v1.1.0.mod"); !strings.Contains(body, "retract v1.0.0") {
+		t.Errorf("go.mod = %q", body)
+	}
+	if _, body := get("/github.com/!foo/bar/@v/v1.0.0.info"); !strings.Contains(body, `"Version":"v1.0.0"`) {
+		t.Errorf("info = %q", body)
+	}
+	code, body := get("/github.com/!foo/bar/@v/v1.0.0.zip")
+	if code != http.StatusOK {
+		t.Fatalf("zip: status %d", code)
+	}
+	zr, err := zip.NewReader(bytes.NewReader([]byte(body)), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if got, want := strings.Join(names, " "), "github.com/Foo/bar@v1.0.0/bar.go github.com/Foo/bar@v1.0.0/go.mod"; got != want {
+		t.Errorf("zip files = %q; want %q", got, want)
+	}
+	if code, _ := get("/github.com/!foo/bar/@v/v2.0.0.zip"); code != http.StatusNotFound {
+		t.Errorf("missing version: status %d; want 404", code)
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fileEntry is a single dependency line of a depaware.txt file.
+type fileEntry struct {
+	Pkg    string
+	OS     string // OS letters, such as "LW"; empty means all
+	Unsafe bool
+	CGO    bool
+	Why    string // importing package, without the "+" suffix
+	More   bool   // whether Why was followed by a "+"
+
+	// Count is set for module entries, in which case Pkg is a module
+	// path (or "std") and Why is empty. Version is the module's
+	// version; it's empty for the main module and the standard library.
+	Count   int // number of packages in the module
+	Version string
+
+	// OwnInternal marks an internal package of the main module, with
+	// -own-internal=badge.
+	OwnInternal bool
+
+	// Native is the comma-separated list of the kinds of non-Go code in
+	// the entry, as returned by nativeKind, with -native.
+	Native string
+
+	// Symbols is the number of linker symbols the entry contributes to
+	// the binary, with -symbols. Zero means unknown.
+	Symbols int
+
+	// Comment is the text following a "#" at the end of the line, if any.
+	// It's preserved when the file is updated. See annotationValue.
+	Comment string
+}
+
+// depsFile is a parsed depaware.txt file.
+type depsFile struct {
+	Pkg string // package named in the header
+
+	// Directives are the "# key: value" lines following the header,
+	// which record how the file was generated when that differs from
+	// the defaults, such as "granularity: module".
+	Directives map[string]string
+
+	Entries []fileEntry
+
+	// Footer are the "# " lines following the entries, without the
+	// "# " prefix, such as the per-OS summary written with -os-summary.
+	Footer []string
+}
+
+// writeDepsFile writes f in the depaware.txt format to w.
+// It's the inverse of parseDepsFile.
+func writeDepsFile(w io.Writer, f *depsFile) {
+	fmt.Fprintf(w, "%s dependencies: (generated by github.com/tailscale/depaware)\n", f.Pkg)
+	keys := make([]string, 0, len(f.Directives))
+	for k := range f.Directives {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "# %s: %s\n", k, f.Directives[k])
+	}
+	fmt.Fprintln(w)
+	for _, e := range f.Entries {
+		unsafeIcon := " "
+		cgoIcon := " "
+		if e.Unsafe {
+			unsafeIcon = "U"
+		}
+		if e.CGO {
+			cgoIcon = "C"
+		}
+		why := ""
+		if e.Why != "" {
+			why = "from " + e.Why
+			if e.More {
+				why += "+"
+			}
+		}
+		if e.Count > 0 {
+			why = fmt.Sprintf("%d pkgs", e.Count)
+		}
+		if e.OwnInternal {
+			why = strings.TrimPrefix(why+" (internal)", " ")
+		}
+		if e.Native != "" {
+			why = strings.TrimPrefix(fmt.Sprintf("%s (native: %s)", why, e.Native), " ")
+		}
+		if e.Symbols > 0 {
+			why = strings.TrimPrefix(fmt.Sprintf("%s (%d syms)", why, e.Symbols), " ")
+		}
+		name := e.Pkg
+		if e.Version != "" {
+			name += "@" + e.Version
+		}
+		fmt.Fprintf(w, " %3s %s%s %s %s", e.OS, unsafeIcon, cgoIcon, padRight(name, 60), why)
+		if e.Comment != "" {
+			fmt.Fprintf(w, " # %s", e.Comment)
+		}
+		fmt.Fprintln(w)
+	}
+	if len(f.Footer) > 0 {
+		fmt.Fprintln(w)
+		for _, line := range f.Footer {
+			fmt.Fprintf(w, "# %s\n", line)
+		}
+	}
+}
+
+// defaultMaxLineBytes is the default of -max-line-bytes.
+const defaultMaxLineBytes = 16 << 20
+
+// lineScanner returns a scanner of the lines of a depaware.txt file
+// read from r. It allows lines of up to max bytes, the value of
+// -max-line-bytes, rather than bufio's default of 64 KiB, which
+// generated files can exceed.
+func lineScanner(r io.Reader, max int) *bufio.Scanner {
+	scan := bufio.NewScanner(r)
+	scan.Buffer(make([]byte, 0, 64<<10), max)
+	return scan
+}
+
+// scanErr returns the error that stopped scan, a lineScanner of lines
+// of up to max bytes that read lineNum lines successfully, if any.
+func scanErr(scan *bufio.Scanner, lineNum, max int) error {
+	if err := scan.Err(); err == bufio.ErrTooLong {
+		return fmt.Errorf("line %d is longer than -max-line-bytes=%d", lineNum+1, max)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseDepsFile parses a depaware.txt file as written by process.
+// Unlike parsePreferredWhy, it is strict and returns an error for
+// any line it doesn't understand, or longer than maxLine bytes.
+func parseDepsFile(r io.Reader, maxLine int) (*depsFile, error) {
+	f := new(depsFile)
+	scan := lineScanner(r, maxLine)
+	lineNum := 0
+	inHeader := true
+	for scan.Scan() {
+		lineNum++
+		line := scan.Text()
+		if lineNum == 1 {
+			i := strings.Index(line, " dependencies: ")
+			if i < 0 {
+				return nil, fmt.Errorf("line 1: missing depaware header")
+			}
+			f.Pkg = line[:i]
+			continue
+		}
+		if inHeader && strings.HasPrefix(line, "# ") {
+			kv := strings.SplitN(line[2:], ": ", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("line %d: malformed directive %q", lineNum, line)
+			}
+			if f.Directives == nil {
+				f.Directives = make(map[string]string)
+			}
+			f.Directives[kv[0]] = kv[1]
+			continue
+		}
+		inHeader = false
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "# ") {
+			f.Footer = append(f.Footer, line[2:])
+			continue
+		}
+		if len(f.Footer) > 0 {
+			return nil, fmt.Errorf("line %d: entry after footer", lineNum)
+		}
+		e, err := parseFileEntry(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		f.Entries = append(f.Entries, e)
+	}
+	if err := scanErr(scan, lineNum, maxLine); err != nil {
+		return nil, err
+	}
+	if lineNum == 0 {
+		return nil, errors.New("empty file")
+	}
+	return f, nil
+}
+
+// parseDirectives returns the directives of an existing depaware.txt
+// file. Like parsePreferredWhy, it's best effort only and ignores
+// anything that doesn't look like a directive, but it returns an error
+// if the file can't be read or has lines longer than maxLine bytes.
+func parseDirectives(r io.Reader, maxLine int) (map[string]string, error) {
+	m := make(map[string]string)
+	scan := lineScanner(r, maxLine)
+	lineNum := 0
+	for scan.Scan() {
+		lineNum++
+		line := scan.Text()
+		if lineNum == 1 {
+			continue
+		}
+		if !strings.HasPrefix(line, "# ") {
+			return m, nil
+		}
+		if kv := strings.SplitN(line[2:], ": ", 2); len(kv) == 2 {
+			m[kv[0]] = kv[1]
+		}
+	}
+	return m, scanErr(scan, lineNum, maxLine)
+}
+
+// checkSafeToUpdate returns an error if the existing depaware.txt
+// contents look like they're in the middle of being edited, in which
+// case -safe-update doesn't overwrite them. Lines may be up to maxLine
+// bytes long.
+func checkSafeToUpdate(contents []byte, maxLine int) error {
+	for i, line := range strings.Split(string(contents), "\n") {
+		for _, marker := range []string{"<<<<<<<", "|||||||", "=======", ">>>>>>>"} {
+			if strings.HasPrefix(line, marker) {
+				return fmt.Errorf("line %d: unresolved merge conflict", i+1)
+			}
+		}
+	}
+	if _, err := parseDepsFile(bytes.NewReader(contents), maxLine); err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseFileEntry parses a single dependency line. See writeDepsFile for
+// the format.
+func parseFileEntry(line string) (e fileEntry, err error) {
+	if !strings.HasPrefix(line, " ") {
+		return e, fmt.Errorf("malformed entry %q", line)
+	}
+	rest := line[1:]
+	// The OS column is at least three wide, right-aligned.
+	if len(rest) > 3 && rest[3] == ' ' {
+		e.OS = strings.TrimSpace(rest[:3])
+		rest = rest[4:]
+	} else if i := strings.IndexByte(rest, ' '); i > 0 {
+		e.OS = rest[:i]
+		rest = rest[i+1:]
+	} else {
+		return e, fmt.Errorf("malformed entry %q", line)
+	}
+	if len(rest) < 4 || rest[2] != ' ' {
+		return e, fmt.Errorf("malformed entry %q", line)
+	}
+	e.Unsafe = rest[0] == 'U'
+	e.CGO = rest[1] == 'C'
+	rest = rest[3:]
+	if i := strings.Index(rest, " #"); i >= 0 {
+		e.Comment = strings.TrimSpace(rest[i+2:])
+		rest = rest[:i]
+	}
+	words := strings.Fields(rest)
+	if n := len(words); n >= 3 && words[n-1] == "syms)" && strings.HasPrefix(words[n-2], "(") {
+		syms, err := strconv.Atoi(words[n-2][1:])
+		if err != nil || syms <= 0 {
+			return e, fmt.Errorf("malformed symbol count in %q", line)
+		}
+		e.Symbols = syms
+		words = words[:n-2]
+	}
+	if n := len(words); n >= 3 && words[n-2] == "(native:" && strings.HasSuffix(words[n-1], ")") {
+		e.Native = strings.TrimSuffix(words[n-1], ")")
+		words = words[:n-2]
+	}
+	if n := len(words); n >= 2 && words[n-1] == "(internal)" {
+		e.OwnInternal = true
+		words = words[:n-1]
+	}
+	switch {
+	case len(words) == 1:
+	case len(words) == 3 && words[1] == "from":
+		e.Why = strings.TrimSuffix(words[2], "+")
+		e.More = e.Why != words[2]
+	case len(words) == 3 && words[2] == "pkgs":
+		n, err := strconv.Atoi(words[1])
+		if err != nil || n <= 0 {
+			return e, fmt.Errorf("malformed package count in %q", line)
+		}
+		e.Count = n
+		if i := strings.LastIndex(words[0], "@"); i > 0 {
+			words[0], e.Version = words[0][:i], words[0][i+1:]
+		}
+	default:
+		return e, fmt.Errorf("malformed entry %q", line)
+	}
+	e.Pkg = words[0]
+	return e, nil
+}
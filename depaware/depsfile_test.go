@@ -0,0 +1,130 @@
+package depaware
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDepsFile(t *testing.T) {
+	in := `example.com/cmd dependencies: (generated by github.com/tailscale/depaware)
+
+        github.com/pkg/diff                                          from example.com/cmd
+  LW U  github.com/foo/bar                                          from github.com/pkg/diff+
+     UC golang.org/x/sys/unix
+        bytes                                                        from bufio+
+`
+	want := &depsFile{
+		Pkg: "example.com/cmd",
+		Entries: []fileEntry{
+			{Pkg: "github.com/pkg/diff", Why: "example.com/cmd"},
+			{Pkg: "github.com/foo/bar", OS: "LW", Unsafe: true, Why: "github.com/pkg/diff", More: true},
+			{Pkg: "golang.org/x/sys/unix", Unsafe: true, CGO: true},
+			{Pkg: "bytes", Why: "bufio", More: true},
+		},
+	}
+	got, err := parseDepsFile(strings.NewReader(in), defaultMaxLineBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want=%+v got=%+v", want, got)
+	}
+
+	for _, bad := range []string{
+		"",
+		"no header\n",
+		"x dependencies: (generated by github.com/tailscale/depaware)\n\nbytes\n",
+		"x dependencies: (generated by github.com/tailscale/depaware)\n\n        bytes from\n",
+		"x dependencies: (generated by github.com/tailscale/depaware)\n\n# footer\n        bytes\n",
+	} {
+		if _, err := parseDepsFile(strings.NewReader(bad), defaultMaxLineBytes); err == nil {
+			t.Errorf("parseDepsFile(%q, defaultMaxLineBytes) succeeded; want error", bad)
+		}
+	}
+}
+
+func TestDepsFileModulesRoundTrip(t *testing.T) {
+	f := &depsFile{
+		Pkg:        "example.com/cmd",
+		Directives: map[string]string{"granularity": "module"},
+		Entries: []fileEntry{
+			{Pkg: "github.com/foo/bar", Unsafe: true, Count: 17, Version: "v1.2.3"},
+			{Pkg: "golang.org/x/sys", OS: "LD", Count: 2, Version: "v0.0.0-20200101000000-0123456789ab"},
+			{Pkg: "std", Count: 40},
+		},
+		Footer: []string{"linux: 59, darwin: 57"},
+	}
+	var buf bytes.Buffer
+	writeDepsFile(&buf, f)
+	if !strings.Contains(buf.String(), "\n# granularity: module\n\n") {
+		t.Errorf("missing directive in:\n%s", buf.String())
+	}
+	got, err := parseDepsFile(&buf, defaultMaxLineBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f, got) {
+		t.Errorf("want=%+v got=%+v", f, got)
+	}
+}
+
+func TestCheckSafeToUpdate(t *testing.T) {
+	const good = `example.com/cmd dependencies: (generated by github.com/tailscale/depaware)
+
+        bytes                                                        from example.com/cmd
+`
+	if err := checkSafeToUpdate([]byte(good), defaultMaxLineBytes); err != nil {
+		t.Errorf("good file: %v", err)
+	}
+	conflicted := strings.Replace(good, "        bytes", "<<<<<<< HEAD\n        bytes", 1) + "=======\n>>>>>>> branch\n"
+	if err := checkSafeToUpdate([]byte(conflicted), defaultMaxLineBytes); err == nil || !strings.Contains(err.Error(), "merge conflict") {
+		t.Errorf("conflicted file: got %v; want merge conflict error", err)
+	}
+	if err := checkSafeToUpdate([]byte(good+"garbage\n"), defaultMaxLineBytes); err == nil {
+		t.Errorf("unparsable file: got nil error")
+	}
+}
+
+func TestParseDirectives(t *testing.T) {
+	in := `example.com/cmd dependencies: (generated by github.com/tailscale/depaware)
+# granularity: module
+# bogus
+
+        std                                                          40 pkgs
+# not: a directive
+`
+	got, err := parseDirectives(strings.NewReader(in), defaultMaxLineBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"granularity": "module"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestDepsFileSymbolsRoundTrip(t *testing.T) {
+	f := &depsFile{
+		Pkg:        "example.com/cmd",
+		Directives: map[string]string{"symbols": "count"},
+		Entries: []fileEntry{
+			{Pkg: "github.com/foo/bar", Why: "example.com/cmd", More: true, Symbols: 120},
+			{Pkg: "github.com/foo/baz", Count: 3, Version: "v1.0.0", Symbols: 45, Comment: "remove-by:2025-06-30"},
+			{Pkg: "unsafe", Why: "github.com/foo/bar"},
+		},
+	}
+	var buf bytes.Buffer
+	writeDepsFile(&buf, f)
+	if !strings.Contains(buf.String(), "from example.com/cmd+ (120 syms)\n") {
+		t.Errorf("missing symbol count in:\n%s", buf.String())
+	}
+	got, err := parseDepsFile(&buf, defaultMaxLineBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f, got) {
+		t.Errorf("want=%+v got=%+v", f, got)
+	}
+}
@@ -0,0 +1,791 @@
+package depaware_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/tailscale/depaware/depaware/depawaretest"
+)
+
+func TestMain(m *testing.M) {
+	depawaretest.Main(m)
+}
+
+func TestEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t,
+		depawaretest.Module{
+			Path: "example.com/cmd",
+			Packages: map[string][]string{
+				"example.com/cmd": {"github.com/a/lib"},
+			},
+		},
+		depawaretest.Module{
+			Path:    "github.com/a/lib",
+			Version: "v1.2.3",
+			Packages: map[string][]string{
+				"github.com/a/lib":      {"github.com/a/lib/util", "bytes"},
+				"github.com/a/lib/util": {"errors"},
+			},
+		},
+	)
+
+	if res := e.Run("-update", "."); res.ExitCode != 0 {
+		t.Fatalf("-update failed: %+v", res)
+	}
+	got := e.ReadFile("depaware.txt")
+	for _, want := range []string{
+		"example.com/cmd dependencies: (generated by github.com/tailscale/depaware)\n",
+		"        github.com/a/lib                                             from example.com/cmd\n",
+		"        github.com/a/lib/util                                        from github.com/a/lib\n",
+		"        bytes                                                        from github.com/a/lib\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("depaware.txt is missing %q:\n%s", want, got)
+		}
+	}
+	if res := e.Run("-check", "."); res.ExitCode != 0 {
+		t.Fatalf("-check failed after -update: %+v", res)
+	}
+
+	e.WriteFile("cmd.go", "package cmd\n\nimport _ \"github.com/a/lib\"\n\nimport _ \"encoding/json\"\n")
+	res := e.Run("-check", ".")
+	if res.ExitCode != 1 || !strings.Contains(res.Stderr, "out of date") {
+		t.Errorf("-check with new dependency: got %+v; want exit 1", res)
+	}
+	if res := e.Run("-check", "-explain=explain.json", "."); res.ExitCode != 1 {
+		t.Errorf("-check -explain with new dependency: got %+v; want exit 1", res)
+	}
+	if got := e.ReadFile("explain.json"); !strings.Contains(got, `"package": "encoding/json"`) {
+		t.Errorf("explain.json doesn't explain the new dependency:\n%s", got)
+	}
+	res = e.Run("-check", "-diff-output=deps.patch", "-explain=stdout", ".")
+	if res.ExitCode != 1 || !strings.Contains(res.Stderr, "depaware.txt is out of date: ") || !strings.Contains(res.Stderr, "; diff in deps.patch.") {
+		t.Errorf("-check -diff-output: got %+v; want exit 1 with a summary on stderr", res)
+	}
+	if !strings.Contains(res.Stdout, `"package": "encoding/json"`) {
+		t.Errorf("-explain=stdout doesn't explain the new dependency:\n%s", res.Stdout)
+	}
+	if res := e.Run("."); !strings.Contains(res.Stdout, "encoding/json") {
+		t.Errorf("new dependency missing from output:\n%s", res.Stdout)
+	}
+}
+
+func TestEndToEndProxy(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	lib := func(version string, imports ...string) depawaretest.Module {
+		return depawaretest.Module{
+			Path:     "github.com/a/lib",
+			Version:  version,
+			Packages: map[string][]string{"github.com/a/lib": imports},
+		}
+	}
+	p := depawaretest.NewProxy(t, lib("v1.0.0", "errors"), lib("v1.1.0", "errors", "bytes"))
+	e := depawaretest.SetupProxy(t, p, depawaretest.Module{
+		Path:     "example.com/cmd",
+		Require:  map[string]string{"github.com/a/lib": "v1.1.0"},
+		Packages: map[string][]string{"example.com/cmd": {"github.com/a/lib"}},
+	})
+	res := e.Run("-granularity=hybrid", ".")
+	if res.ExitCode != 0 {
+		t.Fatalf("depaware failed: %+v", res)
+	}
+	for _, want := range []string{"github.com/a/lib@v1.1.0", "bytes"} {
+		if !strings.Contains(res.Stdout, want) {
+			t.Errorf("output is missing %q:\n%s", want, res.Stdout)
+		}
+	}
+}
+
+func TestEndToEndHints(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path:  "example.com/ios",
+		Files: map[string]string{"ios.go": "//go:build ios\n// +build ios\n\npackage ios\n"},
+	})
+	res := e.Run("-goos=windows", ".")
+	if res.ExitCode == 0 || !strings.Contains(res.Stderr, "hint: ") {
+		t.Errorf("got %+v; want failure with a hint", res)
+	}
+
+	e = depawaretest.Setup(t, depawaretest.Module{
+		Path:     "example.com/cmd",
+		Packages: map[string][]string{"example.com/cmd": {"bytes"}},
+	})
+	res = e.Run("-check", ".")
+	if res.ExitCode == 0 || !strings.Contains(res.Stderr, "run 'depaware -update example.com/cmd'") {
+		t.Errorf("-check without depaware.txt: got %+v; want failure with a hint", res)
+	}
+}
+
+func TestEndToEndPartialFailure(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path: "example.com/m",
+		Packages: map[string][]string{
+			"example.com/m/a": {"bytes"},
+			"example.com/m/b": {"errors"},
+			"example.com/m/c": {"io"},
+		},
+	})
+	if res := e.Run("-update", "./..."); res.ExitCode != 0 {
+		t.Fatalf("-update failed: %+v", res)
+	}
+	e.WriteFile("a/depaware.txt", "")
+	e.WriteFile("c/c.go", "package c\n\nimport _ \"os\"\n")
+	res := e.Run("-check", "./...")
+	if res.ExitCode != 1 {
+		t.Errorf("exit code = %d; want 1", res.ExitCode)
+	}
+	for _, want := range []string{"a/depaware.txt is out of date", "c/depaware.txt is out of date", "2 of 3 packages failed:\n\texample.com/m/a\n\texample.com/m/c\n"} {
+		if !strings.Contains(res.Stderr, want) {
+			t.Errorf("stderr is missing %q:\n%s", want, res.Stderr)
+		}
+	}
+}
+
+func TestEndToEndSizes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path:  "example.com/cmd",
+		Files: map[string]string{"main.go": "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println() }\n"},
+	})
+	if res := e.Run("-update", "-sizes", "-goos=linux", "."); res.ExitCode != 0 {
+		t.Fatalf("-update -sizes failed: %+v", res)
+	}
+	if got := e.ReadFile("depaware.sizes"); !strings.Contains(got, " fmt\n") {
+		t.Errorf("depaware.sizes has no size for fmt:\n%s", got)
+	}
+	if res := e.Run("-check", "-sizes", "-goos=linux", "."); res.ExitCode != 0 {
+		t.Errorf("-check -sizes failed: %+v", res)
+	}
+	if res := e.Run("-format=treemap", "-sizes", "-goos=linux", "."); res.ExitCode != 0 || !regexp.MustCompile(`<title>fmt: \d+ bytes</title>`).MatchString(res.Stdout) {
+		t.Errorf("-format=treemap -sizes: got %+v; want fmt sized in bytes", res)
+	}
+	// Sizes for another GOOS can't be compared.
+	if res := e.Run("-check", "-sizes", "-goos=darwin,linux", "."); res.ExitCode == 0 || !strings.Contains(res.Stderr, "sizes are for GOOS=linux, but -check attributes them for GOOS=darwin") {
+		t.Errorf("-check -sizes with another GOOS first: got %+v; want failure", res)
+	}
+}
+
+func TestEndToEndSymbols(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path:  "example.com/cmd",
+		Files: map[string]string{"main.go": "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println() }\n"},
+	})
+	if res := e.Run("-update", "-symbols", "-goos=linux", "."); res.ExitCode != 0 {
+		t.Fatalf("-update -symbols failed: %+v", res)
+	}
+	if got := e.ReadFile("depaware.txt"); !strings.Contains(got, "# symbols: count on linux\n") {
+		t.Errorf("depaware.txt doesn't record the GOOS of the symbol counts:\n%s", got)
+	}
+	if res := e.Run("-check", "-goos=linux", "."); res.ExitCode != 0 {
+		t.Errorf("-check failed: %+v", res)
+	}
+	if res := e.Run("-check", "-goos=darwin,linux", "."); res.ExitCode == 0 || !strings.Contains(res.Stderr, "symbol counts are for GOOS=linux, but -check attributes them for GOOS=darwin") {
+		t.Errorf("-check with another GOOS first: got %+v; want failure", res)
+	}
+}
+
+func TestEndToEndSymbolsCrossGOOS(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	goos := "windows"
+	if runtime.GOOS == goos {
+		goos = "linux"
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path:  "example.com/cmd",
+		Files: map[string]string{"main.go": "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println() }\n"},
+	})
+	if res := e.Run("-update", "-symbols", "-goos="+goos, "."); res.ExitCode != 0 {
+		t.Fatalf("-update -symbols -goos=%s failed: %+v", goos, res)
+	}
+	got := e.ReadFile("depaware.txt")
+	if !strings.Contains(got, "# symbols: count on "+goos+"\n") || !regexp.MustCompile(`(?m)^ +fmt +from example\.com/cmd \(\d+ syms\)$`).MatchString(got) {
+		t.Errorf("depaware.txt doesn't count symbols for GOOS=%s:\n%s", goos, got)
+	}
+	if res := e.Run("-check", "-goos="+goos, "."); res.ExitCode != 0 {
+		t.Errorf("-check failed: %+v", res)
+	}
+}
+
+func TestEndToEndNoSyso(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t,
+		depawaretest.Module{
+			Path:     "example.com/cmd",
+			Packages: map[string][]string{"example.com/cmd": {"github.com/a/rsrc"}},
+		},
+		depawaretest.Module{
+			Path:     "github.com/a/rsrc",
+			Packages: map[string][]string{"github.com/a/rsrc": nil},
+			Files:    map[string]string{"rsrc_linux_amd64.syso": ""},
+		},
+	)
+	if res := e.Run("-update", "-goos=linux", "."); res.ExitCode != 0 {
+		t.Fatalf("-update failed: %+v", res)
+	}
+	if res := e.Run("-check", "-goos=linux", "."); res.ExitCode != 0 {
+		t.Fatalf("-check failed: %+v", res)
+	}
+	res := e.Run("-check", "-no-syso", "-goos=linux", ".")
+	if res.ExitCode != 1 || !strings.Contains(res.Stderr, "github.com/a/rsrc has .syso files (rsrc_linux_amd64.syso)") {
+		t.Errorf("-check -no-syso: got %+v; want failure naming the .syso file", res)
+	}
+}
+
+func TestEndToEndVerifyCache(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	p := depawaretest.NewProxy(t, depawaretest.Module{
+		Path:     "github.com/a/lib",
+		Packages: map[string][]string{"github.com/a/lib": {"errors"}},
+	})
+	e := depawaretest.SetupProxy(t, p, depawaretest.Module{
+		Path:     "example.com/cmd",
+		Require:  map[string]string{"github.com/a/lib": "v1.0.0"},
+		Packages: map[string][]string{"example.com/cmd": {"github.com/a/lib"}},
+	})
+	if res := e.Run("-verify-cache=-1", "-goos=linux", "."); res.ExitCode != 0 || strings.Contains(res.Stderr, "module cache") {
+		t.Fatalf("-verify-cache with intact cache: %+v", res)
+	}
+
+	cached := filepath.Join(filepath.Dir(e.Dir), "modcache", "github.com", "a", "lib@v1.0.0", "lib.go")
+	if err := ioutil.WriteFile(cached, []byte("package lib\n\nimport _ \"errors\"\n\n// tampered\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	res := e.Run("-verify-cache=-1", "-goos=linux", ".")
+	if !strings.Contains(res.Stderr, "github.com/a/lib@v1.0.0: module cache directory") {
+		t.Errorf("-verify-cache with tampered cache: got %+v; want a hash mismatch", res)
+	}
+}
+
+func TestEndToEndReach(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t,
+		depawaretest.Module{
+			Path: "example.com/cmd",
+			Files: map[string]string{
+				"main.go": "package main\n\nimport \"github.com/a/lib\"\n\nfunc main() { run() }\n\nfunc run() { lib.Used() }\n",
+			},
+		},
+		depawaretest.Module{
+			Path: "github.com/a/lib",
+			Files: map[string]string{
+				"lib.go": "package lib\n\nfunc Used() { var t T; t.Read() }\n\nfunc Unused() {}\n\ntype T struct{}\n\nfunc (*T) Read() {}\n",
+			},
+		},
+	)
+	tests := []struct {
+		pkg, sym string
+		want     string
+	}{
+		{"github.com/a/lib", "T.Read", "is reachable from . on GOOS=linux:\n\texample.com/cmd.main\n\texample.com/cmd.run\n\tgithub.com/a/lib.Used\n\t(*github.com/a/lib.T).Read\n"},
+		{"github.com/a/lib", "Unused", "is not reachable from . on GOOS=linux: github.com/a/lib is imported, but the symbol isn't called"},
+		{"github.com/a/other", "F", "github.com/a/other isn't imported"},
+	}
+	for _, tt := range tests {
+		res := e.Run("-goos=linux", "reach", ".", tt.pkg, tt.sym)
+		if res.ExitCode != 0 || !strings.Contains(res.Stdout, tt.want) {
+			t.Errorf("reach %s %s: got %+v; want stdout containing %q", tt.pkg, tt.sym, res, tt.want)
+		}
+	}
+}
+
+func TestEndToEndSharedFile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path: "example.com/cmd",
+		Packages: map[string][]string{
+			"example.com/cmd/a": {"errors"},
+			"example.com/cmd/b": {"strings"},
+		},
+		// A package only for windows, which has no files when loaded
+		// for other hosts.
+		Files: map[string]string{"c/c_windows.go": "package c\n\nimport _ \"bytes\"\n"},
+	})
+	if res := e.Run("-update", "-goos=windows", "-file=../depaware.txt", "./c"); res.ExitCode != 0 {
+		t.Fatalf("-update of a windows-only package failed: %+v", res)
+	}
+	if c := e.ReadFile("depaware.c.txt"); !strings.Contains(c, "bytes") {
+		t.Errorf("depaware.c.txt = %q; want c's dependencies", c)
+	}
+	if res := e.Run("-update", "-goos=linux", "-file=../depaware.txt", "./a", "./b"); res.ExitCode != 0 {
+		t.Fatalf("-update failed: %+v", res)
+	}
+	if a := e.ReadFile("depaware.a.txt"); !strings.Contains(a, "errors") || strings.Contains(a, "strings") {
+		t.Errorf("depaware.a.txt = %q; want only a's dependencies", a)
+	}
+	if b := e.ReadFile("depaware.b.txt"); !strings.Contains(b, "strings") {
+		t.Errorf("depaware.b.txt = %q; want b's dependencies", b)
+	}
+	// Each package's file is the same on its own.
+	if res := e.Run("-check", "-goos=linux", "-file=../depaware.txt", "./a"); res.ExitCode != 0 {
+		t.Errorf("-check of one package failed: %+v", res)
+	}
+}
+
+func TestEndToEndOutputTemplate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path: "example.com/cmd",
+		Packages: map[string][]string{
+			"example.com/cmd/a": {"errors"},
+			"example.com/cmd/b": {"strings"},
+		},
+	})
+	const tmpl = "-output-template=deps/{{.ImportPath}}.txt"
+	if res := e.Run("-update", "-goos=linux", tmpl, "./..."); res.ExitCode != 0 {
+		t.Fatalf("-update failed: %+v", res)
+	}
+	for _, name := range []string{"deps/example.com/cmd/a.txt", "deps/example.com/cmd/b.txt"} {
+		if !strings.Contains(e.ReadFile(name), "generated by github.com/tailscale/depaware") {
+			t.Errorf("%s wasn't written", name)
+		}
+	}
+	if res := e.Run("-check", "-goos=linux", tmpl, "./..."); res.ExitCode != 0 {
+		t.Errorf("-check failed: %+v", res)
+	}
+
+	res := e.Run("-goos=linux", "./b", "./a")
+	ia, ib := strings.Index(res.Stdout, "==> a/depaware.txt <==\n"), strings.Index(res.Stdout, "\n\n==> b/depaware.txt <==\n")
+	if res.ExitCode != 0 || ia != 0 || ib < 0 {
+		t.Errorf("printing two packages: got %+v; want a header before each, in order", res)
+	}
+}
+
+func TestEndToEndRecordVersion(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path:     "example.com/cmd",
+		Packages: map[string][]string{"example.com/cmd": {"errors"}},
+	})
+	if res := e.Run("-update", "-goos=linux", "-record-version", "."); res.ExitCode != 0 {
+		t.Fatalf("-update failed: %+v", res)
+	}
+	got := e.ReadFile("depaware.txt")
+	i := strings.Index(got, "# depaware-version: ")
+	if i < 0 {
+		t.Fatalf("depaware.txt doesn't record the version:\n%s", got)
+	}
+	line := got[i : i+strings.Index(got[i:], "\n")]
+	e.WriteFile("depaware.txt", strings.Replace(got, line, "# depaware-version: v99.0.0", 1))
+	res := e.Run("-check", "-goos=linux", ".")
+	if res.ExitCode != 0 || !strings.Contains(res.Stderr, "was generated by depaware v99.0.0") || !strings.Contains(res.Stderr, "stray") {
+		t.Errorf("-check of a file from a newer depaware: got %+v; want success with a warning", res)
+	}
+	if res := e.Run("-update", "-goos=linux", "."); res.ExitCode != 0 || !strings.Contains(e.ReadFile("depaware.txt"), line+"\n") {
+		t.Errorf("-update didn't keep recording the version: %+v\n%s", res, e.ReadFile("depaware.txt"))
+	}
+}
+
+func TestEndToEndCompareIgnore(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path:     "example.com/cmd",
+		Packages: map[string][]string{"example.com/cmd": {"errors", "example.com/flaky"}},
+	}, depawaretest.Module{
+		Path:     "example.com/flaky",
+		Packages: map[string][]string{"example.com/flaky": nil},
+	})
+	if res := e.Run("-update", "-goos=linux", "-compare-ignore=example.com/flaky/...", "."); res.ExitCode != 0 {
+		t.Fatalf("-update failed: %+v", res)
+	}
+	got := e.ReadFile("depaware.txt")
+	if !strings.Contains(got, "# compare-ignore: example.com/flaky/...\n") || !strings.Contains(got, "example.com/flaky ") {
+		t.Fatalf("depaware.txt doesn't record the pattern or list the dependency:\n%s", got)
+	}
+	e.WriteFile("main.go", "package cmd\n\nimport _ \"errors\"\n")
+	e.WriteFile("cmd.go", "package cmd\n")
+	res := e.Run("-check", "-goos=linux", ".")
+	if res.ExitCode != 0 || !strings.Contains(res.Stderr, "-example.com/flaky") {
+		t.Errorf("-check without an ignored dependency: got %+v; want success with a note", res)
+	}
+	e.WriteFile("main.go", "package cmd\n\nimport _ \"os\"\n")
+	if res := e.Run("-check", "-goos=linux", "."); res.ExitCode == 0 {
+		t.Errorf("-check with a new dependency that isn't ignored succeeded: %+v", res)
+	}
+}
+
+func TestEndToEndBlankImports(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path:     "example.com/cmd",
+		Packages: map[string][]string{"example.com/cmd": {"errors", "example.com/driver"}},
+	}, depawaretest.Module{
+		Path: "example.com/driver",
+		Packages: map[string][]string{
+			"example.com/driver":      {"example.com/driver/wire"},
+			"example.com/driver/wire": nil,
+		},
+	})
+	res := e.Run("-goos=linux", "-blank-imports=2", ".")
+	if res.ExitCode != 0 || !strings.Contains(res.Stderr, "blank import of example.com/driver at cmd.go:5 pulls in 2 dependencies") {
+		t.Errorf("got %+v; want a warning about example.com/driver", res)
+	}
+	e.WriteFile("depaware.policy", "declare-blank-imports 2\n")
+	if res := e.Run("-goos=linux", "-policy=depaware.policy", "-update", "."); res.ExitCode != 0 {
+		t.Fatalf("-update failed: %+v", res)
+	}
+	if res := e.Run("-goos=linux", "-policy=depaware.policy", "-check", "."); res.ExitCode == 0 || !strings.Contains(res.Stderr, "example.com/driver at cmd.go:5 pulls in 2 dependencies that nothing else imports (2 packages transitively), and isn't declared") {
+		t.Errorf("-check with an undeclared blank import: got %+v; want a violation", res)
+	}
+	e.WriteFile("depaware.policy", "declare-blank-imports 2\nallow-blank-import errors\nallow-blank-import example.com/driver\n")
+	if res := e.Run("-goos=linux", "-policy=depaware.policy", "-check", "."); res.ExitCode != 0 {
+		t.Errorf("-check with a declared blank import failed: %+v", res)
+	}
+}
+
+func TestEndToEndNoPlugin(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path:     "example.com/cmd",
+		Packages: map[string][]string{"example.com/cmd": {"example.com/loader"}},
+	}, depawaretest.Module{
+		Path:     "example.com/loader",
+		Packages: map[string][]string{"example.com/loader": {"plugin"}},
+	})
+	res := e.Run("-update", "-goos=linux", ".")
+	if res.ExitCode != 0 || !strings.Contains(res.Stderr, "depends on the plugin package") {
+		t.Fatalf("-update: got %+v; want success with a warning", res)
+	}
+	res = e.Run("-check", "-no-plugin", "-goos=linux", ".")
+	if res.ExitCode != 1 || !strings.Contains(res.Stderr, "imported via example.com/cmd -> example.com/loader -> plugin") {
+		t.Errorf("-check -no-plugin: got %+v; want failure with the import chain", res)
+	}
+}
+
+func TestEndToEndConfig(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path:     "example.com/cmd",
+		Packages: map[string][]string{"example.com/cmd": {"errors"}},
+	})
+	if res := e.Run("-update", "-goos=linux", "-granularity=module", "."); res.ExitCode != 0 {
+		t.Fatalf("-update failed: %+v", res)
+	}
+	res := e.Run("-check", "config", ".")
+	if res.ExitCode != 0 || !regexp.MustCompile(`(?m)^-granularity +module +.*depaware\.txt$`).MatchString(res.Stdout) ||
+		!regexp.MustCompile(`(?m)^-check +true +flag$`).MatchString(res.Stdout) {
+		t.Errorf("config: got %+v; want -granularity from depaware.txt and -check from a flag", res)
+	}
+	res = e.Run("-goos=linux,darwin,windows", "-tags=foo", "config", ".")
+	if res.ExitCode != 0 || !regexp.MustCompile(`(?m)^-goos +linux,darwin,windows +flag$`).MatchString(res.Stdout) {
+		t.Errorf("config with -goos at its default: got %+v; want -goos from a flag", res)
+	}
+}
+
+func TestEndToEndConfigTags(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path:  "example.com/cmd",
+		Files: map[string]string{"main.go": "//go:build foo\n\npackage main\n\nfunc main() {}\n"},
+	})
+	if res := e.Run("-goos=linux", "config", "."); res.ExitCode == 0 {
+		t.Errorf("config of a package without files for the build: got %+v; want failure", res)
+	}
+	if res := e.Run("-goos=linux", "-tags=foo", "config", "."); res.ExitCode != 0 || !strings.Contains(res.Stdout, "depaware.txt") {
+		t.Errorf("config with -tags: got %+v; want it to find the package", res)
+	}
+}
+
+func TestEndToEndRoots(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path: "example.com/cmd",
+		Packages: map[string][]string{
+			"example.com/cmd":       {"errors"},
+			"example.com/cmd/debug": {"example.com/cmd/trace"},
+			"example.com/cmd/trace": nil,
+		},
+	})
+	if res := e.Run("-update", "-goos=linux", "-roots=./debug", "."); res.ExitCode != 0 {
+		t.Fatalf("-update failed: %+v", res)
+	}
+	got := e.ReadFile("depaware.txt")
+	if !strings.Contains(got, "# roots: example.com/cmd,example.com/cmd/debug\n") ||
+		!strings.Contains(got, "example.com/cmd/trace") || !strings.Contains(got, "errors ") ||
+		strings.Contains(got, "example.com/cmd/debug ") {
+		t.Fatalf("depaware.txt doesn't cover both roots:\n%s", got)
+	}
+	// Later runs take the roots from the file.
+	if res := e.Run("-check", "-goos=linux", "."); res.ExitCode != 0 {
+		t.Errorf("-check without -roots: %+v", res)
+	}
+	e.WriteFile("debug/debug.go", "package debug\n")
+	if res := e.Run("-check", "-goos=linux", "."); res.ExitCode == 0 || !strings.Contains(res.Stderr, "-        example.com/cmd/trace") {
+		t.Errorf("-check after the other root dropped a dependency: got %+v; want failure", res)
+	}
+}
+
+func TestEndToEndRequireMain(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path:     "example.com/cmd",
+		Packages: map[string][]string{"example.com/cmd": {"errors"}},
+	})
+	res := e.Run("-goos=linux", ".")
+	if res.ExitCode != 0 || !strings.Contains(res.Stderr, "is package cmd, not a main package") {
+		t.Errorf("library: got %+v; want success with a notice", res)
+	}
+	if res := e.Run("-goos=linux", "-update", "-require-main", "."); res.ExitCode == 0 || !strings.Contains(res.Stderr, "not a main package") {
+		t.Errorf("library with -require-main: got %+v; want failure", res)
+	}
+	e.WriteFile("cmd.go", "package main\n\nimport _ \"errors\"\n\nfunc main() {}\n")
+	if res := e.Run("-goos=linux", "-require-main", "."); res.ExitCode != 0 || strings.Contains(res.Stderr, "main package") {
+		t.Errorf("main package with -require-main: got %+v; want success without a notice", res)
+	}
+}
+
+func TestEndToEndNewDepNotes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path: "example.com/cmd",
+		Packages: map[string][]string{
+			"example.com/cmd":      {"errors"},
+			"example.com/cmd/util": nil,
+		},
+	})
+	if res := e.Run("-update", "-goos=linux", "."); res.ExitCode != 0 {
+		t.Fatalf("-update failed: %+v", res)
+	}
+	e.WriteFile("cmd.go", "package cmd\n\nimport (\n\t_ \"errors\"\n\t_ \"example.com/cmd/util\"\n)\n")
+	res := e.Run("-check", "-goos=linux", ".")
+	if res.ExitCode == 0 || !regexp.MustCompile(`\+ +example.com/cmd/util +from example.com/cmd  \(1 transitive pkgs, pulled by example.com/cmd\)`).MatchString(res.Stderr) {
+		t.Errorf("-check with a new dependency: got %+v; want a diff with a note on it", res)
+	}
+	// Patches go unannotated, so that they apply.
+	if res := e.Run("-check", "-goos=linux", "-diff-output=stdout", "."); res.ExitCode == 0 || !strings.Contains(res.Stdout, "example.com/cmd/util") || strings.Contains(res.Stdout, "pulled by") {
+		t.Errorf("-check -diff-output=stdout: got %+v; want a plain patch", res)
+	}
+}
+
+func TestEndToEndStatsFile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path:     "example.com/cmd",
+		Packages: map[string][]string{"example.com/cmd": {"errors"}},
+	})
+	stats := filepath.Join(t.TempDir(), "stats.jsonl")
+	if res := e.Run("-stats-file="+stats, "-update", "-goos=linux", "."); res.ExitCode != 0 {
+		t.Fatalf("-update failed: %+v", res)
+	}
+	if res := e.Run("-stats-file="+stats, "-check", "-goos=linux", "."); res.ExitCode != 0 {
+		t.Fatalf("-check failed: %+v", res)
+	}
+	res := e.Run("-stats-file="+stats, "stats", "-self")
+	if res.ExitCode != 0 || !strings.Contains(res.Stdout, "2 runs from") ||
+		!regexp.MustCompile(`(?m)^check +1 +0 `).MatchString(res.Stdout) ||
+		!strings.Contains(res.Stdout, "import graphs: 2 loaded, 0 read from snapshots") {
+		t.Errorf("stats -self: got %+v; want the update and check runs", res)
+	}
+}
+
+func TestEndToEndReleaseNotes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t,
+		depawaretest.Module{
+			Path:     "example.com/cmd",
+			Packages: map[string][]string{"example.com/cmd": {"github.com/a/lib"}},
+		},
+		depawaretest.Module{Path: "github.com/a/lib", Packages: map[string][]string{"github.com/a/lib": nil}},
+		depawaretest.Module{Path: "github.com/b/graphonly", Packages: map[string][]string{"github.com/b/graphonly": nil}},
+		depawaretest.Module{Path: "github.com/c/added", Packages: map[string][]string{"github.com/c/added": nil}},
+	)
+	// The repository holds the replacement modules too, next to the
+	// main one.
+	root := filepath.Dir(e.Dir)
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = root
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "old")
+	git("tag", "old")
+	// A requirement bump of a module that no package imports isn't a
+	// change to the binary.
+	gomod := strings.Replace(e.ReadFile("go.mod"), "github.com/b/graphonly v1.0.0", "github.com/b/graphonly v1.5.0", 1)
+	e.WriteFile("go.mod", gomod)
+	e.WriteFile("cmd.go", "package cmd\n\nimport (\n\t_ \"github.com/a/lib\"\n\t_ \"github.com/c/added\"\n)\n")
+	git("commit", "-q", "-a", "-m", "new")
+
+	res := e.Run("-goos=linux", "release-notes", "old", "HEAD")
+	want := "Third-party updates:\n\n* Added github.com/c/added => ../mod2\n"
+	if res.ExitCode != 0 || res.Stdout != want {
+		t.Errorf("release-notes: got %+v; want stdout %q", res, want)
+	}
+}
+
+func TestEndToEndGitConfig(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path:     "example.com/cmd",
+		Packages: map[string][]string{"example.com/cmd": {"errors"}},
+	})
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = e.Dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return string(out)
+	}
+	git("init", "-q")
+	// git runs the test binary as depaware.
+	self, err := filepath.Abs(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	depaware := "env DEPAWARETEST_RUN_DEPAWARE=1 " + self
+	for i := 0; i < 2; i++ {
+		if res := e.Run("git-config", "install", "-cmd="+depaware); res.ExitCode != 0 {
+			t.Fatalf("git-config install #%d: %+v", i+1, res)
+		}
+	}
+	if got, want := e.ReadFile(".gitattributes"), "depaware.txt diff=depaware merge=depaware\n"; got != want {
+		t.Errorf(".gitattributes after installing twice = %q; want %q", got, want)
+	}
+	for key, want := range map[string]string{
+		"diff.depaware.command": depaware + " git-diff\n",
+		"merge.depaware.driver": depaware + " merge %O %A %B\n",
+	} {
+		if got := git("config", "--get-all", key); got != want {
+			t.Errorf("%s = %q; want %q", key, got, want)
+		}
+	}
+	if res := e.Run("-goos=linux", "git-config", "install", "-cmd="+depaware); res.ExitCode != 0 {
+		t.Fatalf("git-config install -goos=linux: %+v", res)
+	}
+	if got, want := git("config", "--get-all", "merge.depaware.driver"), depaware+" -goos=linux merge %O %A %B\n"; got != want {
+		t.Errorf("merge.depaware.driver with -goos = %q; want %q", got, want)
+	}
+
+	// git diff runs the installed driver.
+	if res := e.Run("-update", "-goos=linux", "."); res.ExitCode != 0 {
+		t.Fatalf("-update failed: %+v", res)
+	}
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	e.WriteFile("cmd.go", "package cmd\n\nimport _ \"errors\"\n\nimport _ \"unicode/utf8\"\n")
+	if res := e.Run("-update", "-goos=linux", "."); res.ExitCode != 0 {
+		t.Fatalf("-update failed: %+v", res)
+	}
+	got := git("diff", "--", "depaware.txt")
+	if !strings.Contains(got, "depaware changes in depaware.txt:\n* Added unicode/utf8 (from example.com/cmd)\n") {
+		t.Errorf("git diff with the depaware driver:\n%s", got)
+	}
+}
+
+func TestEndToEndSelftest(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path: "example.com/cmd",
+		Packages: map[string][]string{
+			"example.com/cmd":       {"errors"},
+			"example.com/cmd/other": {"strconv"},
+		},
+	})
+	if res := e.Run("-update", "-goos=linux", "."); res.ExitCode != 0 {
+		t.Fatalf("-update failed: %+v", res)
+	}
+	cmd := exec.Command("git", "init", "-q")
+	cmd.Dir = e.Dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	if res := e.Run("-goos=linux", "selftest"); res.ExitCode == 0 || !strings.Contains(res.Stderr, "no depaware.txt files tracked by git") {
+		t.Errorf("selftest without tracked files: got %+v; want failure", res)
+	}
+	cmd = exec.Command("git", "add", "depaware.txt")
+	cmd.Dir = e.Dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git add: %v\n%s", err, out)
+	}
+	// Only the package with a tracked depaware.txt file is tested.
+	if res := e.Run("-goos=linux", "selftest"); res.ExitCode != 0 || res.Stdout != "ok\texample.com/cmd\n" {
+		t.Errorf("selftest: got %+v; want example.com/cmd ok", res)
+	}
+}
+
+func TestEndToEndInternal(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e := depawaretest.Setup(t, depawaretest.Module{
+		Path:  "example.com/cmd",
+		Files: map[string]string{"main.go": "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println() }\n"},
+	})
+	if res := e.Run("-update", "-internal", "-goos=linux", "."); res.ExitCode != 0 {
+		t.Fatalf("-update -internal failed: %+v", res)
+	}
+	got := e.ReadFile("depaware.txt")
+	if !strings.Contains(got, "# internal: show\n") || !strings.Contains(got, " internal/fmtsort ") {
+		t.Errorf("depaware.txt doesn't record -internal or list internal packages:\n%s", got)
+	}
+	if res := e.Run("-check", "-goos=linux", "."); res.ExitCode != 0 {
+		t.Errorf("-check without -internal failed: %+v", res)
+	}
+}
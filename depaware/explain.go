@@ -0,0 +1,110 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// explainBundle is the -explain output: everything needed to debug a
+// failed -check offline, in a single JSON file that bots can attach to
+// a PR or CI can upload as an artifact.
+type explainBundle struct {
+	Env      *provenance   `json:"env"`
+	Packages []explanation `json:"packages"` // only the ones that failed
+}
+
+// explanation is why -check failed for a package.
+type explanation struct {
+	Package     string      `json:"package"`
+	File        string      `json:"file,omitempty"`
+	Error       string      `json:"error,omitempty"` // if the package couldn't be checked at all
+	Diff        string      `json:"diff,omitempty"`  // of the depaware.txt file, if out of date
+	NewDeps     []newDep    `json:"newDeps,omitempty"`
+	RemovedDeps []string    `json:"removedDeps,omitempty"`
+	Violations  []violation `json:"violations,omitempty"`
+}
+
+// newDep is a dependency that's not in the checked-in file.
+type newDep struct {
+	Package string   `json:"package"`
+	Chain   []string `json:"chain"` // of imports from the checked package
+}
+
+// explainCheck records, for -explain, that the -check of pkg against
+// daFile, whose checked-in contents are old, failed. diffText is the
+// diff of the file, if it's out of date.
+func (r *runner) explainCheck(pkg, daFile string, old []byte, diffText string, entries []fileEntry, violations []violation) {
+	ex := explanation{
+		Package:    pkg,
+		File:       daFile,
+		Diff:       diffText,
+		Violations: violations,
+	}
+	added, removed := depChanges(old, entries)
+	in := &policyInput{Pkg: pkg, Entries: entries}
+	for _, dep := range added {
+		ex.NewDeps = append(ex.NewDeps, newDep{dep, in.whyChain(dep)})
+	}
+	ex.RemovedDeps = removed
+	r.explanations = append(r.explanations, ex)
+}
+
+// explainError records, for -explain, that pkg couldn't be checked.
+func (r *runner) explainError(pkg string, err error) {
+	r.explanations = append(r.explanations, explanation{Package: pkg, Error: err.Error()})
+}
+
+// entryPkgs returns the set of packages (or modules) listed in the
+// depaware.txt contents.
+func entryPkgs(contents []byte) map[string]bool {
+	m := make(map[string]bool)
+	for _, e := range parseEntriesLoosely(contents) {
+		m[e.Pkg] = true
+	}
+	return m
+}
+
+// parseEntriesLoosely returns the entries of the depaware.txt contents
+// in order. Like parseComments, it skips lines it doesn't understand,
+// so it works on files with merge conflicts. The contents are in memory
+// already, so it doesn't limit the length of lines.
+func parseEntriesLoosely(contents []byte) []fileEntry {
+	var entries []fileEntry
+	scan := lineScanner(bytes.NewReader(contents), len(contents)+1)
+	for scan.Scan() {
+		if e, err := parseFileEntry(scan.Text()); err == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// writeExplainBundle writes the recorded explanations, along with the
+// environment, to the named file.
+func (r *runner) writeExplainBundle(name string, exps []explanation) error {
+	b := explainBundle{
+		Env:      r.newProvenance(time.Now()),
+		Packages: exps,
+	}
+	data, err := json.MarshalIndent(b, "", "\t")
+	if err != nil {
+		return err
+	}
+	w, closeOut, err := r.openOutput(name)
+	if err != nil {
+		return err
+	}
+	if w == nil {
+		w = r.stderr
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		closeOut()
+		return err
+	}
+	return closeOut()
+}
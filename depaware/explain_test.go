@@ -0,0 +1,32 @@
+package depaware
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExplainCheck(t *testing.T) {
+	const old = `example.com/cmd dependencies: (generated by github.com/tailscale/depaware)
+
+        github.com/foo/bar                                           from example.com/cmd
+        github.com/foo/old                                           from github.com/foo/bar
+`
+	entries := []fileEntry{
+		{Pkg: "github.com/foo/bar", Why: "example.com/cmd"},
+		{Pkg: "github.com/foo/new", Why: "github.com/foo/bar"},
+	}
+	vs := []violation{{Rule: "deny github.com/foo/new", Line: 1, Package: "github.com/foo/new", Message: "denied"}}
+	r := new(runner)
+	r.explainCheck("example.com/cmd", "depaware.txt", []byte(old), "the diff", entries, vs)
+	want := []explanation{{
+		Package:     "example.com/cmd",
+		File:        "depaware.txt",
+		Diff:        "the diff",
+		NewDeps:     []newDep{{"github.com/foo/new", []string{"example.com/cmd", "github.com/foo/bar", "github.com/foo/new"}}},
+		RemovedDeps: []string{"github.com/foo/old"},
+		Violations:  vs,
+	}}
+	if !reflect.DeepEqual(r.explanations, want) {
+		t.Errorf("got %+v; want %+v", r.explanations, want)
+	}
+}
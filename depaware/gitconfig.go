@@ -0,0 +1,131 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/diff"
+)
+
+// runGitConfig implements "depaware git-config install", which
+// registers depaware as the diff and merge driver for depaware.txt
+// files in the current git repo: it sets diff.depaware.command and
+// merge.depaware.driver in the repo's git config and adds a line for
+// the files to the top-level .gitattributes.
+//
+// Usage:
+//
+//	depaware git-config install [-cmd=depaware]
+func (r *runner) runGitConfig(args []string) error {
+	if len(args) == 0 || args[0] != "install" {
+		return errors.New("usage: depaware git-config install [-cmd=depaware]")
+	}
+	fs := flag.NewFlagSet("git-config install", flag.ContinueOnError)
+	fs.SetOutput(r.stderr)
+	cmd := fs.String("cmd", "depaware", `command git should run to invoke depaware, such as "go run github.com/tailscale/depaware"`)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	top, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	// The merge driver needs -goos to merge OS letters.
+	merge := *cmd + " merge %O %A %B"
+	if r.GOOS != NewOptions().GOOS {
+		merge = *cmd + " -goos=" + r.GOOS + " merge %O %A %B"
+	}
+	settings := [][2]string{
+		{"diff.depaware.command", *cmd + " git-diff"},
+		{"merge.depaware.name", "depaware semantic merge"},
+		{"merge.depaware.driver", merge},
+	}
+	for _, kv := range settings {
+		if _, err := gitOutput("config", kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+
+	attrFile := filepath.Join(top, ".gitattributes")
+	attrLine := r.File + " diff=depaware merge=depaware"
+	data, err := ioutil.ReadFile(attrFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == attrLine {
+			fmt.Fprintf(r.stdout, "git config updated; %s already configured\n", attrFile)
+			return nil
+		}
+	}
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	data = append(data, attrLine+"\n"...)
+	if err := ioutil.WriteFile(attrFile, data, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(r.stdout, "git config updated; added %q to %s\n", attrLine, attrFile)
+	return nil
+}
+
+// runGitDiff implements "depaware git-diff", the git external diff
+// driver installed by "depaware git-config install". Git runs it as:
+//
+//	depaware git-diff path old-file old-hex old-mode new-file new-hex new-mode
+//
+// It prints the dependency changes as by "depaware changelog", or falls
+// back to a unified diff of the lines if either version doesn't parse.
+func (r *runner) runGitDiff(args []string) error {
+	if len(args) != 7 {
+		return errors.New("usage: depaware git-diff path old-file old-hex old-mode new-file new-hex new-mode")
+	}
+	path := args[0]
+	var data [2][]byte
+	for i, name := range []string{args[1], args[4]} {
+		var err error
+		if data[i], err = ioutil.ReadFile(name); err != nil {
+			return err
+		}
+	}
+	var files [2]*depsFile
+	for i := range files {
+		if len(data[i]) == 0 {
+			// Added or deleted file.
+			files[i] = new(depsFile)
+			continue
+		}
+		var err error
+		if files[i], err = parseDepsFile(bytes.NewReader(data[i]), r.MaxLineBytes); err != nil {
+			// Such as during a merge conflict: fall back to a line diff.
+			return diff.Text("a/"+path, "b/"+path, data[0], data[1], r.stdout)
+		}
+	}
+	fmt.Fprintf(r.stdout, "depaware changes in %s:\n", path)
+	writeChangelog(r.stdout, files[0], files[1])
+	return nil
+}
+
+// gitOutput runs git with args and returns its trimmed output.
+func gitOutput(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), bytes.TrimSpace(ee.Stderr))
+		}
+		return "", err
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
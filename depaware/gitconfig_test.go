@@ -0,0 +1,62 @@
+package depaware
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const gitDiffOld = "example.com/cmd dependencies: (generated by github.com/tailscale/depaware)\n\n" +
+	"        bytes from example.com/cmd\n" +
+	"        errors from bytes\n"
+
+const gitDiffNew = "example.com/cmd dependencies: (generated by github.com/tailscale/depaware)\n\n" +
+	"        bytes from example.com/cmd\n" +
+	"   W U  unsafe from example.com/cmd\n"
+
+func TestRunGitDiff(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) string {
+		t.Helper()
+		name = filepath.Join(dir, name)
+		if err := os.WriteFile(name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+	oldFile := write("old.txt", gitDiffOld)
+	newFile := write("new.txt", gitDiffNew)
+	gitDiff := func(oldName, newName string) string {
+		t.Helper()
+		var out bytes.Buffer
+		r := &runner{Options: *NewOptions(), stdout: &out}
+		if err := r.runGitDiff([]string{"cmd/depaware.txt", oldName, "1111111", "100644", newName, "2222222", "100644"}); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	want := "depaware changes in cmd/depaware.txt:\n* Added unsafe (only W, unsafe, from example.com/cmd)\n* Removed errors\n"
+	if got := gitDiff(oldFile, newFile); got != want {
+		t.Errorf("changed file:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	if got := gitDiff(os.DevNull, oldFile); !strings.Contains(got, "* Added bytes") || !strings.Contains(got, "* Added errors") {
+		t.Errorf("added file: got:\n%s", got)
+	}
+
+	// A file that doesn't parse, such as one with merge conflict
+	// markers, gets a plain diff.
+	conflicted := write("conflicted.txt", gitDiffOld+"<<<<<<< HEAD\n")
+	if got := gitDiff(oldFile, conflicted); !strings.HasPrefix(got, "--- a/cmd/depaware.txt\n+++ b/cmd/depaware.txt\n") || !strings.Contains(got, "+<<<<<<< HEAD") {
+		t.Errorf("unparseable file: got:\n%s", got)
+	}
+}
+
+func TestRunGitDiffUsage(t *testing.T) {
+	r := &runner{Options: *NewOptions()}
+	if err := r.runGitDiff([]string{"depaware.txt"}); err == nil || !strings.HasPrefix(err.Error(), "usage:") {
+		t.Errorf("runGitDiff with one argument: err = %v; want usage", err)
+	}
+}
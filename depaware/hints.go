@@ -0,0 +1,97 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"fmt"
+	"go/build"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// errorHint returns a hint for fixing the go command error msg, which
+// happened while loading pkg for goos, or the empty string if it's not
+// a failure depaware knows about.
+func errorHint(msg, pkg, goos string) string {
+	switch {
+	case strings.Contains(msg, excludedPrefix):
+		dir := strings.TrimSpace(msg[strings.Index(msg, excludedPrefix)+len(excludedPrefix):])
+		if supported := supportedGOOS(dir); len(supported) > 0 {
+			list := strings.Join(supported, ",")
+			return fmt.Sprintf("%s only builds for GOOS=%s; run 'depaware -goos=%s %s'", pkg, list, list, pkg)
+		}
+		return fmt.Sprintf("%s doesn't build for GOOS=%s; pass -goos with only the platforms it supports", pkg, goos)
+	case strings.Contains(msg, `"gcc": executable file not found`),
+		strings.Contains(msg, `"clang": executable file not found`),
+		strings.Contains(msg, "C compiler") && strings.Contains(msg, "not found"):
+		return fmt.Sprintf("loading cgo packages for GOOS=%s needs a C toolchain; install one or set CC to a cross-compiler for %s", goos, goos)
+	case strings.Contains(msg, "missing go.sum entry"),
+		strings.Contains(msg, "no required module provides package"),
+		strings.Contains(msg, "cannot find module providing package"),
+		strings.Contains(msg, "updates to go.mod needed"):
+		return "run 'go mod tidy' (or 'go mod download' if go.mod is up to date) and try again"
+	case strings.Contains(msg, "is not in GOROOT"), strings.Contains(msg, "is not in std"):
+		return fmt.Sprintf("check the spelling of %s, or run depaware from within the module that contains it", pkg)
+	}
+	return ""
+}
+
+// excludedPrefix precedes the directory in the go command's error for
+// a package that doesn't build for the target GOOS.
+const excludedPrefix = "build constraints exclude all Go files in "
+
+// knownOS are the GOOS values supportedGOOS considers.
+var knownOS = []string{
+	"aix", "android", "darwin", "dragonfly", "freebsd", "illumos", "ios", "js",
+	"linux", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows",
+}
+
+// supportedGOOS returns the GOOS values for which the package in dir
+// has Go files, as far as its build constraints tell.
+func supportedGOOS(dir string) []string {
+	var supported []string
+	for _, goos := range knownOS {
+		ctx := build.Default
+		ctx.GOOS = goos
+		ctx.CgoEnabled = true
+		if _, err := ctx.ImportDir(dir, 0); err == nil {
+			supported = append(supported, goos)
+		}
+	}
+	return supported
+}
+
+// withHint returns err's message followed by a hint for fixing it, if
+// errorHint has one.
+func withHint(err error, pkg, goos string) string {
+	msg := err.Error()
+	if hint := errorHint(msg, pkg, goos); hint != "" {
+		return msg + "\n\thint: " + hint
+	}
+	return msg
+}
+
+// rootErrors returns the errors of the packages in pkgs, with hints,
+// for when none of them could be loaded.
+func rootErrors(pkgs []*packages.Package, goos string) []string {
+	var errs []string
+	for _, p := range pkgs {
+		for _, err := range p.Errors {
+			errs = append(errs, fmt.Sprintf("GOOS=%s: %s", goos, withHint(err, p.PkgPath, goos)))
+		}
+	}
+	return errs
+}
+
+// missingFileHint returns the message for a -check of pkg whose
+// depaware.txt file daFile doesn't exist.
+func missingFileHint(daFile, pkg string, err error) string {
+	if os.IsNotExist(err) {
+		return fmt.Sprintf("%s does not exist\n\thint: run 'depaware -update %s' to create it", daFile, pkg)
+	}
+	return err.Error()
+}
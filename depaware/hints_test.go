@@ -0,0 +1,56 @@
+package depaware
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestErrorHint(t *testing.T) {
+	tests := []struct {
+		msg, goos string
+		want      string // substring of the hint; empty for no hint
+	}{
+		{"build constraints exclude all Go files in /nonexistent", "windows", "doesn't build for GOOS=windows"},
+		{`cgo: C compiler "clang" not found: exec: "clang": executable file not found in $PATH`, "darwin", "C toolchain"},
+		{"missing go.sum entry for module providing package github.com/a/b", "linux", "go mod tidy"},
+		{"no required module provides package github.com/a/b; to add it:", "linux", "go mod tidy"},
+		{"something else went wrong", "linux", ""},
+	}
+	for _, tt := range tests {
+		got := errorHint(tt.msg, "example.com/foo", tt.goos)
+		if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+			t.Errorf("errorHint(%q, %q) = %q; want it to contain %q", tt.msg, tt.goos, got, tt.want)
+		}
+	}
+}
+
+func TestMissingFileHint(t *testing.T) {
+	_, err := os.Open("/nonexistent/depaware.txt")
+	got := missingFileHint("/nonexistent/depaware.txt", "example.com/foo", err)
+	if !strings.Contains(got, "depaware -update example.com/foo") {
+		t.Errorf("missingFileHint = %q", got)
+	}
+	if got := missingFileHint("x", "y", errors.New("boom")); got != "boom" {
+		t.Errorf("missingFileHint for other error = %q; want boom", got)
+	}
+}
+
+func TestSupportedGOOS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "depaware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "ios.go"), []byte("// +build ios\n\npackage ios\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	msg := "-: build constraints exclude all Go files in " + dir
+	want := "example.com/foo only builds for GOOS=ios; run 'depaware -goos=ios example.com/foo'"
+	if got := errorHint(msg, "example.com/foo", "windows"); got != want {
+		t.Errorf("errorHint = %q; want %q", got, want)
+	}
+}
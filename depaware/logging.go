@@ -0,0 +1,104 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// newLogger returns the logger for the -log-format value format, which
+// writes to w, and -v, which enables debug messages.
+func newLogger(format string, w io.Writer, verbose bool) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: slog.LevelInfo}
+	if verbose {
+		opts.Level = slog.LevelDebug
+	}
+	switch format {
+	case "plain":
+		return slog.New(&plainHandler{w: w, level: opts.Level.Level(), mu: new(sync.Mutex)}), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown -log-format %q", format)
+}
+
+// goosError is an error loading a package for a GOOS.
+type goosError struct {
+	goos string
+	err  string
+}
+
+func (e *goosError) Error() string { return fmt.Sprintf("for GOOS=%v: %s", e.goos, e.err) }
+
+// errorAttrs returns the attributes to log err, about pkg, with.
+func errorAttrs(pkg string, err error) []interface{} {
+	attrs := []interface{}{"package", pkg}
+	var ge *goosError
+	if errors.As(err, &ge) {
+		attrs = append(attrs, "goos", ge.goos)
+	}
+	return attrs
+}
+
+// plainHandler is the slog handler for -log-format=plain, which writes
+// messages for people to read, as depaware always has: the message,
+// preceded by the package it's about, if any, and "warning:" for
+// warnings, such as "example.com/cmd: warning: ...". Other attributes
+// are left out.
+type plainHandler struct {
+	w     io.Writer
+	level slog.Level
+	mu    *sync.Mutex // guards writes to w
+	pkg   string      // from WithAttrs
+}
+
+func (h *plainHandler) Enabled(_ context.Context, l slog.Level) bool { return l >= h.level }
+
+func (h *plainHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	pkg := h.pkg
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "package" {
+			pkg = a.Value.String()
+		}
+		return true
+	})
+	if pkg != "" {
+		b.WriteString(pkg + ": ")
+	}
+	switch {
+	case r.Level >= slog.LevelError:
+	case r.Level >= slog.LevelWarn:
+		b.WriteString("warning: ")
+	case r.Level < slog.LevelInfo:
+		b.WriteString("debug: ")
+	}
+	b.WriteString(r.Message)
+	b.WriteString("\n")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *plainHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	for _, a := range attrs {
+		if a.Key == "package" {
+			h2.pkg = a.Value.String()
+		}
+	}
+	return &h2
+}
+
+func (h *plainHandler) WithGroup(string) slog.Handler { return h }
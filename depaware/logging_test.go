@@ -0,0 +1,48 @@
+package depaware
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPlainLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := newLogger("plain", &buf, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Error("no go.mod", "package", "example.com/cmd", "goos", "linux")
+	l.Warn("near duplicate", "package", "example.com/cmd")
+	l.With("package", "example.com/other").Info("fine")
+	l.Debug("hidden without -v")
+	l.Error("bad flag")
+	want := "example.com/cmd: no go.mod\n" +
+		"example.com/cmd: warning: near duplicate\n" +
+		"example.com/other: fine\n" +
+		"bad flag\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := newLogger("json", &buf, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fmt.Errorf("loading: %w", &goosError{"windows", "no Go files"})
+	l.Error(err.Error(), errorAttrs("example.com/cmd", err)...)
+	l.Debug("loading packages")
+	got := buf.String()
+	for _, want := range []string{`"level":"ERROR"`, `"msg":"loading: for GOOS=windows: no Go files"`, `"package":"example.com/cmd"`, `"goos":"windows"`, `"level":"DEBUG"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("log is missing %s:\n%s", want, got)
+		}
+	}
+	if _, err := newLogger("xml", &buf, false); err == nil {
+		t.Error("newLogger accepted an unknown format")
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// runMerge implements "depaware merge", a three-way merge of depaware.txt
+// files that can be used as a git merge driver:
+//
+//	depaware merge BASE OURS THEIRS
+//
+// The merged file is written to OURS, as git expects. Unlike a textual
+// merge, dependencies never conflict: see mergeDepsFiles. Directives
+// that both sides changed differently do, in which case ours are kept
+// and an error makes git report the conflict. The OS letters of the
+// files are taken to be for -goos. Running "depaware -update"
+// afterwards produces the exact file.
+func (r *runner) runMerge(args []string) error {
+	if len(args) != 3 {
+		return errors.New("usage: depaware merge BASE OURS THEIRS")
+	}
+	var files [3]*depsFile
+	for i, name := range args {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			// Git passes an empty base when both sides added the file.
+			files[i] = new(depsFile)
+			continue
+		}
+		if files[i], err = parseDepsFile(bytes.NewReader(data), r.MaxLineBytes); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	merged, conflicts := mergeDepsFiles(strings.Split(r.GOOS, ","), files[0], files[1], files[2])
+	var buf bytes.Buffer
+	writeDepsFile(&buf, merged)
+	if err := ioutil.WriteFile(args[1], buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%s: conflicting directives, kept ours: %s", args[1], strings.Join(conflicts, "; "))
+	}
+	return nil
+}
+
+// mergeDepsFiles merges the changes from base to ours and from base to
+// theirs. A dependency changed on only one side takes that side's entry.
+// A dependency present on either side and changed on both (including
+// being removed on one side and changed on the other) is kept, with its
+// entries combined by mergeEntries, whose OS letters are relative to
+// geese. The result is sorted as depaware sorts dependencies.
+//
+// Directives are merged the same way, one key at a time, but a key
+// that both sides changed to different values is a conflict, which
+// keeps ours' value and is described in conflicts. The footer is
+// theirs if only they changed it, and ours otherwise.
+func mergeDepsFiles(geese []string, base, ours, theirs *depsFile) (merged *depsFile, conflicts []string) {
+	index := func(f *depsFile) map[string]fileEntry {
+		m := make(map[string]fileEntry, len(f.Entries))
+		for _, e := range f.Entries {
+			m[e.Pkg] = e
+		}
+		return m
+	}
+	b, o, t := index(base), index(ours), index(theirs)
+
+	pkgs := map[string]bool{}
+	for _, m := range []map[string]fileEntry{b, o, t} {
+		for pkg := range m {
+			pkgs[pkg] = true
+		}
+	}
+
+	merged = &depsFile{Pkg: ours.Pkg, Footer: ours.Footer}
+	if merged.Pkg == "" {
+		merged.Pkg = theirs.Pkg
+	}
+	if reflect.DeepEqual(ours.Footer, base.Footer) {
+		merged.Footer = theirs.Footer
+	}
+	merged.Directives, conflicts = mergeDirectives(base.Directives, ours.Directives, theirs.Directives)
+	for pkg := range pkgs {
+		be, inBase := b[pkg]
+		oe, inOurs := o[pkg]
+		te, inTheirs := t[pkg]
+		sameOurs := inOurs == inBase && oe == be
+		sameTheirs := inTheirs == inBase && te == be
+		switch {
+		case sameTheirs:
+			if inOurs {
+				merged.Entries = append(merged.Entries, oe)
+			}
+		case sameOurs:
+			if inTheirs {
+				merged.Entries = append(merged.Entries, te)
+			}
+		case inOurs && inTheirs:
+			merged.Entries = append(merged.Entries, mergeEntries(geese, oe, te))
+		case inOurs:
+			merged.Entries = append(merged.Entries, oe)
+		case inTheirs:
+			merged.Entries = append(merged.Entries, te)
+		}
+	}
+	sort.Slice(merged.Entries, func(i, j int) bool {
+		return depLess(merged.Entries[i].Pkg, merged.Entries[j].Pkg)
+	})
+	return merged, conflicts
+}
+
+// mergeDirectives merges the changes from the directives base to ours
+// and from base to theirs, returning a description of each key that
+// both changed to different values, for which it keeps ours.
+func mergeDirectives(base, ours, theirs map[string]string) (merged map[string]string, conflicts []string) {
+	keys := map[string]bool{}
+	for _, m := range []map[string]string{base, ours, theirs} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	for _, k := range sorted {
+		bv, inBase := base[k]
+		ov, inOurs := ours[k]
+		tv, inTheirs := theirs[k]
+		v, ok := ov, inOurs
+		switch {
+		case inOurs == inBase && ov == bv:
+			v, ok = tv, inTheirs
+		case inTheirs == inBase && tv == bv, inOurs == inTheirs && ov == tv:
+		default:
+			conflicts = append(conflicts, fmt.Sprintf("%q is %q in ours but %q in theirs", k, ov, tv))
+		}
+		if !ok {
+			continue
+		}
+		if merged == nil {
+			merged = make(map[string]string)
+		}
+		merged[k] = v
+	}
+	return merged, conflicts
+}
+
+// mergeEntries combines two entries for the same dependency that were
+// changed in different ways. The result is a dependency on the union of
+// their platforms, as computed by unionOS for geese, with the union of
+// their flags. The "from" package is recomputed as the lexicographically
+// first of the two, as depaware would pick.
+func mergeEntries(geese []string, a, b fileEntry) fileEntry {
+	m := a
+	m.OS = unionOS(geese, a.OS, b.OS)
+	m.Unsafe = a.Unsafe || b.Unsafe
+	m.CGO = a.CGO || b.CGO
+	if b.Why != "" && (a.Why == "" || b.Why < a.Why) {
+		m.Why = b.Why
+	}
+	m.More = a.More || b.More || (a.Why != b.Why && a.Why != "" && b.Why != "")
+	if m.Comment == "" {
+		m.Comment = b.Comment
+	}
+	return m
+}
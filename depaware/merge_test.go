@@ -0,0 +1,71 @@
+package depaware
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeDepsFiles(t *testing.T) {
+	base := &depsFile{Pkg: "example.com/cmd", Entries: []fileEntry{
+		{Pkg: "github.com/a/kept", Why: "example.com/cmd"},
+		{Pkg: "github.com/a/removed-by-ours", Why: "example.com/cmd"},
+		{Pkg: "github.com/a/both-changed", OS: "L", Why: "github.com/a/kept"},
+		{Pkg: "github.com/a/removed-vs-changed", Why: "example.com/cmd"},
+		{Pkg: "bytes", Why: "github.com/a/kept"},
+	}}
+	ours := &depsFile{Pkg: "example.com/cmd", Entries: []fileEntry{
+		{Pkg: "github.com/a/kept", Why: "example.com/cmd"},
+		{Pkg: "github.com/a/both-changed", OS: "LD", Why: "github.com/a/kept"},
+		{Pkg: "github.com/a/removed-vs-changed", Unsafe: true, Why: "example.com/cmd"},
+		{Pkg: "github.com/a/added-by-ours", Why: "example.com/cmd"},
+		{Pkg: "bytes", Why: "github.com/a/kept"},
+	}}
+	theirs := &depsFile{Pkg: "example.com/cmd", Entries: []fileEntry{
+		{Pkg: "github.com/a/kept", Why: "example.com/cmd"},
+		{Pkg: "github.com/a/removed-by-ours", Why: "example.com/cmd"},
+		{Pkg: "github.com/a/both-changed", OS: "LW", Why: "example.com/cmd", CGO: true},
+		{Pkg: "bytes", Why: "bufio", More: true},
+		{Pkg: "bufio", Why: "github.com/a/kept"},
+	}}
+	want := &depsFile{Pkg: "example.com/cmd", Entries: []fileEntry{
+		{Pkg: "github.com/a/added-by-ours", Why: "example.com/cmd"},
+		{Pkg: "github.com/a/both-changed", CGO: true, Why: "example.com/cmd", More: true},
+		{Pkg: "github.com/a/kept", Why: "example.com/cmd"},
+		{Pkg: "github.com/a/removed-vs-changed", Unsafe: true, Why: "example.com/cmd"},
+		{Pkg: "bufio", Why: "github.com/a/kept"},
+		{Pkg: "bytes", Why: "bufio", More: true},
+	}}
+	got, conflicts := mergeDepsFiles([]string{"linux", "darwin", "windows"}, base, ours, theirs)
+	if !reflect.DeepEqual(got, want) || conflicts != nil {
+		t.Errorf("got:\n%+v, %q\nwant:\n%+v", got, conflicts, want)
+	}
+}
+
+func TestMergeEntriesOS(t *testing.T) {
+	geese := []string{"linux", "darwin", "windows", "freebsd"}
+	for _, tt := range []struct{ a, b, want string }{
+		{"W", "L", "LW"},
+		{"WF", "DL", ""},
+		{"", "L", ""},
+	} {
+		if got := mergeEntries(geese, fileEntry{OS: tt.a}, fileEntry{OS: tt.b}).OS; got != tt.want {
+			t.Errorf("union of %q and %q = %q; want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMergeDirectivesAndFooter(t *testing.T) {
+	base := &depsFile{Pkg: "example.com/cmd", Directives: map[string]string{"hide": "a", "show": "b"}, Footer: []string{"old"}}
+	ours := &depsFile{Pkg: "example.com/cmd", Directives: map[string]string{"hide": "a", "show": "c", "native": "badge"}, Footer: []string{"old"}}
+	theirs := &depsFile{Pkg: "example.com/cmd", Directives: map[string]string{"hide": "x", "show": "d"}, Footer: []string{"new"}}
+	got, conflicts := mergeDepsFiles([]string{"linux"}, base, ours, theirs)
+	if want := map[string]string{"hide": "x", "show": "c", "native": "badge"}; !reflect.DeepEqual(got.Directives, want) {
+		t.Errorf("directives = %v; want %v", got.Directives, want)
+	}
+	if want := []string{`"show" is "c" in ours but "d" in theirs`}; !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("conflicts = %q; want %q", conflicts, want)
+	}
+	if want := []string{"new"}; !reflect.DeepEqual(got.Footer, want) {
+		t.Errorf("footer = %q; want %q", got.Footer, want)
+	}
+}
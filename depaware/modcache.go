@@ -0,0 +1,187 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// verifyModuleCache re-hashes the module cache copies of n randomly
+// chosen dependency modules of d, or all of them if n is negative,
+// and compares the hashes with those of goSumFiles, as "go mod verify"
+// does. It returns a message for each module that doesn't match, so
+// that cache corruption or tampering shows up in the dependencies'
+// report. Replaced and vendored modules aren't verified.
+func (d *deps) verifyModuleCache(n int) ([]string, error) {
+	if d.MainModuleDir == "" {
+		return nil, nil
+	}
+	var mods []module.Version
+	for path, dir := range d.ModuleDirs {
+		if dir != "" {
+			mods = append(mods, d.moduleVersion(path))
+		}
+	}
+	sort.Slice(mods, func(i, j int) bool { return mods[i].Path < mods[j].Path })
+	if n >= 0 && n < len(mods) {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		r.Shuffle(len(mods), func(i, j int) { mods[i], mods[j] = mods[j], mods[i] })
+		mods = mods[:n]
+		sort.Slice(mods, func(i, j int) bool { return mods[i].Path < mods[j].Path })
+	}
+	if len(mods) == 0 {
+		return nil, nil
+	}
+	files, err := d.goSumFiles()
+	if err != nil {
+		return nil, err
+	}
+	sums, err := readGoSums(files)
+	if err != nil {
+		return nil, err
+	}
+	if sums == nil {
+		return nil, fmt.Errorf("-verify-cache: no %s to verify the module cache against", strings.Join(files, " or "))
+	}
+	var msgs []string
+	for _, m := range mods {
+		if msg := verifyModule(m, d.ModuleDirs[m.Path], sums); msg != "" {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, nil
+}
+
+// moduleVersion returns the version of the module path, as a
+// dependency of d.
+func (d *deps) moduleVersion(path string) module.Version {
+	for _, m := range d.Module {
+		if m.Path == path {
+			return m
+		}
+	}
+	return module.Version{Path: path}
+}
+
+// verifyModule checks the extracted copy of m in dir, and its zip file
+// if it's still in the download cache, against the go.sum hashes in
+// sums. It returns a description of the mismatch, if any.
+func verifyModule(m module.Version, dir string, sums map[module.Version]string) string {
+	want, ok := sums[m]
+	if !ok {
+		return fmt.Sprintf("%s@%s: no go.sum entry to verify the module cache against", m.Path, m.Version)
+	}
+	got, err := dirhash.HashDir(dir, m.Path+"@"+m.Version, dirhash.DefaultHash)
+	if err != nil {
+		return fmt.Sprintf("%s@%s: hashing %s: %v", m.Path, m.Version, dir, err)
+	}
+	if got != want {
+		return fmt.Sprintf("%s@%s: module cache directory %s has hash %s; go.sum has %s", m.Path, m.Version, dir, got, want)
+	}
+	if zip := moduleZipPath(m, dir); zip != "" {
+		if _, err := os.Stat(zip); err == nil {
+			got, err := dirhash.HashZip(zip, dirhash.DefaultHash)
+			if err != nil {
+				return fmt.Sprintf("%s@%s: hashing %s: %v", m.Path, m.Version, zip, err)
+			}
+			if got != want {
+				return fmt.Sprintf("%s@%s: module cache zip %s has hash %s; go.sum has %s", m.Path, m.Version, zip, got, want)
+			}
+		}
+	}
+	return ""
+}
+
+// moduleZipPath returns the path of the downloaded zip file of m in the
+// module cache that dir, the extracted copy of m, is in, or the empty
+// string if dir isn't in a module cache.
+func moduleZipPath(m module.Version, dir string) string {
+	escPath, err := module.EscapePath(m.Path)
+	if err != nil {
+		return ""
+	}
+	escVersion, err := module.EscapeVersion(m.Version)
+	if err != nil {
+		return ""
+	}
+	suffix := string(filepath.Separator) + filepath.FromSlash(escPath+"@"+escVersion)
+	if !strings.HasSuffix(dir, suffix) {
+		return ""
+	}
+	cache := strings.TrimSuffix(dir, suffix)
+	return filepath.Join(cache, "cache", "download", filepath.FromSlash(escPath), "@v", escVersion+".zip")
+}
+
+// goSumFiles returns the go.sum files with the hashes of d's
+// dependencies: the main module's, or in workspace mode, go.work.sum
+// and those of every workspace module.
+func (d *deps) goSumFiles() ([]string, error) {
+	work := goWorkFile(d.MainModuleDir)
+	if work == "" {
+		return []string{filepath.Join(d.MainModuleDir, "go.sum")}, nil
+	}
+	dirs, err := workspaceDirs(work)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{filepath.Join(filepath.Dir(work), "go.work.sum")}
+	for _, dir := range dirs {
+		files = append(files, filepath.Join(dir, "go.sum"))
+	}
+	return files, nil
+}
+
+// readGoSums returns the module hashes in the named go.sum files that
+// exist, or nil if none do.
+func readGoSums(names []string) (map[module.Version]string, error) {
+	var sums map[module.Version]string
+	for _, name := range names {
+		m, err := readGoSum(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if sums == nil {
+			sums = m
+			continue
+		}
+		for mv, h := range m {
+			sums[mv] = h
+		}
+	}
+	return sums, nil
+}
+
+// readGoSum returns the module hashes in the named go.sum file, without
+// the go.mod-only ones.
+func readGoSum(name string) (map[module.Version]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sums := make(map[module.Version]string)
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		f := strings.Fields(scan.Text())
+		if len(f) != 3 || strings.HasSuffix(f[1], "/go.mod") {
+			continue
+		}
+		sums[module.Version{Path: f[0], Version: f[1]}] = f[2]
+	}
+	return sums, scan.Err()
+}
@@ -0,0 +1,102 @@
+package depaware
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+func TestModuleZipPath(t *testing.T) {
+	m := module.Version{Path: "github.com/BurntSushi/toml", Version: "v0.3.1"}
+	cache := filepath.FromSlash("/home/me/go/pkg/mod")
+	dir := filepath.Join(cache, "github.com", "!burnt!sushi", "toml@v0.3.1")
+	want := filepath.Join(cache, "cache", "download", "github.com", "!burnt!sushi", "toml", "@v", "v0.3.1.zip")
+	if got := moduleZipPath(m, dir); got != want {
+		t.Errorf("moduleZipPath = %q; want %q", got, want)
+	}
+	if got := moduleZipPath(m, filepath.FromSlash("/src/toml")); got != "" {
+		t.Errorf("moduleZipPath outside module cache = %q; want empty", got)
+	}
+}
+
+func TestReadGoSum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "depaware-gosum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "go.sum")
+	const sum = `github.com/pkg/diff v0.0.0-20200914180035-5b29258ca4f7 h1:+/5q+XdMB0Yl7QNbE2lGqZqK5gU2wF1/fbR8gfhvOY4=
+github.com/pkg/diff v0.0.0-20200914180035-5b29258ca4f7/go.mod h1:zO8QMzTeZd5cpnIkz/Gn6iK0jDfGicM1nynOkkPIl28=
+`
+	if err := ioutil.WriteFile(name, []byte(sum), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readGoSum(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[module.Version]string{
+		{Path: "github.com/pkg/diff", Version: "v0.0.0-20200914180035-5b29258ca4f7"}: "h1:+/5q+XdMB0Yl7QNbE2lGqZqK5gU2wF1/fbR8gfhvOY4=",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestGoSumFiles(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	t.Setenv("GOFLAGS", "")
+	t.Setenv("GOTOOLCHAIN", "local")
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.work":     "go 1.21\n\nuse (\n\t./a\n\t./b\n)\n",
+		"go.work.sum": "example.com/w v1.0.0 h1:w=\n",
+		"a/go.mod":    "module example.com/a\n\ngo 1.21\n",
+		"a/go.sum":    "example.com/x v1.0.0 h1:x=\n",
+		"b/go.mod":    "module example.com/b\n\ngo 1.21\n",
+	}
+	for name, contents := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := &deps{MainModuleDir: filepath.Join(dir, "a")}
+	names, err := d.goSumFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sums, err := readGoSums(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[module.Version]string{
+		{Path: "example.com/w", Version: "v1.0.0"}: "h1:w=",
+		{Path: "example.com/x", Version: "v1.0.0"}: "h1:x=",
+	}
+	if !reflect.DeepEqual(sums, want) {
+		t.Errorf("workspace sums = %v; want %v", sums, want)
+	}
+}
+
+func TestVerifyModuleCacheNoGoSum(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	d := &deps{
+		MainModuleDir: t.TempDir(),
+		Module:        map[string]module.Version{"example.com/x": {Path: "example.com/x", Version: "v1.0.0"}},
+		ModuleDirs:    map[string]string{"example.com/x": t.TempDir()},
+	}
+	msgs, err := d.verifyModuleCache(-1)
+	if err == nil || !strings.Contains(err.Error(), "no "+filepath.Join(d.MainModuleDir, "go.sum")+" to verify") || msgs != nil {
+		t.Errorf("without go.sum: got %q, %v; want a single error", msgs, err)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// stdModule is the name used for the standard library in
+// module-granularity output.
+const stdModule = "std"
+
+// ModuleEntries returns one entry per module providing d.Deps, with
+// its version and the number of packages used from it, for
+// module-granularity output. Packages that aren't in a module are
+// attributed to stdModule. A module's OS letters, unsafe and cgo flags
+// are the union of those of its packages. The entries are sorted as
+// for depLess.
+func (d *deps) ModuleEntries(geese []string) []fileEntry {
+	return d.moduleEntries(geese, d.Deps)
+}
+
+// HybridEntries returns the entries for hybrid-granularity output:
+// third-party dependencies are grouped by module as by ModuleEntries,
+// while the standard library and golang.org/x packages are listed
+// individually as by Entries.
+func (d *deps) HybridEntries(geese []string, preferredWhy map[string]string) []fileEntry {
+	var thirdParty []string
+	for _, pkg := range d.Deps {
+		if d.isThirdParty(pkg) {
+			thirdParty = append(thirdParty, pkg)
+		}
+	}
+	entries := d.moduleEntries(geese, thirdParty)
+	for _, e := range d.Entries(geese, preferredWhy) {
+		if !d.isThirdParty(e.Pkg) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// isThirdParty reports whether pkg belongs to a module other than
+// golang.org/x. Standard library packages don't belong to any.
+func (d *deps) isThirdParty(pkg string) bool {
+	m, ok := d.Module[pkg]
+	return ok && !strings.HasPrefix(m.Path, "golang.org/x/")
+}
+
+// moduleEntries returns the module entries for pkgs, which must be a
+// subset of d.Deps.
+func (d *deps) moduleEntries(geese []string, pkgs []string) []fileEntry {
+	byMod := map[string]*fileEntry{}
+	onOS := map[pkgGOOS]bool{}
+	var mods []string
+	for _, pkg := range pkgs {
+		mod := stdModule
+		m, ok := d.Module[pkg]
+		if ok {
+			mod = m.Path
+		}
+		e, ok := byMod[mod]
+		if !ok {
+			e = &fileEntry{Pkg: mod, Version: m.Version}
+			byMod[mod] = e
+			mods = append(mods, mod)
+		}
+		e.Count++
+		e.Unsafe = e.Unsafe || d.UsesUnsafe[pkg] && !isGoPackage(pkg)
+		e.CGO = e.CGO || d.UsesCGO[pkg] && !isGoPackage(pkg)
+		for _, goos := range geese {
+			if d.DepOnOS[pkgGOOS{pkg, goos}] {
+				onOS[pkgGOOS{mod, goos}] = true
+			}
+		}
+	}
+	sort.Slice(mods, func(i, j int) bool {
+		// Sort std last, along with the standard library packages
+		// it stands for.
+		if mi, mj := mods[i] == stdModule, mods[j] == stdModule; mi != mj {
+			return mj
+		}
+		return depLess(mods[i], mods[j])
+	})
+	entries := make([]fileEntry, 0, len(mods))
+	var osBuf bytes.Buffer
+	for _, mod := range mods {
+		e := byMod[mod]
+		osBuf.Reset()
+		for _, goos := range geese {
+			if onOS[pkgGOOS{mod, goos}] {
+				osBuf.WriteRune(unicode.ToUpper(rune(goos[0])))
+			}
+		}
+		if osBuf.Len() != len(geese) {
+			e.OS = osBuf.String()
+		}
+		entries = append(entries, *e)
+	}
+	return entries
+}
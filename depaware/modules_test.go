@@ -0,0 +1,61 @@
+package depaware
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+func testModuleDeps() *deps {
+	return &deps{
+		Deps: []string{"github.com/foo/bar", "github.com/foo/bar/sub", "golang.org/x/sys/unix", "bytes", "os"},
+		DepOnOS: map[pkgGOOS]bool{
+			{"github.com/foo/bar", "linux"}:     true,
+			{"github.com/foo/bar", "windows"}:   true,
+			{"github.com/foo/bar/sub", "linux"}: true,
+			{"golang.org/x/sys/unix", "linux"}:  true,
+			{"bytes", "linux"}:                  true,
+			{"bytes", "windows"}:                true,
+			{"os", "linux"}:                     true,
+			{"os", "windows"}:                   true,
+		},
+		UsesUnsafe: map[string]bool{"github.com/foo/bar/sub": true},
+		Module: map[string]module.Version{
+			"github.com/foo/bar":     {Path: "github.com/foo/bar", Version: "v1.2.3"},
+			"github.com/foo/bar/sub": {Path: "github.com/foo/bar", Version: "v1.2.3"},
+			"golang.org/x/sys/unix":  {Path: "golang.org/x/sys", Version: "v0.1.0"},
+		},
+	}
+}
+
+func TestModuleEntries(t *testing.T) {
+	d := testModuleDeps()
+	got := d.ModuleEntries([]string{"linux", "windows"})
+	want := []fileEntry{
+		{Pkg: "github.com/foo/bar", Unsafe: true, Count: 2, Version: "v1.2.3"},
+		{Pkg: "golang.org/x/sys", OS: "L", Count: 1, Version: "v0.1.0"},
+		{Pkg: "std", Count: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+}
+
+func TestHybridEntries(t *testing.T) {
+	d := testModuleDeps()
+	d.AddEdge("github.com/foo/bar", "golang.org/x/sys/unix")
+	d.AddEdge("github.com/foo/bar", "bytes")
+	d.AddEdge("golang.org/x/sys/unix", "os")
+	d.AddEdge("github.com/foo/bar/sub", "unsafe")
+	got := d.HybridEntries([]string{"linux", "windows"}, nil)
+	want := []fileEntry{
+		{Pkg: "github.com/foo/bar", Unsafe: true, Count: 2, Version: "v1.2.3"},
+		{Pkg: "golang.org/x/sys/unix", OS: "L", Why: "github.com/foo/bar"},
+		{Pkg: "bytes", Why: "github.com/foo/bar"},
+		{Pkg: "os", Why: "golang.org/x/sys/unix"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+}
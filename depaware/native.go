@@ -0,0 +1,153 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// nativeKinds are the kinds of non-Go code that nativeKind reports, in
+// the order -native lists them.
+var nativeKinds = []string{"asm", "c", "syso", "bin"}
+
+// nativeKind returns the kind of non-Go code in the file with the given
+// name, or the empty string if it's not code (or is Go):
+//
+//	asm:  assembly
+//	c:    C, C++, Objective-C, Fortran or SWIG sources
+//	syso: object files that the linker adds to the binary as is
+//	bin:  other prebuilt binaries, such as static and shared libraries
+func nativeKind(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".s", ".sx":
+		return "asm"
+	case ".c", ".cc", ".cpp", ".cxx", ".h", ".hh", ".hpp", ".hxx", ".m",
+		".f", ".for", ".f90", ".swig", ".swigcxx":
+		return "c"
+	case ".syso":
+		return "syso"
+	case ".a", ".so", ".dylib", ".dll", ".lib", ".o", ".obj":
+		return "bin"
+	}
+	return ""
+}
+
+// NativeKinds returns the kinds of non-Go code in pkg, in the order of
+// nativeKinds, as found by nativeFiles.
+func (d *deps) NativeKinds(pkg string) []string {
+	found := make(map[string]bool)
+	for _, f := range d.nativeFiles(pkg) {
+		found[nativeKind(f)] = true
+	}
+	var kinds []string
+	for _, k := range nativeKinds {
+		if found[k] {
+			kinds = append(kinds, k)
+		}
+	}
+	return kinds
+}
+
+// sysoFiles returns the names of the .syso files in pkg.
+func (d *deps) sysoFiles(pkg string) []string {
+	var names []string
+	for _, f := range d.nativeFiles(pkg) {
+		if nativeKind(f) == "syso" {
+			names = append(names, filepath.Base(f))
+		}
+	}
+	return names
+}
+
+// nativeFiles returns the non-Go files of pkg that contain code: its
+// non-Go source files, and the files in its directory, which finds
+// prebuilt binaries that the go command doesn't list. Packages of the
+// standard library and golang.org/x aren't inspected.
+func (d *deps) nativeFiles(pkg string) []string {
+	if isGoPackage(pkg) {
+		return nil
+	}
+	var files []string
+	seen := make(map[string]bool)
+	add := func(f string) {
+		if nativeKind(f) != "" && !seen[f] {
+			seen[f] = true
+			files = append(files, f)
+		}
+	}
+	for _, f := range d.OtherFiles[pkg] {
+		add(f)
+	}
+	if dir := d.pkgDir(pkg); dir != "" {
+		if fis, err := ioutil.ReadDir(dir); err == nil {
+			for _, fi := range fis {
+				if !fi.IsDir() {
+					add(filepath.Join(dir, fi.Name()))
+				}
+			}
+		}
+	}
+	return files
+}
+
+// pkgDir returns the directory of pkg, as far as its files tell, or
+// the empty string if it's unknown.
+func (d *deps) pkgDir(pkg string) string {
+	if files := d.GoFiles[pkg]; len(files) > 0 {
+		return filepath.Dir(files[0])
+	}
+	if files := d.OtherFiles[pkg]; len(files) > 0 {
+		return filepath.Dir(files[0])
+	}
+	return ""
+}
+
+// setNativeKinds sets the Native field of entries, for -native. Module
+// entries get the kinds of all their packages in d.Deps.
+func (d *deps) setNativeKinds(entries []fileEntry) {
+	byMod := make(map[string]map[string]bool)
+	for _, pkg := range d.Deps {
+		m, ok := d.Module[pkg]
+		if !ok {
+			continue
+		}
+		for _, k := range d.NativeKinds(pkg) {
+			if byMod[m.Path] == nil {
+				byMod[m.Path] = make(map[string]bool)
+			}
+			byMod[m.Path][k] = true
+		}
+	}
+	for i, e := range entries {
+		if e.Count == 0 {
+			entries[i].Native = strings.Join(d.NativeKinds(e.Pkg), ",")
+			continue
+		}
+		var kinds []string
+		for _, k := range nativeKinds {
+			if byMod[e.Pkg][k] {
+				kinds = append(kinds, k)
+			}
+		}
+		entries[i].Native = strings.Join(kinds, ",")
+	}
+}
+
+// sysoViolations returns a message for each dependency of pkg with
+// .syso files, for -no-syso, with the chain of imports that pulls it
+// in.
+func (d *deps) sysoViolations(pkg string) []string {
+	var msgs []string
+	for _, dep := range d.Deps {
+		if names := d.sysoFiles(dep); len(names) > 0 {
+			msgs = append(msgs, fmt.Sprintf("%s has .syso files (%s), imported via %s",
+				dep, strings.Join(names, ", "), strings.Join(d.shortestChain(pkg, dep), " -> ")))
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,93 @@
+package depaware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+func TestNativeKind(t *testing.T) {
+	for name, want := range map[string]string{
+		"foo.go":          "",
+		"foo_amd64.s":     "asm",
+		"zlib.c":          "c",
+		"zlib.H":          "c",
+		"rsrc_amd64.syso": "syso",
+		"libfoo.a":        "bin",
+		"README.md":       "",
+	} {
+		if got := nativeKind(name); got != want {
+			t.Errorf("nativeKind(%q) = %q; want %q", name, got, want)
+		}
+	}
+}
+
+func TestSetNativeKinds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "depaware-native")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"bar.go", "bar.c", "rsrc.syso", "libbar.a"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := &deps{
+		Deps: []string{"github.com/foo/bar", "github.com/foo/baz", "runtime/cgo"},
+		Module: map[string]module.Version{
+			"github.com/foo/bar": {Path: "github.com/foo", Version: "v1.0.0"},
+			"github.com/foo/baz": {Path: "github.com/foo", Version: "v1.0.0"},
+		},
+	}
+	d.AddGoFiles("github.com/foo/bar", []string{filepath.Join(dir, "bar.go")})
+	d.AddOtherFiles("github.com/foo/bar", []string{filepath.Join(dir, "bar.c"), filepath.Join(dir, "rsrc.syso")})
+	d.AddOtherFiles("github.com/foo/baz", []string{"/mod/baz/baz_amd64.s"})
+	d.AddOtherFiles("runtime/cgo", []string{"/goroot/src/runtime/cgo/gcc_linux_amd64.c"})
+
+	entries := []fileEntry{{Pkg: "github.com/foo/bar"}, {Pkg: "github.com/foo/baz"}, {Pkg: "runtime/cgo"}}
+	d.setNativeKinds(entries)
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Native)
+	}
+	if want := []string{"c,syso,bin", "asm", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("package entries: got %q; want %q", got, want)
+	}
+
+	mods := d.ModuleEntries([]string{"linux"})
+	d.setNativeKinds(mods)
+	if mods[0].Native != "asm,c,syso,bin" {
+		t.Errorf("module entry: got %q; want %q", mods[0].Native, "asm,c,syso,bin")
+	}
+
+	var buf bytes.Buffer
+	f := &depsFile{Pkg: "example.com/cmd", Entries: entries[:1]}
+	writeDepsFile(&buf, f)
+	back, err := parseDepsFile(&buf, defaultMaxLineBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, f) {
+		t.Errorf("round trip: got %+v; want %+v", back, f)
+	}
+}
+
+func TestSysoViolations(t *testing.T) {
+	d := new(deps)
+	d.AddEdge("example.com/cmd", "github.com/foo/bar")
+	d.AddEdge("github.com/foo/bar", "github.com/foo/rsrc")
+	d.AddDep("github.com/foo/bar", "windows")
+	d.AddDep("github.com/foo/rsrc", "windows")
+	d.AddOtherFiles("github.com/foo/rsrc", []string{"/mod/rsrc/rsrc_windows_amd64.syso", "/mod/rsrc/rsrc.c"})
+	got := d.sysoViolations("example.com/cmd")
+	want := []string{"github.com/foo/rsrc has .syso files (rsrc_windows_amd64.syso), imported via example.com/cmd -> github.com/foo/bar -> github.com/foo/rsrc"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// nearDup is a pair of module paths that look like they're the same
+// module under different names, which often means a half-finished
+// migration is doubling the dependency surface.
+type nearDup struct {
+	A      string `json:"a"` // module path, less than B
+	B      string `json:"b"`
+	Reason string `json:"reason"`
+}
+
+func (n nearDup) String() string {
+	return fmt.Sprintf("modules %s and %s look like duplicates: %s", n.A, n.B, n.Reason)
+}
+
+// codeHosts are hosts whose first path element is a user or
+// organization rather than part of the project name.
+var codeHosts = map[string]bool{
+	"github.com":    true,
+	"gitlab.com":    true,
+	"bitbucket.org": true,
+	"gitee.com":     true,
+	"codeberg.org":  true,
+	"git.sr.ht":     true,
+}
+
+// Modules returns the sorted paths of the modules providing d.Deps,
+// excluding the main module.
+func (d *deps) Modules() []string {
+	seen := map[string]bool{}
+	var mods []string
+	for _, pkg := range d.Deps {
+		m, ok := d.Module[pkg]
+		if !ok || m.Path == d.MainModule || seen[m.Path] {
+			continue
+		}
+		seen[m.Path] = true
+		mods = append(mods, m.Path)
+	}
+	sort.Strings(mods)
+	return mods
+}
+
+// findNearDups returns the pairs of module paths in mods that differ
+// only by case, only by major version suffix, or that have the same
+// name but are hosted elsewhere (such as github.com/golang/protobuf and
+// google.golang.org/protobuf). mods must be sorted, as returned by
+// deps.Modules, so that each pair is in order.
+func findNearDups(mods []string) []nearDup {
+	var dups []nearDup
+	for i, a := range mods {
+		for _, b := range mods[i+1:] {
+			if reason := nearDupReason(a, b); reason != "" {
+				dups = append(dups, nearDup{a, b, reason})
+			}
+		}
+	}
+	sort.Slice(dups, func(i, j int) bool {
+		if dups[i].A != dups[j].A {
+			return dups[i].A < dups[j].A
+		}
+		return dups[i].B < dups[j].B
+	})
+	return dups
+}
+
+// nearDupReason returns why module paths a and b look like duplicates,
+// or the empty string if they don't.
+func nearDupReason(a, b string) string {
+	if a == b {
+		return ""
+	}
+	if strings.EqualFold(a, b) {
+		return "paths differ only by case"
+	}
+	pa, _, okA := module.SplitPathVersion(a)
+	pb, _, okB := module.SplitPathVersion(b)
+	if okA && okB && pa == pb {
+		return "multiple major versions"
+	}
+	if okA && okB && strings.EqualFold(pa, pb) {
+		return "paths differ only by case and major version"
+	}
+	if okA && okB && hostOf(pa) != hostOf(pb) && (codeHosts[hostOf(pa)] || codeHosts[hostOf(pb)]) &&
+		projectName(pa) == projectName(pb) {
+		return "same name on a different host; half-finished migration?"
+	}
+	return ""
+}
+
+func hostOf(modPath string) string {
+	if i := strings.Index(modPath, "/"); i >= 0 {
+		return modPath[:i]
+	}
+	return modPath
+}
+
+// projectName returns the last element of modPath, lowercased and
+// without any "go-" prefix or "-go" suffix, which are commonly added or
+// dropped when a project moves.
+func projectName(modPath string) string {
+	name := strings.ToLower(path.Base(modPath))
+	name = strings.TrimPrefix(name, "go-")
+	name = strings.TrimSuffix(name, "-go")
+	return name
+}
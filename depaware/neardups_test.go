@@ -0,0 +1,43 @@
+package depaware
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindNearDups(t *testing.T) {
+	mods := []string{
+		"github.com/Sirupsen/logrus",
+		"github.com/golang/protobuf",
+		"github.com/pkg/errors",
+		"github.com/go-errors/errors",
+		"github.com/sirupsen/logrus",
+		"github.com/foo/bar",
+		"github.com/foo/bar/v2",
+		"google.golang.org/protobuf",
+		"gopkg.in/yaml.v2",
+		"gopkg.in/yaml.v3",
+		"example.com/uuid",
+	}
+	want := []nearDup{
+		{"github.com/Sirupsen/logrus", "github.com/sirupsen/logrus", "paths differ only by case"},
+		{"github.com/foo/bar", "github.com/foo/bar/v2", "multiple major versions"},
+		{"github.com/golang/protobuf", "google.golang.org/protobuf", "same name on a different host; half-finished migration?"},
+		{"gopkg.in/yaml.v2", "gopkg.in/yaml.v3", "multiple major versions"},
+	}
+	if got := findNearDups(mods); !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestFindNearDupsThreeModules(t *testing.T) {
+	mods := []string{"github.com/Foo/bar", "github.com/foo/bar", "github.com/foo/bar/v2"}
+	want := []nearDup{
+		{"github.com/Foo/bar", "github.com/foo/bar", "paths differ only by case"},
+		{"github.com/Foo/bar", "github.com/foo/bar/v2", "paths differ only by case and major version"},
+		{"github.com/foo/bar", "github.com/foo/bar/v2", "multiple major versions"},
+	}
+	if got := findNearDups(mods); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"flag"
+	"io"
+	"log/slog"
+)
+
+// Options configure a depaware run. Main sets them from the command
+// line; programs embedding depaware can set them directly and call Run.
+// Each field is the value of the flag named in its comment, and
+// NewOptions returns the flags' defaults.
+type Options struct {
+	Check  bool // -check
+	Update bool // -update
+
+	// File is the name of each package's depaware.txt file, relative to
+	// its directory (-file). A name outside the directory, such as
+	// ../depaware.txt, gets the package's name added, as in
+	// ../depaware.foo.txt, so that packages don't share a file.
+	File string
+
+	// MaxLineBytes is the longest line read from an existing
+	// depaware.txt file (-max-line-bytes); longer lines are an error
+	// rather than silently ignored.
+	MaxLineBytes int
+
+	// OutputTemplate, if non-empty, is a text/template for the name of
+	// each package's file, used instead of File (-output-template), such
+	// as "{{.Dir}}/deps/{{.PkgName}}.depaware.txt". It has the package's
+	// directory as .Dir, its import path as .ImportPath and the last
+	// element of the import path as .PkgName. Relative names are
+	// relative to the current directory.
+	OutputTemplate string
+
+	GOOS string // -goos
+	Tags string // -tags
+
+	// Roots are comma-separated patterns of other main packages, such as
+	// ./cmd/tool-debug, whose dependencies the package's file also
+	// covers (-roots), for a family of closely related binaries sharing
+	// one file. Like Hide, Show, CompareIgnore and OwnInternal, it's
+	// recorded in depaware.txt, and if empty, what the existing file
+	// uses.
+	Roots string
+
+	// RequireMain makes packages that aren't main packages fail
+	// (-require-main), rather than only noting it when there's no
+	// depaware.txt file yet: a file for a library directory generated
+	// by mistake makes for a misleadingly small list.
+	RequireMain bool
+
+	Internal bool   // -internal
+	Hide     string // -hide: package patterns, such as example.com/wrappers/..., to hide
+	Show     string // -show: package patterns, such as runtime/cgo, to show even if internal or hidden
+
+	// CompareIgnore are package patterns of volatile dependencies, such
+	// as ones that come and go with toolchain point releases, whose
+	// presence or absence never makes -check fail (-compare-ignore).
+	// They're still listed in depaware.txt. Policy files can add
+	// patterns with compare-ignore rules.
+	CompareIgnore string
+
+	// OwnInternal is how to list the main module's internal packages
+	// (-own-internal): "show" like any other package, "badge" to mark
+	// them with "(internal)", "hide" to leave them out, or "collapse"
+	// for one line per internal directory with its package count.
+	OwnInternal string
+
+	// Format is the output format (-format): "text" for the
+	// depaware.txt format, "json" for a JSON report, "metrics-json" for
+	// a one-line JSON summary of counts, "treemap" for an HTML treemap
+	// of dependencies grouped by owner (sized by bytes with Sizes),
+	// "orgs" for third-party dependency counts per owning org,
+	// "platforms" for the dependencies on only one GOOS, "buildtime"
+	// for the compile time of each module (slow: it rebuilds
+	// everything), "vex" for the packages used from each module
+	// version, to feed VEX statements, or "modules" for one line per
+	// module with its package count.
+	Format string
+
+	MaxOrgs int    // -max-orgs
+	Policy  string // -policy
+
+	// BlankImports, if non-zero, warns about blank (_) imports in the
+	// main module, such as of database drivers and image codecs, that
+	// alone pull in at least this many dependencies, with their cost
+	// (-blank-imports). -format=json lists them too.
+	BlankImports int
+
+	// NearDups warns about dependency modules whose paths differ only by
+	// case or major version, or that look like the same project on
+	// different hosts (-near-dups).
+	NearDups bool
+
+	SafeUpdate   bool   // -safe-update
+	Annotations  string // -annotations
+	EnforceTodos bool   // -enforce-todos
+
+	// Granularity is what depaware.txt tracks (-granularity): "package"
+	// for one line per package, "module" for one line per module with
+	// its version and package count, or "hybrid" for modules for
+	// third-party code and packages for the standard library and
+	// golang.org/x. If empty, it's what the existing file uses, or
+	// "package" for a new file.
+	Granularity string
+
+	OSSummary bool // -os-summary
+	Verbose   bool // -v
+
+	// LogFormat is how diagnostics are logged (-log-format): "plain" for
+	// messages like "example.com/cmd: warning: ...", or "text" or "json"
+	// for structured logs with a level and "package" and "goos"
+	// attributes, for log processors. Verbose also logs debug messages.
+	LogFormat string
+
+	Slowest int // -slowest
+
+	// StatsFile, if non-empty, is a local file to append a line of JSON
+	// to for each run (-stats-file), with how long it took and how often
+	// it read a snapshot instead of loading packages, for
+	// 'depaware stats -self' to summarize. It's never sent anywhere.
+	StatsFile string
+
+	// Sizes makes -update record the binary size attributed to each
+	// package in a .sizes file next to depaware.txt, and -check fail if
+	// a package grew by more than MaxSizeGrowth percent (-sizes). With
+	// -format=treemap, it sizes the treemap by bytes instead. The
+	// package must be a main package.
+	Sizes bool
+
+	MaxSizeGrowth int  // -max-size-growth
+	SizeWarn      bool // -size-warn
+
+	// Symbols lists the number of linked symbols each dependency
+	// contributes to the binary (-symbols). It's recorded in
+	// depaware.txt so later runs keep the column. The package must be a
+	// main package.
+	Symbols bool
+
+	Baseline string // -baseline
+
+	// Explain, with -check, is a JSON file to write if the check fails,
+	// or "stdout" (-explain), with the diff, the import chains of new
+	// dependencies, policy violations and details of the environment,
+	// for bots to attach to PRs or CI to upload.
+	Explain string
+
+	// DiffOutput, with -check, is where the diffs of out-of-date files
+	// go (-diff-output): "stderr", "stdout", "none", or a file to write
+	// them all to as one patch. Unless it's "stderr", stderr only gets a
+	// one-line summary per file.
+	DiffOutput string
+
+	// Color, with -check, is whether to color diffs written to a
+	// terminal (-color): "never", "always", or "auto" to color them if
+	// stderr (or stdout, with -diff-output=stdout) is a terminal, TERM
+	// isn't "dumb" and NO_COLOR isn't set. On Windows, "auto" and
+	// "always" turn on the console's processing of escape sequences.
+	Color string
+
+	// StdRenames, with -check, treats standard library packages renamed
+	// between Go releases as the same (-std-renames), so that files
+	// generated with the previous release still pass during a toolchain
+	// upgrade: "default" for the renames depaware knows about, or a file
+	// of additional ones, with lines like
+	// "go1.23 runtime/internal/atomic internal/runtime/atomic".
+	StdRenames string
+
+	// Compat, with -check, accepts depaware.txt files generated by up to
+	// this many minor versions of depaware older than this one, as
+	// recorded by RecordVersion, if they list the same dependencies in
+	// an older format (-compat). They're reported with a warning to
+	// regenerate them, so a new depaware version can be rolled out
+	// gradually.
+	Compat int
+
+	// Snapshot, if non-empty, is a file to write the full import graph
+	// of the package to (-snapshot), for 'depaware why', 'rdeps' and
+	// 'top' to query with -from-snapshot.
+	Snapshot string
+
+	Deep bool // -deep
+
+	// Native marks dependencies that ship non-Go code, such as
+	// "(native: c,syso)" (-native). It's recorded in depaware.txt so
+	// later runs keep the marks.
+	Native bool
+
+	// RecordVersion records the version of depaware in depaware.txt
+	// (-record-version), so later runs keep recording it and warn if the
+	// file was generated by a different version, such as a stray,
+	// globally installed one rather than the one pinned in go.mod.
+	RecordVersion bool
+
+	// NoSyso makes -check fail for dependencies with .syso files,
+	// prebuilt objects that the linker adds to the binary without any
+	// source review (-no-syso).
+	NoSyso bool
+
+	// NoPlugin makes -check fail for dependencies on the plugin package,
+	// or that otherwise only work in dynamically linked builds, such as
+	// ones linking with -ldl (-no-plugin).
+	NoPlugin bool
+
+	// VerifyCache, if non-zero, re-hashes the module cache copies of
+	// that many randomly chosen dependency modules, or all of them if
+	// negative, against go.sum, and reports mismatches, which make
+	// -check fail (-verify-cache).
+	VerifyCache int
+
+	// Stdout and Stderr are where output and diagnostics go. Nil means
+	// os.Stdout and os.Stderr.
+	Stdout, Stderr io.Writer
+
+	// Logger is where diagnostics are logged. Nil means a logger for
+	// LogFormat and Verbose that writes to Stderr.
+	Logger *slog.Logger
+
+	// FlagSet is the parsed flag set that RegisterFlags defined the
+	// flags in, if any, so that "depaware config" can tell the flags
+	// that were given from those left at their defaults. Nil means
+	// that only flags with other values than their defaults were given.
+	FlagSet *flag.FlagSet
+}
+
+// NewOptions returns the default options, as when no flags are given.
+func NewOptions() *Options {
+	o := new(Options)
+	o.RegisterFlags(flag.NewFlagSet("depaware", flag.ContinueOnError))
+	return o
+}
+
+// RegisterFlags defines the command-line flags of o in fs, setting the
+// fields of o to their defaults.
+func (o *Options) RegisterFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.Check, "check", false, "if true, check whether dependencies match the depaware.txt file")
+	fs.BoolVar(&o.Update, "update", false, "if true, update the depaware.txt file")
+	fs.StringVar(&o.File, "file", "depaware.txt", "name of the file to write")
+	fs.IntVar(&o.MaxLineBytes, "max-line-bytes", defaultMaxLineBytes, "the longest line to read from an existing depaware.txt file")
+	fs.StringVar(&o.OutputTemplate, "output-template", "", "if non-empty, a text/template for the name of each package's file")
+	fs.StringVar(&o.GOOS, "goos", "linux,darwin,windows", "comma-separated list of GOOS values")
+	fs.StringVar(&o.Tags, "tags", "", "comma-separated list of build tags to use when loading packages")
+	fs.StringVar(&o.Roots, "roots", "", "comma-separated patterns of other main packages that share the depaware.txt file")
+	fs.BoolVar(&o.RequireMain, "require-main", false, "if true, fail for packages that aren't main packages")
+	fs.BoolVar(&o.Internal, "internal", false, "if true, include internal packages in the output")
+	fs.StringVar(&o.Hide, "hide", "", "comma-separated package patterns to hide from the output")
+	fs.StringVar(&o.Show, "show", "", "comma-separated package patterns to show even if internal or hidden")
+	fs.StringVar(&o.CompareIgnore, "compare-ignore", "", "comma-separated package patterns whose changes never make -check fail")
+	fs.StringVar(&o.OwnInternal, "own-internal", "", `how to list the main module's internal packages: "show", "badge", "hide" or "collapse"`)
+	fs.StringVar(&o.Format, "format", "text", "output format: text, json, metrics-json, treemap, orgs, platforms, buildtime, vex or modules")
+	fs.IntVar(&o.MaxOrgs, "max-orgs", 0, "if non-zero, fail if a package depends on more than this many distinct third-party orgs")
+	fs.StringVar(&o.Policy, "policy", "", "if non-empty, the name of a policy file whose rules the dependencies must follow")
+	fs.IntVar(&o.BlankImports, "blank-imports", 0, "if non-zero, warn about blank imports that pull in at least this many dependencies")
+	fs.BoolVar(&o.NearDups, "near-dups", false, "if true, warn about dependency modules whose paths are nearly the same")
+	fs.BoolVar(&o.SafeUpdate, "safe-update", false, "if true, -update refuses to overwrite a file with merge conflict markers or that doesn't parse")
+	fs.StringVar(&o.Annotations, "annotations", "", "if non-empty, the name of a JSON file to write editor annotations to, mapping import statements to the new dependencies they introduce")
+	fs.BoolVar(&o.EnforceTodos, "enforce-todos", false, "if true, -check fails for dependencies annotated with a remove-by date that has passed")
+	fs.StringVar(&o.Granularity, "granularity", "", `what depaware.txt tracks: "package", "module" or "hybrid"`)
+	fs.BoolVar(&o.OSSummary, "os-summary", false, "if true, end the depaware.txt file with the number of dependencies on each GOOS")
+	fs.BoolVar(&o.Verbose, "v", false, "if true, report how long loading, merging and writing took, and log debug messages")
+	fs.StringVar(&o.LogFormat, "log-format", "plain", `how to log diagnostics on stderr: "plain", "text" or "json"`)
+	fs.IntVar(&o.Slowest, "slowest", 10, "with -v, the number of slowest timings to report")
+	fs.StringVar(&o.StatsFile, "stats-file", "", "if non-empty, the name of a local file to append run statistics to")
+	fs.BoolVar(&o.Sizes, "sizes", false, "if true, record and check the binary size attributed to each package")
+	fs.IntVar(&o.MaxSizeGrowth, "max-size-growth", 10, "with -sizes, the percentage by which a package's attributed size may grow")
+	fs.BoolVar(&o.SizeWarn, "size-warn", false, "with -sizes, only warn about size growth instead of failing -check")
+	fs.BoolVar(&o.Symbols, "symbols", false, "if true, list the number of linked symbols each dependency contributes")
+	fs.StringVar(&o.Baseline, "baseline", "", "if non-empty, the name of a baseline file of accepted policy violations, as written by 'depaware policy baseline'")
+	fs.StringVar(&o.Explain, "explain", "", `with -check, the name of a JSON file, or "stdout", to explain a failed check in`)
+	fs.StringVar(&o.DiffOutput, "diff-output", "stderr", `with -check, where to write diffs: "stderr", "stdout", "none" or a file name`)
+	fs.StringVar(&o.Color, "color", "never", `with -check, whether to color diffs: "never", "always" or "auto"`)
+	fs.IntVar(&o.Compat, "compat", 0, "with -check, how many minor versions older depaware.txt files may be")
+	fs.StringVar(&o.StdRenames, "std-renames", "", `with -check, "default" or a file of standard library renames to accept`)
+	fs.StringVar(&o.Snapshot, "snapshot", "", "if non-empty, the name of a file to write the full import graph to")
+	fs.BoolVar(&o.Deep, "deep", false, "if true, -format=json and -format=treemap include the files each dependency is compiled from")
+	fs.BoolVar(&o.Native, "native", false, "if true, mark dependencies that ship non-Go code")
+	fs.BoolVar(&o.RecordVersion, "record-version", false, "if true, record the version of depaware in depaware.txt")
+	fs.BoolVar(&o.NoSyso, "no-syso", false, "if true, -check fails for dependencies with .syso files")
+	fs.BoolVar(&o.NoPlugin, "no-plugin", false, "if true, -check fails for dependencies that need dynamic linking")
+	fs.IntVar(&o.VerifyCache, "verify-cache", 0, "if non-zero, the number of dependency modules to verify in the module cache, or -1 for all")
+}
@@ -0,0 +1,51 @@
+package depaware
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewOptions(t *testing.T) {
+	o := NewOptions()
+	if o.MaxLineBytes != defaultMaxLineBytes || o.DiffOutput != "stderr" || o.Check {
+		t.Errorf("unexpected defaults: %+v", o)
+	}
+}
+
+func TestRunConcurrent(t *testing.T) {
+	const pkg = "github.com/tailscale/depaware"
+	run := func(internal bool) (string, error) {
+		var stdout, stderr bytes.Buffer
+		o := NewOptions()
+		o.Internal = internal
+		o.Stdout, o.Stderr = &stdout, &stderr
+		err := Run(o, []string{pkg})
+		return stdout.String(), err
+	}
+	var wg sync.WaitGroup
+	var outs [2]string
+	var errs [2]error
+	for i := range outs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			outs[i], errs[i] = run(i == 1)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("run %d: %v", i, err)
+		}
+	}
+	if !strings.HasPrefix(outs[0], pkg+" dependencies:") || outs[0] == outs[1] {
+		t.Fatalf("runs with and without -internal wrote the same or unexpected output:\n%s", outs[0])
+	}
+	for i, want := range outs {
+		if got, _ := run(i == 1); got != want {
+			t.Errorf("concurrent run %d wrote\n%s\nwant\n%s", i, want, got)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// orgCount is the number of dependency packages owned by an org.
+type orgCount struct {
+	Org   string
+	Count int
+}
+
+// OrgCounts returns the number of third-party dependency packages per
+// owning org, as returned by depOwner, sorted by decreasing count.
+// Packages from the standard library, golang.org/x, and the main module
+// aren't third-party and aren't counted.
+func (d *deps) OrgCounts() []orgCount {
+	counts := map[string]int{}
+	for _, pkg := range d.Deps {
+		if !isThirdPartyPath(pkg, d.MainModule) {
+			continue
+		}
+		counts[depOwner(pkg)]++
+	}
+	orgs := make([]orgCount, 0, len(counts))
+	for org, n := range counts {
+		orgs = append(orgs, orgCount{org, n})
+	}
+	sort.Slice(orgs, func(i, j int) bool {
+		if orgs[i].Count != orgs[j].Count {
+			return orgs[i].Count > orgs[j].Count
+		}
+		return orgs[i].Org < orgs[j].Org
+	})
+	return orgs
+}
+
+// isThirdPartyPath reports whether pkg is neither part of Go (the
+// standard library or golang.org/x) nor part of the main module, whose
+// path may be empty if unknown.
+func isThirdPartyPath(pkg, mainModule string) bool {
+	if owner := depOwner(pkg); owner == "std" || owner == "golang.org/x" {
+		return false
+	}
+	return mainModule == "" || !inModule(pkg, mainModule)
+}
+
+// inModule reports whether the package path pkg is within the module
+// path modPath. It doesn't know about nested modules.
+func inModule(pkg, modPath string) bool {
+	return pkg == modPath || strings.HasPrefix(pkg, modPath+"/")
+}
+
+// orgConcentration summarizes how concentrated the third-party
+// dependencies are among their owners.
+type orgConcentration struct {
+	Orgs         int     `json:"orgs"`                   // distinct third-party orgs
+	Packages     int     `json:"packages"`               // third-party packages
+	Largest      string  `json:"largest,omitempty"`      // org owning the most packages
+	LargestShare float64 `json:"largestShare,omitempty"` // fraction of Packages owned by Largest
+}
+
+// concentration returns the concentration of orgs, as returned by
+// OrgCounts.
+func concentration(orgs []orgCount) orgConcentration {
+	c := orgConcentration{Orgs: len(orgs)}
+	for _, o := range orgs {
+		c.Packages += o.Count
+	}
+	if len(orgs) > 0 {
+		c.Largest = orgs[0].Org
+		c.LargestShare = float64(orgs[0].Count) / float64(c.Packages)
+	}
+	return c
+}
+
+// writeOrgCounts writes the third-party org counts of pkg's
+// dependencies to w, with each org's share of the third-party packages.
+func writeOrgCounts(w io.Writer, pkg string, d *deps) {
+	orgs := d.OrgCounts()
+	c := concentration(orgs)
+	fmt.Fprintf(w, "%s third-party dependencies by org:\n\n", pkg)
+	for _, o := range orgs {
+		fmt.Fprintf(w, " %5d %3.0f%% %s\n", o.Count, 100*float64(o.Count)/float64(c.Packages), o.Org)
+	}
+	fmt.Fprintf(w, "\n%d distinct orgs", c.Orgs)
+	if c.Largest != "" {
+		fmt.Fprintf(w, "; %s owns the largest share, %.0f%% of %d packages", c.Largest, 100*c.LargestShare, c.Packages)
+	}
+	fmt.Fprintln(w)
+}
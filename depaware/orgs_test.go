@@ -0,0 +1,52 @@
+package depaware
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestOrgCounts(t *testing.T) {
+	d := &deps{
+		Deps: []string{
+			"bytes",
+			"golang.org/x/sys/unix",
+			"example.com/me/internal/util",
+			"github.com/a/one",
+			"github.com/a/two",
+			"github.com/b/one",
+			"k8s.io/api",
+		},
+		MainModule: "example.com/me",
+	}
+	want := []orgCount{
+		{"github.com/a", 2},
+		{"github.com/b", 1},
+		{"k8s.io", 1},
+	}
+	if got := d.OrgCounts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestWriteOrgCounts(t *testing.T) {
+	d := &deps{
+		Deps:       []string{"bytes", "github.com/a/one", "github.com/a/two", "github.com/a/three", "github.com/b/one"},
+		MainModule: "example.com/me",
+	}
+	var buf bytes.Buffer
+	writeOrgCounts(&buf, "example.com/me", d)
+	const want = `example.com/me third-party dependencies by org:
+
+     3  75% github.com/a
+     1  25% github.com/b
+
+2 distinct orgs; github.com/a owns the largest share, 75% of 4 packages
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if c := concentration(nil); c != (orgConcentration{}) {
+		t.Errorf("concentration(nil) = %+v; want zero", c)
+	}
+}
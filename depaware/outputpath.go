@@ -0,0 +1,64 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// outputPathData is what -output-template is executed with.
+type outputPathData struct {
+	Dir        string // the package's directory
+	ImportPath string // the package's import path
+	PkgName    string // the last element of the import path, not counting a major version suffix
+}
+
+// parseOutputTemplate parses the -output-template flag value s.
+func parseOutputTemplate(s string) (*template.Template, error) {
+	return template.New("output").Option("missingkey=error").Parse(s)
+}
+
+// depawareFile returns the name of the depaware.txt file of pkg, whose
+// directory is dir: from -output-template if set, and otherwise -file
+// in dir, as named by sharedFileName. It's an error for two packages of
+// the run to get the same file, as one would overwrite the other's.
+func (r *runner) depawareFile(pkg, dir string) (string, error) {
+	if r.outputTmpl == nil {
+		name := filepath.Join(dir, sharedFileName(pkg, r.File))
+		if other, ok := r.outputFiles[name]; ok && other != pkg {
+			return "", fmt.Errorf("%s and %s would both write %s; name their files apart with -output-template", other, pkg, name)
+		}
+		r.outputFiles[name] = pkg
+		return name, nil
+	}
+	var buf bytes.Buffer
+	if err := r.outputTmpl.Execute(&buf, outputPathData{Dir: dir, ImportPath: pkg, PkgName: lastElem(pkg)}); err != nil {
+		return "", fmt.Errorf("-output-template: %v", err)
+	}
+	if strings.TrimSpace(buf.String()) == "" {
+		return "", fmt.Errorf("-output-template is empty for %s", pkg)
+	}
+	name := filepath.Clean(filepath.FromSlash(buf.String()))
+	if other, ok := r.outputFiles[name]; ok && other != pkg {
+		return "", fmt.Errorf("-output-template names %s for both %s and %s; use {{.ImportPath}} to tell them apart", name, other, pkg)
+	}
+	r.outputFiles[name] = pkg
+	return name, nil
+}
+
+// lastElem returns the last element of the import path pkg, skipping a
+// major version suffix such as "/v2".
+func lastElem(pkg string) string {
+	base := path.Base(pkg)
+	if len(base) > 1 && base[0] == 'v' && strings.Trim(base[1:], "0123456789") == "" && path.Dir(pkg) != "." {
+		return path.Base(path.Dir(pkg))
+	}
+	return base
+}
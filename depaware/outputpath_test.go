@@ -0,0 +1,37 @@
+package depaware
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDepawareFile(t *testing.T) {
+	tmpl, err := parseOutputTemplate("{{.Dir}}/deps/{{.PkgName}}.depaware.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &runner{outputTmpl: tmpl, outputFiles: make(map[string]string)}
+	got, err := r.depawareFile("example.com/foo/v2", "/src/foo")
+	if want := filepath.FromSlash("/src/foo/deps/foo.depaware.txt"); err != nil || got != want {
+		t.Errorf("got %q, %v; want %q", got, err, want)
+	}
+	// Templates that name the same file for two packages are an error.
+	if _, err := r.depawareFile("example.com/bar/foo", "/src/foo"); err == nil || !strings.Contains(err.Error(), "for both example.com/foo/v2 and example.com/bar/foo") {
+		t.Errorf("shared file: got error %v", err)
+	}
+}
+
+func TestLastElem(t *testing.T) {
+	for pkg, want := range map[string]string{
+		"example.com/foo":    "foo",
+		"example.com/foo/v2": "foo",
+		"example.com/v2/foo": "foo",
+		"v2":                 "v2",
+		"fmt":                "fmt",
+	} {
+		if got := lastElem(pkg); got != want {
+			t.Errorf("lastElem(%q) = %q; want %q", pkg, got, want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// isOwnInternal reports whether pkg is an internal package of the main
+// module. Unlike the internal packages of the standard library and
+// golang.org/x, isInternalPackage doesn't hide those.
+func (d *deps) isOwnInternal(pkg string) bool {
+	m, ok := d.Module[pkg]
+	return ok && d.MainModule != "" && m.Path == d.MainModule && internalRoot(pkg) != ""
+}
+
+// internalRoot returns the path of the "internal" directory pkg is in,
+// such as "example.com/m/internal" for "example.com/m/internal/foo",
+// or the empty string if it's not in one.
+func internalRoot(pkg string) string {
+	if strings.HasSuffix(pkg, "/internal") {
+		return pkg
+	}
+	if i := strings.LastIndex(pkg, "/internal/"); i >= 0 {
+		return pkg[:i+len("/internal")]
+	}
+	return ""
+}
+
+// ownInternalEntries applies the -own-internal mode to entries, whose
+// OS letters are relative to geese:
+// "show" leaves the main module's internal packages as they are,
+// "badge" marks them as such, "hide" removes them, and "collapse"
+// replaces the packages of each internal directory by a single
+// "dir/..." entry with their count.
+func (d *deps) ownInternalEntries(entries []fileEntry, geese []string, mode string) ([]fileEntry, error) {
+	switch mode {
+	case "", "show":
+		return entries, nil
+	case "badge", "hide", "collapse":
+	default:
+		return nil, fmt.Errorf("unknown own-internal mode %q", mode)
+	}
+	out := entries[:0:0]
+	collapsed := make(map[string]int) // root -> index in out
+	for _, e := range entries {
+		if e.Count > 0 || !d.isOwnInternal(e.Pkg) {
+			out = append(out, e)
+			continue
+		}
+		switch mode {
+		case "badge":
+			e.OwnInternal = true
+			out = append(out, e)
+		case "collapse":
+			root := internalRoot(e.Pkg)
+			i, ok := collapsed[root]
+			if !ok {
+				i = len(out)
+				collapsed[root] = i
+				out = append(out, fileEntry{Pkg: root + "/...", OS: e.OS})
+			}
+			c := &out[i]
+			c.Count++
+			c.Unsafe = c.Unsafe || e.Unsafe
+			c.CGO = c.CGO || e.CGO
+			c.OS = unionOS(geese, c.OS, e.OS)
+		}
+	}
+	return out, nil
+}
+
+// unionOS returns the OS letters, relative to geese, of an entry on
+// the platforms of either of the OS letters a and b, where empty
+// means all.
+func unionOS(geese []string, a, b string) string {
+	if a == "" || b == "" {
+		return ""
+	}
+	var union []rune
+	for _, goos := range geese {
+		r := unicode.ToUpper(rune(goos[0]))
+		if strings.ContainsRune(a, r) || strings.ContainsRune(b, r) {
+			union = append(union, r)
+		}
+	}
+	if len(union) == len(geese) {
+		return ""
+	}
+	return string(union)
+}
@@ -0,0 +1,100 @@
+package depaware
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+func testOwnInternalDeps() (*deps, []fileEntry) {
+	d := &deps{
+		MainModule: "example.com/m",
+		Module: map[string]module.Version{
+			"example.com/m/internal/a":      {Path: "example.com/m"},
+			"example.com/m/internal/a/b":    {Path: "example.com/m"},
+			"example.com/m/pkg":             {Path: "example.com/m"},
+			"example.com/m/pkg/internal":    {Path: "example.com/m"},
+			"github.com/foo/bar/internal/x": {Path: "github.com/foo/bar", Version: "v1.0.0"},
+		},
+	}
+	entries := []fileEntry{
+		{Pkg: "example.com/m/internal/a", OS: "L", Why: "example.com/m/cmd"},
+		{Pkg: "example.com/m/internal/a/b", OS: "W", Unsafe: true, Why: "example.com/m/internal/a"},
+		{Pkg: "example.com/m/pkg", Why: "example.com/m/cmd"},
+		{Pkg: "example.com/m/pkg/internal", Why: "example.com/m/pkg"},
+		{Pkg: "github.com/foo/bar/internal/x", Why: "example.com/m/pkg"},
+		{Pkg: "fmt", Why: "example.com/m/cmd"},
+	}
+	for _, e := range entries {
+		d.Deps = append(d.Deps, e.Pkg)
+	}
+	return d, entries
+}
+
+func TestOwnInternalEntries(t *testing.T) {
+	geese := []string{"linux", "darwin", "windows"}
+	d, entries := testOwnInternalDeps()
+
+	got, err := d.ownInternalEntries(append([]fileEntry(nil), entries...), geese, "hide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []fileEntry{entries[2], entries[4], entries[5]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hide: got %+v; want %+v", got, want)
+	}
+
+	got, err = d.ownInternalEntries(append([]fileEntry(nil), entries...), geese, "collapse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []fileEntry{
+		{Pkg: "example.com/m/internal/...", OS: "LW", Unsafe: true, Count: 2},
+		entries[2],
+		{Pkg: "example.com/m/pkg/internal/...", Count: 1},
+		entries[4],
+		entries[5],
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collapse: got %+v; want %+v", got, want)
+	}
+
+	got, err = d.ownInternalEntries(append([]fileEntry(nil), entries...), geese, "badge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var badged []string
+	for _, e := range got {
+		if e.OwnInternal {
+			badged = append(badged, e.Pkg)
+		}
+	}
+	if want := []string{"example.com/m/internal/a", "example.com/m/internal/a/b", "example.com/m/pkg/internal"}; !reflect.DeepEqual(badged, want) {
+		t.Errorf("badge: got %q; want %q", badged, want)
+	}
+
+	if _, err := d.ownInternalEntries(entries, geese, "bogus"); err == nil {
+		t.Error("unexpected success for unknown mode")
+	}
+}
+
+func TestOwnInternalRoundTrip(t *testing.T) {
+	f := &depsFile{
+		Pkg:        "example.com/m/cmd",
+		Directives: map[string]string{"own-internal": "badge"},
+		Entries: []fileEntry{
+			{Pkg: "example.com/m/internal/a", Why: "example.com/m/cmd", OwnInternal: true, Symbols: 12},
+			{Pkg: "example.com/m/pkg/internal/...", Count: 2},
+		},
+	}
+	var buf bytes.Buffer
+	writeDepsFile(&buf, f)
+	got, err := parseDepsFile(&buf, defaultMaxLineBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f, got) {
+		t.Errorf("want=%+v got=%+v", f, got)
+	}
+}
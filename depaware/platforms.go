@@ -0,0 +1,70 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// OSCounts returns the number of dependencies of d on each of geese.
+func (d *deps) OSCounts(geese []string) map[string]int {
+	counts := make(map[string]int, len(geese))
+	for _, goos := range geese {
+		counts[goos] = 0
+		for _, pkg := range d.Deps {
+			if d.DepOnOS[pkgGOOS{pkg, goos}] {
+				counts[goos]++
+			}
+		}
+	}
+	return counts
+}
+
+// osSummary formats counts as returned by OSCounts as a single line,
+// such as "linux: 412, darwin: 398, windows: 455", in the order of geese.
+func osSummary(geese []string, counts map[string]int) string {
+	parts := make([]string, len(geese))
+	for i, goos := range geese {
+		parts[i] = fmt.Sprintf("%s: %d", goos, counts[goos])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ExclusiveDeps returns the dependencies of d on exactly one of geese,
+// keyed by that GOOS, in the order of d.Deps.
+func (d *deps) ExclusiveDeps(geese []string) map[string][]string {
+	m := make(map[string][]string)
+	for _, pkg := range d.Deps {
+		var only string
+		n := 0
+		for _, goos := range geese {
+			if d.DepOnOS[pkgGOOS{pkg, goos}] {
+				only = goos
+				n++
+			}
+		}
+		if n == 1 {
+			m[only] = append(m[only], pkg)
+		}
+	}
+	return m
+}
+
+// writeExclusiveDeps writes the -format=platforms report to w: the
+// dependencies of pkg on only one of geese, grouped by GOOS, each with
+// the chain of imports that pulls it in.
+func writeExclusiveDeps(w io.Writer, pkg string, d *deps, geese []string, entries []fileEntry) {
+	in := &policyInput{Pkg: pkg, Entries: entries}
+	excl := d.ExclusiveDeps(geese)
+	fmt.Fprintf(w, "%s platform-exclusive dependencies:\n", pkg)
+	for _, goos := range geese {
+		fmt.Fprintf(w, "\n%s only (%d packages):\n", goos, len(excl[goos]))
+		for _, dep := range excl[goos] {
+			fmt.Fprintf(w, "\t%s\n", strings.Join(in.whyChain(dep), " -> "))
+		}
+	}
+}
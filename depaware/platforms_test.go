@@ -0,0 +1,63 @@
+package depaware
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestOSCounts(t *testing.T) {
+	geese := []string{"linux", "darwin", "windows"}
+	d := new(deps)
+	d.AddDep("bytes", "linux")
+	d.AddDep("bytes", "darwin")
+	d.AddDep("bytes", "windows")
+	d.AddDep("golang.org/x/sys/unix", "linux")
+	d.AddDep("golang.org/x/sys/unix", "darwin")
+	d.AddDep("golang.org/x/sys/windows", "windows")
+	d.AddDep("golang.org/x/sys/windows/svc", "windows")
+	got := d.OSCounts(geese)
+	want := map[string]int{"linux": 2, "darwin": 2, "windows": 3}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OSCounts = %v; want %v", got, want)
+	}
+	if got, want := osSummary(geese, got), "linux: 2, darwin: 2, windows: 3"; got != want {
+		t.Errorf("osSummary = %q; want %q", got, want)
+	}
+}
+
+func TestExclusiveDeps(t *testing.T) {
+	geese := []string{"linux", "darwin", "windows"}
+	d := new(deps)
+	d.AddEdge("example.com/cmd", "golang.org/x/sys/windows/svc")
+	d.AddEdge("golang.org/x/sys/windows/svc", "golang.org/x/sys/windows")
+	for _, goos := range geese {
+		d.AddDep("bytes", goos)
+	}
+	d.AddDep("golang.org/x/sys/unix", "linux")
+	d.AddDep("golang.org/x/sys/unix", "darwin")
+	d.AddDep("golang.org/x/sys/windows/svc", "windows")
+	d.AddDep("golang.org/x/sys/windows", "windows")
+	want := map[string][]string{
+		"windows": {"golang.org/x/sys/windows/svc", "golang.org/x/sys/windows"},
+	}
+	if got := d.ExclusiveDeps(geese); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExclusiveDeps = %v; want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	writeExclusiveDeps(&buf, "example.com/cmd", d, geese, d.Entries(geese, nil))
+	wantOut := `example.com/cmd platform-exclusive dependencies:
+
+linux only (0 packages):
+
+darwin only (0 packages):
+
+windows only (2 packages):
+	example.com/cmd -> golang.org/x/sys/windows/svc
+	example.com/cmd -> golang.org/x/sys/windows/svc -> golang.org/x/sys/windows
+`
+	if got := buf.String(); got != wantOut {
+		t.Errorf("got:\n%s\nwant:\n%s", got, wantOut)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// dynamicLDFlags are the cgo linker flags that make a package need
+// dynamic linking.
+var dynamicLDFlags = []string{"-ldl", "-rdynamic", "-shared"}
+
+// dynamicReason returns why pkg only works in dynamically linked
+// builds, or the empty string if it doesn't as far as depaware can
+// tell: it's the plugin package, or a package outside the standard
+// library and golang.org/x whose Go files import symbols from shared
+// libraries with //go:cgo_import_dynamic or link with flags such as
+// -ldl.
+func (d *deps) dynamicReason(pkg string) string {
+	if pkg == "plugin" {
+		return "loads Go plugins"
+	}
+	if isGoPackage(pkg) {
+		return ""
+	}
+	for _, file := range d.GoFiles[pkg] {
+		if reason := dynamicDirective(file); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// dynamicDirective returns the first directive in the named Go file
+// that needs dynamic linking, described for dynamicReason, or the empty
+// string if there's none or the file can't be read.
+func dynamicDirective(name string) string {
+	f, err := os.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		if strings.HasPrefix(line, "//go:cgo_import_dynamic ") {
+			return "imports symbols from shared libraries with //go:cgo_import_dynamic"
+		}
+		// #cgo directives are in the comment preceding import "C",
+		// which may be a // comment or a /* */ one.
+		line = strings.TrimSpace(strings.TrimPrefix(line, "//"))
+		if !strings.HasPrefix(line, "#cgo ") || !strings.Contains(line, "LDFLAGS:") {
+			continue
+		}
+		for _, flag := range strings.Fields(line[strings.Index(line, "LDFLAGS:")+len("LDFLAGS:"):]) {
+			for _, dyn := range dynamicLDFlags {
+				if flag == dyn {
+					return "links with " + flag
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// pluginViolations returns a message for each dependency of pkg that
+// only works in dynamically linked builds, for -no-plugin, with the
+// chain of imports that pulls it in.
+func (d *deps) pluginViolations(pkg string) []string {
+	var msgs []string
+	for _, dep := range d.Deps {
+		if reason := d.dynamicReason(dep); reason != "" {
+			msgs = append(msgs, fmt.Sprintf("%s %s, which rules out static builds, imported via %s",
+				dep, reason, strings.Join(d.shortestChain(pkg, dep), " -> ")))
+		}
+	}
+	return msgs
+}
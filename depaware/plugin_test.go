@@ -0,0 +1,55 @@
+package depaware
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDynamicDirective(t *testing.T) {
+	dir := t.TempDir()
+	for src, want := range map[string]string{
+		"package a\n": "",
+		"package a\n\n// #cgo LDFLAGS: -lm\nimport \"C\"\n":                 "",
+		"package a\n\n// #cgo linux LDFLAGS: -lm -ldl\nimport \"C\"\n":      "links with -ldl",
+		"package a\n\n//go:cgo_import_dynamic libc_open open \"libc.so\"\n": "imports symbols from shared libraries with //go:cgo_import_dynamic",
+	} {
+		name := filepath.Join(dir, "a.go")
+		if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := dynamicDirective(name); got != want {
+			t.Errorf("dynamicDirective(%q) = %q; want %q", src, got, want)
+		}
+	}
+}
+
+func TestPluginViolations(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "dl.go")
+	if err := ioutil.WriteFile(file, []byte("package dl\n\n// #cgo LDFLAGS: -ldl\nimport \"C\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d := new(deps)
+	d.AddEdge("example.com/cmd", "example.com/loader")
+	d.AddEdge("example.com/loader", "plugin")
+	d.AddEdge("example.com/cmd", "github.com/x/dl")
+	d.AddEdge("example.com/cmd", "fmt")
+	for _, pkg := range []string{"example.com/loader", "plugin", "github.com/x/dl", "fmt"} {
+		d.AddDep(pkg, "linux")
+	}
+	d.AddGoFiles("github.com/x/dl", []string{file})
+	got := strings.Join(d.pluginViolations("example.com/cmd"), "\n")
+	for _, want := range []string{
+		"plugin loads Go plugins, which rules out static builds, imported via example.com/cmd -> example.com/loader -> plugin",
+		"github.com/x/dl links with -ldl",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("violations don't contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "fmt") {
+		t.Errorf("fmt reported:\n%s", got)
+	}
+}
@@ -0,0 +1,383 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A policy is a set of rules that dependencies must follow, read from
+// the file named by the -policy flag.
+//
+// The file has one rule per line. Blank lines and lines starting with
+// "#" are ignored. Each rule is a rule kind followed by its arguments,
+// optionally followed by "severity=error", "severity=warn" or
+// "severity=info" (the default is error):
+//
+//	deny github.com/evil/...              # no dependency may match
+//	deny-unsafe github.com/...            # matching deps may not use unsafe
+//	deny-cgo ...                          # matching deps may not use cgo
+//	max-orgs 10 severity=warn             # at most 10 third-party orgs
+//	migrate github.com/golang/protobuf google.golang.org/protobuf severity=info
+//	compare-ignore golang.org/x/exp/...   # never fails -check; see -compare-ignore
+//	declare-blank-imports 20              # blank imports pulling in 20+ deps must be allowed
+//	allow-blank-import github.com/lib/pq  # a blank import that's intended
+//
+// A migrate rule reports each package still imported from the old
+// module, with the import chain that pulls it in, until none remain.
+// A declare-blank-imports rule reports each blank (_) import in the
+// main module that alone pulls in at least that many dependencies,
+// unless an allow-blank-import rule matches the imported package. It
+// needs the packages' source, so it's only checked when they're loaded,
+// not against committed depaware.txt files.
+// Package patterns use the go command's "..." wildcard syntax.
+// Only violations of error rules make -check fail; warnings and
+// informational violations are printed but otherwise ignored, so new
+// rules can be introduced as warnings before they're enforced.
+type policy struct {
+	Rules []*policyRule
+}
+
+// policyRule is a single rule of a policy.
+type policyRule struct {
+	Line     int      // line number in the policy file
+	Text     string   // rule as written, without the severity
+	Kind     string   // "deny", "deny-unsafe", "deny-cgo", "max-orgs", "migrate", "compare-ignore", "declare-blank-imports", "allow-blank-import"
+	Args     []string // arguments following Kind
+	Severity severity
+
+	match func(pkg string) bool // for pattern rules
+	max   int                   // for max-orgs and declare-blank-imports
+}
+
+// severity is how seriously a policy violation is taken.
+type severity int
+
+const (
+	severityInfo severity = iota
+	severityWarn
+	severityError
+)
+
+var severityNames = map[severity]string{
+	severityInfo:  "info",
+	severityWarn:  "warn",
+	severityError: "error",
+}
+
+func (s severity) String() string { return severityNames[s] }
+
+// logLevel returns the level to log violations of severity s at.
+func (s severity) logLevel() slog.Level {
+	switch s {
+	case severityInfo:
+		return slog.LevelInfo
+	case severityWarn:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
+
+func (s severity) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+func (s *severity) UnmarshalText(b []byte) error {
+	for v, name := range severityNames {
+		if name == string(b) {
+			*s = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", b)
+}
+
+// violation is a dependency that breaks a policy rule.
+type violation struct {
+	Rule     string   `json:"rule"`
+	Line     int      `json:"line"`
+	Severity severity `json:"severity"`
+	Package  string   `json:"package,omitempty"` // dependency at fault, if any
+	Message  string   `json:"message"`
+}
+
+func (v violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Severity, v.detail())
+}
+
+// detail returns the message of v with the rule it violates.
+func (v violation) detail() string {
+	return fmt.Sprintf("%s (policy line %d: %s)", v.Message, v.Line, v.Rule)
+}
+
+// readPolicy reads and parses the named policy file.
+func readPolicy(name string) (*policy, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := parsePolicy(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return p, nil
+}
+
+// parsePolicy parses a policy file. See policy for the format.
+func parsePolicy(r io.Reader) (*policy, error) {
+	p := new(policy)
+	scan := bufio.NewScanner(r)
+	lineNum := 0
+	for scan.Scan() {
+		lineNum++
+		line := scan.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+		rule := &policyRule{Line: lineNum, Severity: severityError}
+		if last := words[len(words)-1]; strings.HasPrefix(last, "severity=") {
+			if err := rule.Severity.UnmarshalText([]byte(strings.TrimPrefix(last, "severity="))); err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			words = words[:len(words)-1]
+		}
+		rule.Kind, rule.Args = words[0], words[1:]
+		rule.Text = strings.Join(words, " ")
+		if err := rule.init(); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		p.Rules = append(p.Rules, rule)
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// init validates the rule's arguments and sets its unexported fields.
+func (r *policyRule) init() error {
+	switch r.Kind {
+	case "deny", "deny-unsafe", "deny-cgo", "compare-ignore", "allow-blank-import":
+		if len(r.Args) != 1 {
+			return fmt.Errorf("%s takes one package pattern", r.Kind)
+		}
+		r.match = matchPattern(r.Args[0])
+	case "max-orgs", "declare-blank-imports":
+		if len(r.Args) != 1 {
+			return fmt.Errorf("%s takes one number", r.Kind)
+		}
+		n, err := strconv.Atoi(r.Args[0])
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s: bad number %q", r.Kind, r.Args[0])
+		}
+		r.max = n
+	case "migrate":
+		if len(r.Args) != 2 {
+			return fmt.Errorf("%s takes an old and a new module path", r.Kind)
+		}
+		old := r.Args[0]
+		r.match = func(pkg string) bool { return inModule(pkg, old) }
+	default:
+		return fmt.Errorf("unknown rule %q", r.Kind)
+	}
+	return nil
+}
+
+// needsBlankImports reports whether p has a declare-blank-imports rule,
+// for which the blank imports must be found.
+func (p *policy) needsBlankImports() bool {
+	for _, r := range p.Rules {
+		if r.Kind == "declare-blank-imports" {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsBlankImport reports whether an allow-blank-import rule of p
+// matches pkg.
+func (p *policy) allowsBlankImport(pkg string) bool {
+	for _, r := range p.Rules {
+		if r.Kind == "allow-blank-import" && r.match(pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyInput is what a policy is evaluated against: the dependencies
+// of a single package, either freshly loaded or parsed from a
+// depaware.txt file.
+type policyInput struct {
+	Pkg        string
+	Entries    []fileEntry
+	MainModule string // main module path, or empty if unknown
+
+	// BlankImports are the main module's blank imports, or nil if the
+	// packages' source isn't available.
+	BlankImports []blankImport
+}
+
+// Evaluate returns the violations of p by in, sorted by package and
+// then rule.
+func (p *policy) Evaluate(in *policyInput) []violation {
+	var vs []violation
+	for _, r := range p.Rules {
+		add := func(pkg, msg string) {
+			vs = append(vs, violation{
+				Rule:     r.Text,
+				Line:     r.Line,
+				Severity: r.Severity,
+				Package:  pkg,
+				Message:  msg,
+			})
+		}
+		switch r.Kind {
+		case "deny":
+			for _, e := range in.Entries {
+				if r.match(e.Pkg) {
+					add(e.Pkg, fmt.Sprintf("%s depends on denied package %s", in.Pkg, e.Pkg))
+				}
+			}
+		case "deny-unsafe":
+			for _, e := range in.Entries {
+				if e.Unsafe && r.match(e.Pkg) {
+					add(e.Pkg, fmt.Sprintf("%s uses unsafe", e.Pkg))
+				}
+			}
+		case "deny-cgo":
+			for _, e := range in.Entries {
+				if e.CGO && r.match(e.Pkg) {
+					add(e.Pkg, fmt.Sprintf("%s uses cgo", e.Pkg))
+				}
+			}
+		case "max-orgs":
+			orgs := map[string]bool{}
+			for _, e := range in.Entries {
+				if isThirdPartyPath(e.Pkg, in.MainModule) {
+					orgs[depOwner(e.Pkg)] = true
+				}
+			}
+			if len(orgs) > r.max {
+				add("", fmt.Sprintf("%s depends on %d distinct third-party orgs; at most %d allowed", in.Pkg, len(orgs), r.max))
+			}
+		case "declare-blank-imports":
+			for _, b := range costlyBlankImports(in.BlankImports, r.max) {
+				if !p.allowsBlankImport(b.Import) {
+					add(b.Import, b.String()+", and isn't declared with allow-blank-import")
+				}
+			}
+		case "migrate":
+			var remaining []fileEntry
+			for _, e := range in.Entries {
+				if r.match(e.Pkg) {
+					remaining = append(remaining, e)
+				}
+			}
+			for _, e := range remaining {
+				add(e.Pkg, fmt.Sprintf("%s still uses %s (%d packages left to migrate to %s): %s",
+					in.Pkg, e.Pkg, len(remaining), r.Args[1], strings.Join(in.whyChain(e.Pkg), " -> ")))
+			}
+		}
+	}
+	sort.SliceStable(vs, func(i, j int) bool {
+		if vs[i].Package != vs[j].Package {
+			return vs[i].Package < vs[j].Package
+		}
+		return vs[i].Line < vs[j].Line
+	})
+	return vs
+}
+
+// whyChain returns a chain of imports from in.Pkg to pkg, following
+// each entry's Why. The chain is incomplete if an entry on the way
+// is missing.
+func (in *policyInput) whyChain(pkg string) []string {
+	why := make(map[string]string, len(in.Entries))
+	for _, e := range in.Entries {
+		why[e.Pkg] = e.Why
+	}
+	chain := []string{pkg}
+	seen := map[string]bool{pkg: true}
+	for p := pkg; p != in.Pkg; {
+		p = why[p]
+		if p == "" || seen[p] {
+			break
+		}
+		seen[p] = true
+		chain = append(chain, p)
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
+
+// hasErrors reports whether any of vs has error severity.
+func hasErrors(vs []violation) bool {
+	for _, v := range vs {
+		if v.Severity == severityError {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern returns a function that reports whether a package path
+// matches pattern, which may contain "..." wildcards as understood by
+// the go command. As with the go command, a trailing "/..." also
+// matches the empty string, so "foo/..." matches "foo".
+func matchPattern(pattern string) func(pkg string) bool {
+	re := regexp.QuoteMeta(pattern)
+	re = strings.Replace(re, `\.\.\.`, `.*`, -1)
+	if strings.HasSuffix(re, `/.*`) {
+		re = strings.TrimSuffix(re, `/.*`) + `(/.*)?`
+	}
+	rx := regexp.MustCompile(`^` + re + `$`)
+	return rx.MatchString
+}
+
+// patternSet is a list of package patterns, compiled once so that
+// matching many packages against it doesn't recompile them.
+type patternSet struct {
+	patterns []string
+	matchers []func(pkg string) bool
+}
+
+// newPatternSet compiles patterns as matchPattern does.
+func newPatternSet(patterns []string) patternSet {
+	s := patternSet{patterns: patterns}
+	for _, p := range patterns {
+		s.matchers = append(s.matchers, matchPattern(p))
+	}
+	return s
+}
+
+// Match reports whether pkg matches one of the patterns of s.
+func (s patternSet) Match(pkg string) bool {
+	for _, m := range s.matchers {
+		if m(pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the patterns of s, comma-separated as in flags and
+// directives.
+func (s patternSet) String() string {
+	return strings.Join(s.patterns, ",")
+}
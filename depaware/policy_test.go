@@ -0,0 +1,103 @@
+package depaware
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	in := `
+# A comment.
+deny github.com/evil/...
+deny-unsafe github.com/...  severity=warn  # trailing comment
+max-orgs 2 severity=info
+`
+	p, err := parsePolicy(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range p.Rules {
+		got = append(got, r.Severity.String()+" "+r.Text)
+	}
+	want := []string{
+		"error deny github.com/evil/...",
+		"warn deny-unsafe github.com/...",
+		"info max-orgs 2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	for _, bad := range []string{
+		"frobnicate foo",
+		"deny",
+		"deny a b",
+		"max-orgs many",
+		"deny foo severity=fatal",
+	} {
+		if _, err := parsePolicy(strings.NewReader(bad)); err == nil {
+			t.Errorf("parsePolicy(%q) succeeded; want error", bad)
+		}
+	}
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	p, err := parsePolicy(strings.NewReader(`
+deny github.com/evil/...
+deny-unsafe github.com/... severity=warn
+deny-cgo ...
+max-orgs 1 severity=info
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := &policyInput{
+		Pkg: "example.com/cmd",
+		Entries: []fileEntry{
+			{Pkg: "example.com/cmd/internal/c", CGO: true},
+			{Pkg: "github.com/evil"},
+			{Pkg: "github.com/good/u", Unsafe: true},
+			{Pkg: "github.com/evilish"},
+		},
+		MainModule: "example.com/cmd",
+	}
+	var got []string
+	for _, v := range p.Evaluate(in) {
+		got = append(got, v.String())
+	}
+	want := []string{
+		"info: example.com/cmd depends on 3 distinct third-party orgs; at most 1 allowed (policy line 5: max-orgs 1)",
+		"error: example.com/cmd/internal/c uses cgo (policy line 4: deny-cgo ...)",
+		"error: example.com/cmd depends on denied package github.com/evil (policy line 2: deny github.com/evil/...)",
+		"warn: github.com/good/u uses unsafe (policy line 3: deny-unsafe github.com/...)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestPolicyMigrate(t *testing.T) {
+	p, err := parsePolicy(strings.NewReader("migrate github.com/golang/protobuf google.golang.org/protobuf severity=warn\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := &policyInput{
+		Pkg: "example.com/cmd",
+		Entries: []fileEntry{
+			{Pkg: "github.com/golang/protobuf/proto", Why: "example.com/lib", More: true},
+			{Pkg: "github.com/golang/protobufx", Why: "example.com/cmd"},
+			{Pkg: "example.com/lib", Why: "example.com/cmd"},
+			{Pkg: "google.golang.org/protobuf/proto", Why: "github.com/golang/protobuf/proto"},
+		},
+	}
+	vs := p.Evaluate(in)
+	if len(vs) != 1 {
+		t.Fatalf("got %d violations; want 1: %v", len(vs), vs)
+	}
+	const want = "example.com/cmd still uses github.com/golang/protobuf/proto (1 packages left to migrate to google.golang.org/protobuf): example.com/cmd -> example.com/lib -> github.com/golang/protobuf/proto"
+	if vs[0].Message != want {
+		t.Errorf("got message %q; want %q", vs[0].Message, want)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// runPolicy implements the "depaware policy" subcommands.
+//
+// Usage:
+//
+//	depaware policy test [policy-file]
+//	depaware policy baseline [policy-file [baseline-file]]
+func (r *runner) runPolicy(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: depaware policy test|baseline [policy-file]")
+	}
+	switch args[0] {
+	case "test":
+		return r.runPolicyTest(args[1:])
+	case "baseline":
+		return r.runPolicyBaseline(args[1:])
+	}
+	return fmt.Errorf("unknown policy subcommand %q", args[0])
+}
+
+// policyFromArgs returns the policy named by the only element of args,
+// or by the -policy flag if args is empty.
+func (r *runner) policyFromArgs(args []string) (*policy, error) {
+	name := r.Policy
+	switch len(args) {
+	case 0:
+		if name == "" {
+			return nil, errors.New("no policy file given")
+		}
+	case 1:
+		name = args[0]
+	default:
+		return nil, errors.New("too many arguments")
+	}
+	return readPolicy(name)
+}
+
+// runPolicyTest implements "depaware policy test", which evaluates a
+// (typically proposed) policy against all the depaware.txt files
+// committed to the current git repo and reports what would violate it,
+// without loading any packages. It's a dry run: the exit status doesn't
+// depend on the violations found.
+func (r *runner) runPolicyTest(args []string) error {
+	p, err := r.policyFromArgs(args)
+	if err != nil {
+		return err
+	}
+	files, err := r.trackedDepsFiles()
+	if err != nil {
+		return err
+	}
+	counts := map[severity]int{}
+	filesWithViolations := 0
+	for _, name := range files {
+		_, vs, err := r.evaluateDepsFile(p, name)
+		if err != nil {
+			return err
+		}
+		if len(vs) > 0 {
+			filesWithViolations++
+		}
+		for _, v := range vs {
+			counts[v.Severity]++
+			fmt.Fprintf(r.stdout, "%s: %v\n", name, v)
+		}
+	}
+	fmt.Fprintf(r.stdout, "\n%d errors, %d warnings, %d info in %d of %d files\n",
+		counts[severityError], counts[severityWarn], counts[severityInfo],
+		filesWithViolations, len(files))
+	return nil
+}
+
+// runPolicyBaseline implements "depaware policy baseline", which
+// writes all current violations of a policy by the committed
+// depaware.txt files to a baseline file. -check then accepts those
+// violations and only fails on new ones.
+func (r *runner) runPolicyBaseline(args []string) error {
+	out := r.Baseline
+	if out == "" {
+		out = defaultBaselineFile
+	}
+	if len(args) == 2 {
+		args, out = args[:1], args[1]
+	}
+	p, err := r.policyFromArgs(args)
+	if err != nil {
+		return err
+	}
+	files, err := r.trackedDepsFiles()
+	if err != nil {
+		return err
+	}
+	b := baseline{}
+	n := 0
+	for _, name := range files {
+		f, vs, err := r.evaluateDepsFile(p, name)
+		if err != nil {
+			return err
+		}
+		b.Add(f.Pkg, vs)
+		n += len(vs)
+	}
+	var buf bytes.Buffer
+	if err := b.write(&buf); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(out, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(r.stdout, "wrote %d violations in %d files to %s\n", n, len(files), out)
+	return nil
+}
+
+// evaluateDepsFile parses the named depaware.txt file and returns it
+// along with its violations of p.
+func (r *runner) evaluateDepsFile(p *policy, name string) (*depsFile, []violation, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := parseDepsFile(bytes.NewReader(data), r.MaxLineBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", name, err)
+	}
+	vs := p.Evaluate(&policyInput{
+		Pkg:        f.Pkg,
+		Entries:    f.Entries,
+		MainModule: modulePathFor(filepath.Dir(name)),
+	})
+	return f, vs, nil
+}
+
+// trackedDepsFiles returns the paths, relative to the current directory,
+// of the files named by the -file flag that are tracked by git in or
+// below the current directory.
+func (r *runner) trackedDepsFiles() ([]string, error) {
+	out, err := exec.Command("git", "ls-files", "-z").Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("git ls-files: %s", bytes.TrimSpace(ee.Stderr))
+		}
+		return nil, err
+	}
+	var files []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" && filepath.Base(name) == r.File {
+			files = append(files, filepath.FromSlash(name))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// modulePathFor returns the path of the module containing dir, found
+// by looking for a go.mod file in dir and its parents. It returns the
+// empty string if there is none.
+func modulePathFor(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if data, err := ioutil.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+			return modfile.ModulePath(data)
+		} else if !os.IsNotExist(err) {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
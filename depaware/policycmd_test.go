@@ -0,0 +1,47 @@
+package depaware
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateDepsFile(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "cmd", "foo")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	const file = `example.com/m/cmd/foo dependencies: (generated by github.com/tailscale/depaware)
+
+        example.com/m/util                                           from example.com/m/cmd/foo
+        github.com/a/b                                               from example.com/m/cmd/foo
+        github.com/c/d                                               from github.com/a/b
+`
+	name := filepath.Join(sub, "depaware.txt")
+	if err := ioutil.WriteFile(name, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := modulePathFor(sub); got != "example.com/m" {
+		t.Errorf("modulePathFor = %q; want example.com/m", got)
+	}
+
+	p, err := parsePolicy(strings.NewReader("max-orgs 1\ndeny github.com/c/...\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &runner{Options: *NewOptions()}
+	_, vs, err := r.evaluateDepsFile(p, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 2 || vs[0].Rule != "max-orgs 1" || vs[1].Package != "github.com/c/d" {
+		t.Errorf("unexpected violations: %v", vs)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// provenance records how a machine-readable report was produced, so
+// that systems storing reports can tell, and can notice configuration
+// drifting between CI runs.
+type provenance struct {
+	Tool      string    `json:"tool"`              // depaware's module and version, such as "github.com/tailscale/depaware@v0.1.0"
+	ToolSum   string    `json:"toolSum,omitempty"` // go.sum hash of depaware's module, if it was built from a downloaded one
+	Args      []string  `json:"args"`              // the non-flag arguments depaware ran with
+	GOOS      []string  `json:"goos"`              // from -goos
+	Tags      string    `json:"tags,omitempty"`
+	GoVersion string    `json:"goVersion"` // of the go command that loaded packages
+	BuiltWith string    `json:"builtWith"` // Go version depaware was built with
+	Host      string    `json:"host"`      // GOOS/GOARCH depaware ran on
+	Time      time.Time `json:"time"`
+}
+
+// depawareModule is the path of depaware's module.
+const depawareModule = "github.com/tailscale/depaware"
+
+// newProvenance returns the provenance of reports written at now.
+func (r *runner) newProvenance(now time.Time) *provenance {
+	r.provenanceOnce.Do(func() {
+		r.runProvenance = provenance{
+			Tool:      depawareModule + "@(devel)",
+			Args:      r.args,
+			GOOS:      strings.Split(r.GOOS, ","),
+			Tags:      r.Tags,
+			GoVersion: goVersion(),
+			BuiltWith: runtime.Version(),
+			Host:      runtime.GOOS + "/" + runtime.GOARCH,
+		}
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			if m := toolModule(bi); m != nil {
+				r.runProvenance.Tool = m.Path + "@" + m.Version
+				r.runProvenance.ToolSum = m.Sum
+			}
+		}
+	})
+	p := r.runProvenance
+	p.Time = now.UTC().Truncate(time.Second)
+	return &p
+}
+
+// toolModule returns depaware's module in bi: the main module when
+// depaware is built on its own, and a dependency when it's run from a
+// module that requires it to pin its version. A replaced module is
+// reported as its replacement.
+func toolModule(bi *debug.BuildInfo) *debug.Module {
+	m := &bi.Main
+	if m.Path != depawareModule {
+		m = nil
+		for _, dep := range bi.Deps {
+			if dep.Path == depawareModule {
+				m = dep
+				break
+			}
+		}
+	}
+	if m != nil && m.Replace != nil {
+		return m.Replace
+	}
+	return m
+}
+
+// toolVersion returns the version of the running depaware, such as
+// "v0.1.0", or "(devel)" if it's unknown.
+func toolVersion() string {
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if m := toolModule(bi); m != nil && m.Version != "" {
+			return m.Version
+		}
+	}
+	return "(devel)"
+}
+
+// goVersion returns the output of "go version", or the error running
+// it.
+func goVersion() string {
+	out, err := exec.Command("go", "version").Output()
+	if err != nil {
+		return "unknown: " + err.Error()
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,46 @@
+package depaware
+
+import (
+	"reflect"
+	"runtime/debug"
+	"testing"
+	"time"
+)
+
+func TestToolModule(t *testing.T) {
+	pinned := &debug.Module{Path: depawareModule, Version: "v0.1.0", Sum: "h1:abc="}
+	tests := []struct {
+		name string
+		bi   *debug.BuildInfo
+		want *debug.Module
+	}{
+		{"main", &debug.BuildInfo{Main: debug.Module{Path: depawareModule, Version: "(devel)"}}, &debug.Module{Path: depawareModule, Version: "(devel)"}},
+		{"pinned", &debug.BuildInfo{Main: debug.Module{Path: "example.com/proj"}, Deps: []*debug.Module{{Path: "golang.org/x/mod"}, pinned}}, pinned},
+		{"replaced", &debug.BuildInfo{Main: debug.Module{Path: "example.com/proj"}, Deps: []*debug.Module{{Path: depawareModule, Replace: pinned}}}, pinned},
+		{"absent", &debug.BuildInfo{Main: debug.Module{Path: "example.com/proj"}}, nil},
+	}
+	for _, tt := range tests {
+		got := toolModule(tt.bi)
+		if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("%s: got %+v; want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewProvenance(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 30, 0, 123, time.FixedZone("X", 3600))
+	r := &runner{Options: *NewOptions(), args: []string{"./cmd/foo"}}
+	p := r.newProvenance(now)
+	if !p.Time.Equal(now.Truncate(time.Second)) || p.Time.Location() != time.UTC {
+		t.Errorf("Time = %v; want %v in UTC", p.Time, now)
+	}
+	if p.Tool == "" || p.GoVersion == "" || p.BuiltWith == "" {
+		t.Errorf("incomplete provenance: %+v", p)
+	}
+	if want := []string{"./cmd/foo"}; !reflect.DeepEqual(p.Args, want) {
+		t.Errorf("Args = %q; want the runner's %q", p.Args, want)
+	}
+	if q := r.newProvenance(now.Add(time.Hour)); q.Time == p.Time {
+		t.Error("provenances share their time")
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// moduleGraph is how the modules providing a package's dependencies
+// relate to the main module's go.mod requirements. From go 1.17 on,
+// the module graph is pruned: go.mod must require every module that
+// provides a package to the main module's builds, and other modules in
+// the graph are only there for the requirements of those.
+type moduleGraph struct {
+	// Unrequired maps the modules that provide dependencies but that
+	// go.mod doesn't require to one of the dependencies they provide.
+	// They're only in the build because of pruning quirks, such as a
+	// go.mod edited by hand, and their versions may change as the
+	// graph around them does.
+	Unrequired map[string]string `json:"unrequired,omitempty"`
+
+	// GraphOnly are the modules go.mod requires that don't provide any
+	// dependencies: they're merely in the module graph, for the
+	// main module's other packages, their tests, or other modules'
+	// requirements, and don't end up in the binary.
+	GraphOnly []string `json:"graphOnly,omitempty"`
+}
+
+// ModuleGraph returns how the modules providing d.Deps relate to the
+// requirements of the main module's go.mod file, or nil if there's no
+// main module, its graph isn't pruned (go 1.16 and earlier), or it's
+// part of a go.work workspace, whose requirements span several go.mod
+// files.
+func (d *deps) ModuleGraph() (*moduleGraph, error) {
+	if d.MainModuleDir == "" || inWorkspace(d.MainModuleDir) {
+		return nil, nil
+	}
+	f, err := readGoMod(filepath.Join(d.MainModuleDir, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+	if !prunedGraph(f.Go) {
+		return nil, nil
+	}
+	required := make(map[string]bool)
+	for _, req := range f.Require {
+		required[req.Path] = true
+	}
+	g := &moduleGraph{}
+	used := make(map[string]bool)
+	for _, pkg := range d.Deps {
+		m, ok := d.Module[pkg]
+		// Main modules, including those of a workspace, have no
+		// version.
+		if !ok || m.Version == "" {
+			continue
+		}
+		used[m.Path] = true
+		if !required[m.Path] {
+			if g.Unrequired == nil {
+				g.Unrequired = make(map[string]string)
+			}
+			if _, ok := g.Unrequired[m.Path]; !ok {
+				g.Unrequired[m.Path] = pkg
+			}
+		}
+	}
+	for _, req := range f.Require {
+		if !used[req.Path] {
+			g.GraphOnly = append(g.GraphOnly, req.Path)
+		}
+	}
+	sort.Strings(g.GraphOnly)
+	return g, nil
+}
+
+// goMod is the part of a go.mod file that depaware needs, as printed
+// by "go mod edit -json".
+type goMod struct {
+	Module  struct{ Path string }
+	Go      string
+	Require []struct{ Path, Version string }
+}
+
+// readGoMod parses the named go.mod file. It asks the go command rather
+// than using modfile, so that it understands directives of newer Go
+// versions than depaware was built with.
+func readGoMod(name string) (*goMod, error) {
+	out, err := exec.Command("go", "mod", "edit", "-json", name).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("go mod edit -json %s: %s", name, bytes.TrimSpace(ee.Stderr))
+		}
+		return nil, err
+	}
+	f := new(goMod)
+	if err := json.Unmarshal(out, f); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", name, err)
+	}
+	return f, nil
+}
+
+// Warnings returns a message for each module in g.Unrequired.
+func (g *moduleGraph) Warnings() []string {
+	mods := make([]string, 0, len(g.Unrequired))
+	for mod := range g.Unrequired {
+		mods = append(mods, mod)
+	}
+	sort.Strings(mods)
+	var msgs []string
+	for _, mod := range mods {
+		msgs = append(msgs, fmt.Sprintf("%s comes from module %s, which go.mod doesn't require; with module graph pruning (go 1.17 and later), its version may change as the graph does: run 'go mod tidy'",
+			g.Unrequired[mod], mod))
+	}
+	return msgs
+}
+
+// prunedGraph reports whether a go.mod file with the go directive
+// version has a pruned module graph, which is go 1.17 and later. A
+// go.mod file without a go directive is treated as go 1.16.
+func prunedGraph(version string) bool {
+	major, minor, _ := strings.Cut(version, ".")
+	// Drop suffixes such as the "rc1" of "21rc1" and the patch version.
+	if i := strings.IndexFunc(minor, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		minor = minor[:i]
+	}
+	maj, err1 := strconv.Atoi(major)
+	min, err2 := strconv.Atoi(minor)
+	return err1 == nil && err2 == nil && (maj > 1 || maj == 1 && min >= 17)
+}
+
+// inWorkspace reports whether the go command uses a go.work file in
+// dir.
+func inWorkspace(dir string) bool {
+	return goWorkFile(dir) != ""
+}
+
+// goWorkFile returns the name of the go.work file the go command uses
+// in dir, or the empty string if there's none.
+func goWorkFile(dir string) string {
+	cmd := exec.Command("go", "env", "GOWORK")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	if gowork := strings.TrimSpace(string(out)); gowork != "off" {
+		return gowork
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package depaware
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+func TestPrunedGraph(t *testing.T) {
+	for v, want := range map[string]bool{
+		"1.16":    false,
+		"1.17":    true,
+		"1.21.0":  true,
+		"1.21rc1": true,
+		"1.9":     false,
+		"":        false,
+	} {
+		if got := prunedGraph(v); got != want {
+			t.Errorf("prunedGraph(%q) = %v; want %v", v, got, want)
+		}
+	}
+}
+
+func TestModuleGraph(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GOWORK", "off")
+	goMod := "module example.com/cmd\n\ngo 1.21.0\n\nrequire (\n\texample.com/a v1.0.0\n\texample.com/test v1.2.0\n)\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d := &deps{
+		MainModule:    "example.com/cmd",
+		MainModuleDir: dir,
+		Deps:          []string{"example.com/a", "example.com/b/lib", "example.com/cmd/internal/x", "fmt"},
+		Module: map[string]module.Version{
+			"example.com/a":              {Path: "example.com/a", Version: "v1.0.0"},
+			"example.com/b/lib":          {Path: "example.com/b", Version: "v0.3.0"},
+			"example.com/cmd/internal/x": {Path: "example.com/cmd"},
+		},
+	}
+	g, err := d.ModuleGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &moduleGraph{
+		Unrequired: map[string]string{"example.com/b": "example.com/b/lib"},
+		GraphOnly:  []string{"example.com/test"},
+	}
+	if !reflect.DeepEqual(g, want) {
+		t.Fatalf("got %+v; want %+v", g, want)
+	}
+	if ws := g.Warnings(); len(ws) != 1 || !strings.HasPrefix(ws[0], "example.com/b/lib comes from module example.com/b, which go.mod doesn't require") {
+		t.Errorf("unexpected warnings %q", ws)
+	}
+
+	goMod = strings.Replace(goMod, "go 1.21.0", "go 1.16", 1)
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if g, err := d.ModuleGraph(); g != nil || err != nil {
+		t.Errorf("unpruned graph: got %+v, %v; want nil", g, err)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// query is a single query of "depaware query".
+type query struct {
+	Verb string // "why", "rdeps" or "count"
+	Arg  string
+}
+
+func (q query) String() string { return q.Verb + " " + q.Arg }
+
+// queryVerbs are the verbs of the query language.
+var queryVerbs = map[string]bool{"why": true, "rdeps": true, "count": true}
+
+// parseQueries parses queries, one per line: "why dep" for the shortest
+// import chain to dep, "rdeps dep" for the packages that import dep,
+// directly or indirectly, and "count prefix" for the number of
+// dependencies whose import path is prefix or starts with prefix
+// followed by a slash. Blank lines and lines starting with # are
+// ignored.
+func parseQueries(r io.Reader) ([]query, error) {
+	var qs []query
+	scan := bufio.NewScanner(r)
+	lineNum := 0
+	for scan.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) != 2 || !queryVerbs[f[0]] {
+			return nil, fmt.Errorf("line %d: bad query %q; want why, rdeps or count followed by a package", lineNum, line)
+		}
+		qs = append(qs, query{Verb: f[0], Arg: f[1]})
+	}
+	return qs, scan.Err()
+}
+
+// answer returns the answer to q about root's dependencies d, one
+// element per line of output.
+func (d *deps) answer(root string, q query) []string {
+	switch q.Verb {
+	case "why":
+		chain := d.shortestChain(root, q.Arg)
+		if chain == nil {
+			return []string{fmt.Sprintf("%s doesn't depend on %s", root, q.Arg)}
+		}
+		return []string{strings.Join(chain, " -> ")}
+	case "rdeps":
+		return d.reverseDeps(q.Arg)
+	case "count":
+		n := 0
+		for _, dep := range d.Deps {
+			if dep == q.Arg || strings.HasPrefix(dep, q.Arg+"/") {
+				n++
+			}
+		}
+		return []string{strconv.Itoa(n)}
+	}
+	panic("unknown query verb " + q.Verb)
+}
+
+// runQuery implements "depaware query", which answers many queries
+// about one package's dependencies in a single run, loading them (or
+// reading the snapshot) only once, for audit scripts that would
+// otherwise run why, rdeps and the like over and over. The queries are
+// read from the -queries file, in the language of parseQueries. Each
+// query is printed followed by its answer, indented, or with -json, as
+// a line of JSON.
+//
+// Usage:
+//
+//	depaware query [-json] -queries=file -from-snapshot=file
+//	depaware query [-json] -queries=file root
+func (r *runner) runQuery(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	fs.SetOutput(r.stderr)
+	queriesFile := fs.String("queries", "", `name of the file of queries to answer, or "-" for stdin`)
+	asJSON := fs.Bool("json", false, `print each answer as a line of JSON, {"query": ..., "answer": [...]}`)
+	parse := r.queryFlags(fs)
+	root, d, rest, err := parse(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 || *queriesFile == "" {
+		return errors.New("usage: depaware query [-json] -queries=file [-from-snapshot=file | root]")
+	}
+	in := io.Reader(os.Stdin)
+	if *queriesFile != "-" {
+		f, err := os.Open(*queriesFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	qs, err := parseQueries(in)
+	if err != nil {
+		return fmt.Errorf("%s: %v", *queriesFile, err)
+	}
+	enc := json.NewEncoder(r.stdout)
+	for _, q := range qs {
+		ans := d.answer(root, q)
+		if *asJSON {
+			if ans == nil {
+				ans = []string{}
+			}
+			if err := enc.Encode(struct {
+				Query  string   `json:"query"`
+				Answer []string `json:"answer"`
+			}{q.String(), ans}); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintln(r.stdout, q)
+		for _, line := range ans {
+			fmt.Fprintf(r.stdout, "\t%s\n", line)
+		}
+	}
+	return nil
+}
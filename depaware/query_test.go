@@ -0,0 +1,63 @@
+package depaware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseQueries(t *testing.T) {
+	qs, err := parseQueries(strings.NewReader("# audit\nwhy fmt\n\n  rdeps  io \ncount github.com/a\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(qs) != 3 || qs[0].String() != "why fmt" || qs[1].String() != "rdeps io" || qs[2].String() != "count github.com/a" {
+		t.Errorf("got %q", qs)
+	}
+	for _, bad := range []string{"top 10\n", "why\n", "why a b\n"} {
+		if _, err := parseQueries(strings.NewReader(bad)); err == nil || !strings.Contains(err.Error(), "line 1: bad query") {
+			t.Errorf("%q: got error %v; want bad query", bad, err)
+		}
+	}
+}
+
+func TestRunQuery(t *testing.T) {
+	dir := t.TempDir()
+	snap := filepath.Join(dir, "deps.snap")
+	if err := writeSnapshot(snap, newSnapshot("example.com/cmd", []string{"linux", "windows"}, testSnapshotDeps())); err != nil {
+		t.Fatal(err)
+	}
+	queries := filepath.Join(dir, "queries.txt")
+	if err := ioutil.WriteFile(queries, []byte("why io\nwhy os\nrdeps github.com/a/lib/util\ncount github.com/a\ncount github.com/a/lib/util\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout bytes.Buffer
+	r := &runner{Options: *NewOptions(), stdout: &stdout, stderr: ioutil.Discard}
+	if err := r.runQuery([]string{"-from-snapshot", snap, "-queries", queries}); err != nil {
+		t.Fatal(err)
+	}
+	const want = "why io\n" +
+		"\texample.com/cmd -> fmt -> io\n" +
+		"why os\n" +
+		"\texample.com/cmd doesn't depend on os\n" +
+		"rdeps github.com/a/lib/util\n" +
+		"\texample.com/cmd\n" +
+		"\tgithub.com/a/lib\n" +
+		"count github.com/a\n" +
+		"\t2\n" +
+		"count github.com/a/lib/util\n" +
+		"\t1\n"
+	if stdout.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", stdout.String(), want)
+	}
+
+	stdout.Reset()
+	if err := r.runQuery([]string{"-json", "-from-snapshot", snap, "-queries", queries}); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(stdout.String()), "\n"); len(lines) != 5 || lines[3] != `{"query":"count github.com/a","answer":["2"]}` {
+		t.Errorf("-json: got:\n%s", stdout.String())
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"errors"
+	"fmt"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/rta"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// runReach implements "depaware reach", which answers whether a
+// function or method, such as one named in a vulnerability advisory,
+// is reachable from a package, and prints a call chain if it is. It's
+// meant as a first pass at triaging advisories.
+//
+// The package is analyzed for the first -goos value only. If it's a
+// main package, calls are resolved with rapid type analysis, starting
+// from main and init. Otherwise, they're resolved by class hierarchy
+// analysis, starting from all its functions, which may report calls
+// through interfaces that can't actually happen.
+//
+// Usage:
+//
+//	depaware reach root pkg symbol
+//
+// where symbol is a function name, such as "Parse", or a method name
+// qualified by its receiver's type name, such as "Reader.Read".
+func (r *runner) runReach(args []string) error {
+	if len(args) != 3 {
+		return errors.New("usage: depaware reach root pkg symbol")
+	}
+	root, pkg, sym := args[0], args[1], args[2]
+	goos := strings.Split(r.GOOS, ",")[0]
+
+	// Don't bother building a call graph if pkg isn't even linked in.
+	d, _, err := r.loadDepsConfig(root, []string{goos}, loadConfig{NoFiles: true})
+	if err != nil {
+		return err
+	}
+	if !stringsContains(d.Deps, pkg) {
+		fmt.Fprintf(r.stdout, "%s.%s is not reachable from %s on GOOS=%s: %s isn't imported\n", pkg, sym, root, goos, pkg)
+		return nil
+	}
+
+	chain, err := r.callChain(root, goos, pkg, sym)
+	if err != nil {
+		return err
+	}
+	if chain == nil {
+		fmt.Fprintf(r.stdout, "%s.%s is not reachable from %s on GOOS=%s: %s is imported, but the symbol isn't called\n", pkg, sym, root, goos, pkg)
+		return nil
+	}
+	fmt.Fprintf(r.stdout, "%s.%s is reachable from %s on GOOS=%s:\n", pkg, sym, root, goos)
+	for _, fn := range chain {
+		fmt.Fprintf(r.stdout, "\t%s\n", fn)
+	}
+	return nil
+}
+
+// callChain returns a chain of calls from root, loaded for goos, to
+// the function or method sym of pkg, or nil if there's none.
+func (r *runner) callChain(root, goos, pkg, sym string) ([]string, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedImports |
+			packages.NeedDeps | packages.NeedTypes | packages.NeedTypesSizes | packages.NeedSyntax | packages.NeedTypesInfo,
+		Env: goEnv(goos),
+	}
+	if r.Tags != "" {
+		cfg.BuildFlags = []string{"-tags", r.Tags}
+	}
+	pkgs, err := packages.Load(cfg, root)
+	if err != nil {
+		return nil, err
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		return nil, fmt.Errorf("%s has errors for GOOS=%s", root, goos)
+	}
+	prog, ssaPkgs := ssautil.AllPackages(pkgs, 0)
+	prog.Build()
+	rootPkg := ssaPkgs[0]
+
+	var cg *callgraph.Graph
+	var starts []*ssa.Function
+	if rootPkg.Pkg.Name() == "main" {
+		starts = []*ssa.Function{rootPkg.Func("main"), rootPkg.Func("init")}
+		cg = rta.Analyze(starts, true).CallGraph
+	} else {
+		cg = cha.CallGraph(prog)
+		for fn := range ssautil.AllFunctions(prog) {
+			if fn.Pkg == rootPkg && fn.Parent() == nil {
+				starts = append(starts, fn)
+			}
+		}
+	}
+	cg.DeleteSyntheticNodes()
+
+	for _, fn := range starts {
+		node := cg.Nodes[fn]
+		if node == nil {
+			continue
+		}
+		if isSymbol(fn, pkg, sym) {
+			return []string{fn.String()}, nil
+		}
+		edges := callgraph.PathSearch(node, func(n *callgraph.Node) bool { return isSymbol(n.Func, pkg, sym) })
+		if edges == nil {
+			continue
+		}
+		chain := []string{edges[0].Caller.Func.String()}
+		for _, e := range edges {
+			chain = append(chain, e.Callee.Func.String())
+		}
+		return chain, nil
+	}
+	return nil, nil
+}
+
+// isSymbol reports whether fn is the function or method sym of pkg,
+// where sym is as for runReach. Closures and synthetic wrappers don't
+// count.
+func isSymbol(fn *ssa.Function, pkg, sym string) bool {
+	if fn == nil || fn.Parent() != nil || fn.Synthetic != "" {
+		return false
+	}
+	obj := fn.Object()
+	if obj == nil || obj.Pkg() == nil || obj.Pkg().Path() != pkg {
+		return false
+	}
+	return symbolName(fn) == sym
+}
+
+// symbolName returns the name of fn as for runReach: its name for a
+// function, or its receiver's type name and its name for a method.
+func symbolName(fn *ssa.Function) string {
+	recv := fn.Signature.Recv()
+	if recv == nil {
+		return fn.Name()
+	}
+	t := recv.Type()
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	if n, ok := t.(*types.Named); ok {
+		return n.Obj().Name() + "." + fn.Name()
+	}
+	return fn.Name()
+}
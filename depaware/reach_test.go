@@ -0,0 +1,59 @@
+package depaware
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+func TestIsSymbol(t *testing.T) {
+	const src = `package vuln
+
+func Parse() { func() {}() }
+
+type Reader struct{}
+
+func (*Reader) Read() {}
+
+func (Reader) Close() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "vuln.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := types.NewPackage("example.com/vuln", "vuln")
+	ssaPkg, _, err := ssautil.BuildPackage(&types.Config{Importer: importer.Default()}, fset, pkg, []*ast.File{f}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for fn := range ssautil.AllFunctions(ssaPkg.Prog) {
+		for _, sym := range []string{"Parse", "Reader.Read", "Reader.Close", "Read", "init"} {
+			if isSymbol(fn, "example.com/vuln", sym) {
+				got = append(got, fn.String()+" is "+sym)
+			}
+			if isSymbol(fn, "example.com/other", sym) {
+				t.Errorf("%s matches %s in the wrong package", fn, sym)
+			}
+		}
+	}
+	want := map[string]bool{
+		"example.com/vuln.Parse is Parse":                 true,
+		"(*example.com/vuln.Reader).Read is Reader.Read":  true,
+		"(example.com/vuln.Reader).Close is Reader.Close": true,
+	}
+	if len(got) != len(want) {
+		t.Errorf("got matches %q; want %d", got, len(want))
+	}
+	for _, g := range got {
+		if !want[g] {
+			t.Errorf("unexpected match %q", g)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// runReleaseNotes implements "depaware release-notes", which compares
+// the modules (with versions) that provide the dependencies of packages
+// between two git revisions and prints a "Third-party updates" section
+// for release notes. Only modules that the packages actually import
+// from count: modules that are merely in go.mod's module graph, or only
+// needed by tests, don't end up in the binaries and aren't reported.
+// The packages default to "./...".
+//
+// Usage:
+//
+//	depaware release-notes OLD NEW [packages]
+func (r *runner) runReleaseNotes(args []string) error {
+	fs := flag.NewFlagSet("release-notes", flag.ContinueOnError)
+	fs.SetOutput(r.stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return errors.New("usage: depaware release-notes OLD NEW [packages]")
+	}
+	patterns := fs.Args()[2:]
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	var mods [2]map[string]string
+	for i, rev := range fs.Args()[:2] {
+		var err error
+		if mods[i], err = r.revModuleVersions(rev, patterns); err != nil {
+			return err
+		}
+	}
+	writeReleaseNotes(r.stdout, mods[0], mods[1])
+	return nil
+}
+
+// revModuleVersions returns the moduleVersions of the packages matching
+// patterns, relative to the current directory, at the git revision rev,
+// on each of -goos. It loads them from a temporary worktree of rev, so
+// that the current one is left alone.
+func (r *runner) revModuleVersions(rev string, patterns []string) (map[string]string, error) {
+	prefix, err := gitOutput("rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := ioutil.TempDir("", "depaware-release-notes")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	wt := filepath.Join(tmp, "wt")
+	if _, err := gitOutput("worktree", "add", "--detach", wt, rev); err != nil {
+		return nil, err
+	}
+	defer gitOutput("worktree", "remove", "--force", wt)
+
+	var buildFlags []string
+	if r.Tags != "" {
+		buildFlags = append(buildFlags, "-tags", r.Tags)
+	}
+	conf := loadConfig{NoFiles: true, Dir: filepath.Join(wt, filepath.FromSlash(prefix))}
+	m := make(map[string]string)
+	for _, goos := range strings.Split(r.GOOS, ",") {
+		pkgs, err := loadGOOS(patterns, goos, buildFlags, conf)
+		if err != nil {
+			return nil, fmt.Errorf("loading packages at %s: %v", rev, err)
+		}
+		for mod, v := range moduleVersions(pkgs) {
+			m[mod] = v
+		}
+	}
+	return m, nil
+}
+
+// moduleVersions returns the modules providing pkgs and their
+// dependencies, other than main modules, mapped to their effective
+// version after replacements. Replacements by a local directory are
+// reported as "=> dir".
+func moduleVersions(pkgs []*packages.Package) map[string]string {
+	m := make(map[string]string)
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		mod := p.Module
+		if mod == nil || mod.Main {
+			return
+		}
+		switch rep := mod.Replace; {
+		case rep == nil:
+			m[mod.Path] = mod.Version
+		case rep.Version == "":
+			m[mod.Path] = "=> " + rep.Path
+		case rep.Path == mod.Path:
+			m[mod.Path] = rep.Version
+		default:
+			m[mod.Path] = "=> " + rep.Path + " " + rep.Version
+		}
+	})
+	return m
+}
+
+// writeReleaseNotes writes the module changes between the old and new
+// module version maps to w. It writes nothing if they are the same.
+func writeReleaseNotes(w io.Writer, oldMods, newMods map[string]string) {
+	var changed []string
+	for mod, v := range newMods {
+		if oldMods[mod] != v {
+			changed = append(changed, mod)
+		}
+	}
+	for mod := range oldMods {
+		if _, ok := newMods[mod]; !ok {
+			changed = append(changed, mod)
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+	sort.Strings(changed)
+	fmt.Fprintf(w, "Third-party updates:\n\n")
+	for _, mod := range changed {
+		ov, inOld := oldMods[mod]
+		nv, inNew := newMods[mod]
+		switch {
+		case !inOld:
+			fmt.Fprintf(w, "* Added %s %s\n", mod, nv)
+		case !inNew:
+			fmt.Fprintf(w, "* Removed %s %s\n", mod, ov)
+		default:
+			fmt.Fprintf(w, "* Updated %s %s -> %s\n", mod, ov, nv)
+		}
+	}
+}
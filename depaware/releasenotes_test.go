@@ -0,0 +1,66 @@
+package depaware
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestModuleVersions(t *testing.T) {
+	mod := func(path, version string, replace *packages.Module) *packages.Module {
+		return &packages.Module{Path: path, Version: version, Replace: replace}
+	}
+	pkg := func(path string, m *packages.Module, imports ...*packages.Package) *packages.Package {
+		p := &packages.Package{PkgPath: path, Module: m, Imports: make(map[string]*packages.Package)}
+		for _, imp := range imports {
+			p.Imports[imp.PkgPath] = imp
+		}
+		return p
+	}
+	a := pkg("example.com/a", mod("example.com/a", "v1.0.0", nil))
+	b := pkg("example.com/b", mod("example.com/b", "v1.1.0", mod("example.com/b", "v1.1.1", nil)), a)
+	c := pkg("example.com/c", mod("example.com/c", "v1.2.0", mod("../c", "", nil)))
+	d := pkg("example.com/d/sub", mod("example.com/d", "v1.3.0", mod("example.com/fork", "v1.0.0", nil)))
+	root := pkg("example.com/m", &packages.Module{Path: "example.com/m", Main: true}, b, c, d, pkg("fmt", nil))
+	want := map[string]string{
+		"example.com/a": "v1.0.0",
+		"example.com/b": "v1.1.1",
+		"example.com/c": "=> ../c",
+		"example.com/d": "=> example.com/fork v1.0.0",
+	}
+	if got := moduleVersions([]*packages.Package{root}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestWriteReleaseNotes(t *testing.T) {
+	oldMods := map[string]string{
+		"example.com/same":    "v1.0.0",
+		"example.com/gone":    "v0.1.0",
+		"example.com/updated": "v1.0.0",
+	}
+	newMods := map[string]string{
+		"example.com/same":    "v1.0.0",
+		"example.com/added":   "v2.0.0",
+		"example.com/updated": "v1.1.0",
+	}
+	var buf bytes.Buffer
+	writeReleaseNotes(&buf, oldMods, newMods)
+	const want = `Third-party updates:
+
+* Added example.com/added v2.0.0
+* Removed example.com/gone v0.1.0
+* Updated example.com/updated v1.0.0 -> v1.1.0
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	writeReleaseNotes(&buf, oldMods, oldMods)
+	if buf.Len() != 0 {
+		t.Errorf("unchanged modules produced output: %q", buf.String())
+	}
+}
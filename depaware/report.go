@@ -0,0 +1,155 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tailscale/depaware/depaware/schema"
+	"unicode"
+)
+
+// report is the -format=json output for a single package. Package
+// schema describes it for consumers, and must be kept in sync with it;
+// changes that aren't additions need a new major schema.Version.
+type report struct {
+	SchemaVersion string           `json:"schemaVersion"` // schema.Version
+	Package       string           `json:"package"`
+	GOOS          []string         `json:"goos"`
+	OSCounts      map[string]int   `json:"osCounts"` // number of deps on each GOOS
+	Deps          []reportDep      `json:"deps"`
+	Orgs          orgConcentration `json:"orgs"` // of the third-party dependencies
+	Violations    []violation      `json:"violations,omitempty"`
+	NearDups      []nearDup        `json:"nearDups,omitempty"`     // with -near-dups
+	BlankImports  []blankImport    `json:"blankImports,omitempty"` // with -blank-imports
+	CacheErrors   []string         `json:"cacheErrors,omitempty"`  // with -verify-cache
+	ModuleGraph   *moduleGraph     `json:"moduleGraph,omitempty"`  // if go.mod's module graph is pruned
+	Provenance    *provenance      `json:"provenance,omitempty"`
+}
+
+// reportDep is a single dependency in a report.
+type reportDep struct {
+	Package string   `json:"package"`
+	GOOS    []string `json:"goos,omitempty"` // if not a dependency on all of report.GOOS
+	Unsafe  bool     `json:"unsafe,omitempty"`
+	CGO     bool     `json:"cgo,omitempty"`
+	Why     string   `json:"why,omitempty"`     // an importer of Package
+	Symbols int      `json:"symbols,omitempty"` // with -symbols
+	Native  []string `json:"native,omitempty"`  // with -native
+
+	// Files are the files Package is compiled from on each GOOS, with
+	// -deep.
+	Files map[string][]string `json:"files,omitempty"`
+}
+
+// newReport returns the report for pkg, whose dependencies are d and
+// entries. The platforms of each dependency come from its entry's OS
+// letters, as entries may be for modules rather than packages.
+func newReport(pkg string, d *deps, geese []string, entries []fileEntry, violations []violation) *report {
+	r := &report{
+		SchemaVersion: schema.Version,
+		Package:       pkg,
+		GOOS:          geese,
+		OSCounts:      d.OSCounts(geese),
+		Orgs:          concentration(d.OrgCounts()),
+		Deps:          make([]reportDep, 0, len(entries)),
+		Violations:    violations,
+	}
+	for _, e := range entries {
+		rd := reportDep{
+			Package: e.Pkg,
+			Unsafe:  e.Unsafe,
+			CGO:     e.CGO,
+			Why:     e.Why,
+			Symbols: e.Symbols,
+		}
+		if e.Native != "" {
+			rd.Native = strings.Split(e.Native, ",")
+		}
+		if e.OS != "" {
+			for _, goos := range geese {
+				if strings.ContainsRune(e.OS, unicode.ToUpper(rune(goos[0]))) {
+					rd.GOOS = append(rd.GOOS, goos)
+				}
+			}
+		}
+		r.Deps = append(r.Deps, rd)
+	}
+	return r
+}
+
+// addFiles adds the compiled files of each dependency in r, from d,
+// for -deep.
+func (r *report) addFiles(d *deps) {
+	for i, rd := range r.Deps {
+		for _, goos := range r.GOOS {
+			files := d.CompiledFiles[pkgGOOS{rd.Package, goos}]
+			if len(files) == 0 {
+				continue
+			}
+			if rd.Files == nil {
+				rd.Files = make(map[string][]string)
+			}
+			rd.Files[goos] = files
+		}
+		r.Deps[i] = rd
+	}
+}
+
+func writeJSONReport(w io.Writer, r *report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(r)
+}
+
+// metrics is the -format=metrics-json output for a single package: a
+// compact summary meant to be appended to a metrics store by CI, one
+// line per package and run.
+type metrics struct {
+	Time       time.Time `json:"time"`
+	Package    string    `json:"package"`
+	Total      int       `json:"total"`
+	Std        int       `json:"std"`
+	X          int       `json:"x"` // golang.org/x
+	FirstParty int       `json:"firstParty"`
+	ThirdParty int       `json:"thirdParty"`
+	Unsafe     int       `json:"unsafe"`
+	CGO        int       `json:"cgo"`
+
+	Provenance *provenance `json:"provenance,omitempty"`
+}
+
+// newMetrics returns the metrics of pkg at time now.
+func newMetrics(pkg string, d *deps, entries []fileEntry, now time.Time) *metrics {
+	m := &metrics{
+		Time:    now.UTC().Truncate(time.Second),
+		Package: pkg,
+		Total:   len(entries),
+	}
+	for _, e := range entries {
+		switch depOwner(e.Pkg) {
+		case "std":
+			m.Std++
+		case "golang.org/x":
+			m.X++
+		default:
+			if isThirdPartyPath(e.Pkg, d.MainModule) {
+				m.ThirdParty++
+			} else {
+				m.FirstParty++
+			}
+		}
+		if e.Unsafe {
+			m.Unsafe++
+		}
+		if e.CGO {
+			m.CGO++
+		}
+	}
+	return m
+}
@@ -0,0 +1,121 @@
+package depaware
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tailscale/depaware/depaware/schema"
+)
+
+func TestNewMetrics(t *testing.T) {
+	d := &deps{MainModule: "example.com/m"}
+	entries := []fileEntry{
+		{Pkg: "bytes"},
+		{Pkg: "net/http"},
+		{Pkg: "golang.org/x/sys/unix"},
+		{Pkg: "example.com/m/util"},
+		{Pkg: "github.com/a/b", Unsafe: true, CGO: true},
+		{Pkg: "github.com/a/c", Unsafe: true},
+	}
+	now := time.Date(2025, 1, 2, 3, 4, 5, 6, time.UTC)
+	want := &metrics{
+		Time:       time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Package:    "example.com/m/cmd",
+		Total:      6,
+		Std:        2,
+		X:          1,
+		FirstParty: 1,
+		ThirdParty: 2,
+		Unsafe:     2,
+		CGO:        1,
+	}
+	if got := newMetrics("example.com/m/cmd", d, entries, now); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+}
+
+func TestReportAddFiles(t *testing.T) {
+	d := new(deps)
+	d.AddDep("github.com/foo/bar", "linux")
+	d.AddDep("github.com/foo/bar", "windows")
+	d.AddCompiledFiles("github.com/foo/bar", "linux", []string{"/mod/bar/bar.go", "/mod/bar/bar_linux.go"})
+	d.AddCompiledFiles("github.com/foo/bar", "windows", []string{"/mod/bar/bar.go"})
+	r := newReport("example.com/cmd", d, []string{"linux", "windows"}, d.Entries([]string{"linux", "windows"}, nil), nil)
+	if r.Deps[0].Files != nil {
+		t.Errorf("files without -deep: %v", r.Deps[0].Files)
+	}
+	r.addFiles(d)
+	want := map[string][]string{
+		"linux":   {"/mod/bar/bar.go", "/mod/bar/bar_linux.go"},
+		"windows": {"/mod/bar/bar.go"},
+	}
+	if !reflect.DeepEqual(r.Deps[0].Files, want) {
+		t.Errorf("Files = %v; want %v", r.Deps[0].Files, want)
+	}
+}
+
+func TestReportModuleGranularity(t *testing.T) {
+	d := testModuleDeps()
+	geese := []string{"linux", "windows"}
+	r := newReport("example.com/cmd", d, geese, d.ModuleEntries(geese), nil)
+	got := make(map[string][]string)
+	for _, rd := range r.Deps {
+		got[rd.Package] = rd.GOOS
+	}
+	want := map[string][]string{
+		"github.com/foo/bar": nil,
+		"golang.org/x/sys":   {"linux"},
+		"std":                nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GOOS by module = %v; want %v", got, want)
+	}
+}
+
+// TestReportSchema checks that a report with every field set decodes
+// into schema.Report without unknown fields and encodes back the same.
+func TestReportSchema(t *testing.T) {
+	rep := &report{
+		SchemaVersion: schema.Version,
+		Package:       "example.com/cmd",
+		GOOS:          []string{"linux", "windows"},
+		OSCounts:      map[string]int{"linux": 2, "windows": 1},
+		Deps: []reportDep{{
+			Package: "example.com/lib",
+			GOOS:    []string{"linux"},
+			Unsafe:  true,
+			CGO:     true,
+			Why:     "example.com/cmd",
+			Symbols: 12,
+			Native:  []string{"c"},
+			Files:   map[string][]string{"linux": {"/src/lib/lib.go"}},
+		}},
+		Orgs:         orgConcentration{Orgs: 1, Packages: 1, Largest: "example.com", LargestShare: 1},
+		Violations:   []violation{{Rule: "deny example.com/lib", Line: 1, Severity: severityError, Package: "example.com/lib", Message: "denied"}},
+		NearDups:     []nearDup{{A: "example.com/a", B: "example.com/A", Reason: "case"}},
+		BlankImports: []blankImport{{Importer: "example.com/cmd", Import: "example.com/lib", Pos: "cmd.go:5", Transitive: 2, Exclusive: 1}},
+		CacheErrors:  []string{"mismatch"},
+		ModuleGraph:  &moduleGraph{Unrequired: map[string]string{"example.com/b": "example.com/b/lib"}, GraphOnly: []string{"example.com/c"}},
+		Provenance:   &provenance{Tool: "depaware", ToolSum: "h1:x", Args: []string{"-format=json"}, GOOS: []string{"linux"}, Tags: "x", GoVersion: "go1.21", BuiltWith: "go1.21", Host: "linux/amd64", Time: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	var buf bytes.Buffer
+	if err := writeJSONReport(&buf, rep); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.DisallowUnknownFields()
+	var got schema.Report
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("decoding into schema.Report: %v", err)
+	}
+	enc, err := json.MarshalIndent(got, "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(enc)+"\n" != buf.String() {
+		t.Errorf("schema.Report encodes as\n%s\nwant\n%s", enc, buf.String())
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"sort"
+	"strings"
+)
+
+// rootsDirective is the depaware.txt directive that lists the root
+// packages a file covers, when it covers more than the package it's
+// for, such as a family of closely related binaries.
+const rootsDirective = "roots"
+
+// otherRoots returns the packages in roots other than pkg, sorted and
+// without duplicates.
+func otherRoots(pkg string, roots []string) []string {
+	var out []string
+	for _, root := range roots {
+		if root != pkg && !stringsContains(out, root) {
+			out = append(out, root)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// rootsDirectiveValue returns the value of the roots directive for a
+// file of pkg that also covers others.
+func rootsDirectiveValue(pkg string, others []string) string {
+	return strings.Join(append([]string{pkg}, others...), ",")
+}
@@ -0,0 +1,29 @@
+package depaware
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOtherRoots(t *testing.T) {
+	got := otherRoots("example.com/cmd/tool", []string{"example.com/cmd/tool-debug", "example.com/cmd/tool", "example.com/cmd/tool-bench", "example.com/cmd/tool-debug"})
+	want := []string{"example.com/cmd/tool-bench", "example.com/cmd/tool-debug"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("otherRoots = %q; want %q", got, want)
+	}
+	if v := rootsDirectiveValue("example.com/cmd/tool", want); v != "example.com/cmd/tool,example.com/cmd/tool-bench,example.com/cmd/tool-debug" {
+		t.Errorf("rootsDirectiveValue = %q", v)
+	}
+}
+
+func TestShortestChainOtherRoots(t *testing.T) {
+	d := testSnapshotDeps()
+	d.AddEdge("example.com/cmd/debug", "os")
+	d.OtherRoots = []string{"example.com/cmd/debug"}
+	if got, want := d.shortestChain("example.com/cmd", "os"), []string{"example.com/cmd/debug", "os"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("shortestChain = %q; want %q", got, want)
+	}
+	if got, want := d.shortestChain("example.com/cmd", "io"), []string{"example.com/cmd", "fmt", "io"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("shortestChain = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package schema describes the JSON report that depaware writes with
+// -format=json, for programs that consume it.
+//
+// Report and the types it uses decode the report with encoding/json,
+// and JSONSchema validates it with any JSON Schema implementation.
+// Every report records the Version of the schema it follows, so
+// consumers can reject reports they don't understand.
+package schema
+
+import (
+	_ "embed"
+	"strings"
+	"time"
+)
+
+// Version is the version of the report schema, "major.minor". The
+// minor version goes up when fields are added, which consumers can
+// ignore, and the major version when fields are removed or change
+// meaning or type.
+const Version = "1.0"
+
+// JSONSchema is the JSON Schema (draft 2020-12) of the report, as
+// published in report.schema.json next to this package.
+//
+//go:embed report.schema.json
+var JSONSchema []byte
+
+// Compatible reports whether a report with the schema version v can be
+// decoded into Report without losing meaning: whether it has the same
+// major version as Version. Reports that predate schema versions have
+// none, and are compatible.
+func Compatible(v string) bool {
+	if v == "" {
+		return true
+	}
+	major, _, _ := strings.Cut(v, ".")
+	want, _, _ := strings.Cut(Version, ".")
+	return major == want
+}
+
+// Report is the -format=json output for a single package.
+type Report struct {
+	SchemaVersion string         `json:"schemaVersion"` // Version, when written
+	Package       string         `json:"package"`
+	GOOS          []string       `json:"goos"`
+	OSCounts      map[string]int `json:"osCounts"` // number of deps on each GOOS
+	Deps          []Dep          `json:"deps"`
+	Orgs          Orgs           `json:"orgs"` // of the third-party dependencies
+	Violations    []Violation    `json:"violations,omitempty"`
+	NearDups      []NearDup      `json:"nearDups,omitempty"`     // with -near-dups
+	BlankImports  []BlankImport  `json:"blankImports,omitempty"` // with -blank-imports
+	CacheErrors   []string       `json:"cacheErrors,omitempty"`  // with -verify-cache
+	ModuleGraph   *ModuleGraph   `json:"moduleGraph,omitempty"`  // if go.mod's module graph is pruned
+	Provenance    *Provenance    `json:"provenance,omitempty"`
+}
+
+// Dep is a single dependency in a report.
+type Dep struct {
+	Package string   `json:"package"`
+	GOOS    []string `json:"goos,omitempty"` // if not a dependency on all of Report.GOOS
+	Unsafe  bool     `json:"unsafe,omitempty"`
+	CGO     bool     `json:"cgo,omitempty"`
+	Why     string   `json:"why,omitempty"`     // an importer of Package
+	Symbols int      `json:"symbols,omitempty"` // with -symbols
+	Native  []string `json:"native,omitempty"`  // kinds of non-Go code, with -native
+
+	// Files are the files Package is compiled from on each GOOS, with
+	// -deep.
+	Files map[string][]string `json:"files,omitempty"`
+}
+
+// Orgs is how concentrated the third-party dependencies are in the
+// hands of a few organizations.
+type Orgs struct {
+	Orgs         int     `json:"orgs"`                   // distinct third-party orgs
+	Packages     int     `json:"packages"`               // third-party packages
+	Largest      string  `json:"largest,omitempty"`      // org owning the most packages
+	LargestShare float64 `json:"largestShare,omitempty"` // fraction of Packages owned by Largest
+}
+
+// Violation is a violation of a policy rule.
+type Violation struct {
+	Rule     string `json:"rule"`
+	Line     int    `json:"line"`              // of the rule in the policy file
+	Severity string `json:"severity"`          // "info", "warn" or "error"
+	Package  string `json:"package,omitempty"` // dependency at fault, if any
+	Message  string `json:"message"`
+}
+
+// NearDup is a pair of modules that look like duplicates of each other.
+type NearDup struct {
+	A      string `json:"a"` // module path, less than B
+	B      string `json:"b"`
+	Reason string `json:"reason"`
+}
+
+// BlankImport is an import for side effects only in the main module.
+type BlankImport struct {
+	Importer string `json:"importer"`
+	Import   string `json:"import"`
+	Pos      string `json:"pos"` // "file:line", relative to the main module's root
+
+	// Transitive is the number of packages Import transitively
+	// imports, including itself, and Exclusive the number of
+	// dependencies that nothing but this import pulls in.
+	Transitive int `json:"transitive"`
+	Exclusive  int `json:"exclusive"`
+}
+
+// ModuleGraph is how the modules providing the dependencies relate to
+// the main module's go.mod requirements.
+type ModuleGraph struct {
+	// Unrequired maps the modules that provide dependencies but that
+	// go.mod doesn't require to one of the dependencies they provide.
+	Unrequired map[string]string `json:"unrequired,omitempty"`
+
+	// GraphOnly are the modules go.mod requires that don't provide any
+	// dependencies.
+	GraphOnly []string `json:"graphOnly,omitempty"`
+}
+
+// Provenance records how a report was produced.
+type Provenance struct {
+	Tool      string    `json:"tool"`              // depaware's module and version, such as "github.com/tailscale/depaware@v0.1.0"
+	ToolSum   string    `json:"toolSum,omitempty"` // go.sum hash of depaware's module, if it was built from a downloaded one
+	Args      []string  `json:"args"`              // the non-flag arguments depaware ran with
+	GOOS      []string  `json:"goos"`              // from -goos
+	Tags      string    `json:"tags,omitempty"`
+	GoVersion string    `json:"goVersion"` // of the go command that loaded packages
+	BuiltWith string    `json:"builtWith"` // Go version depaware was built with
+	Host      string    `json:"host"`      // GOOS/GOARCH depaware ran on
+	Time      time.Time `json:"time"`
+}
@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestJSONSchema checks that JSONSchema describes the same fields as
+// the Go types, with the fields that aren't omitted when empty required.
+func TestJSONSchema(t *testing.T) {
+	var s struct {
+		Required   []string                   `json:"required"`
+		Properties map[string]json.RawMessage `json:"properties"`
+		Defs       map[string]struct {
+			Required   []string                   `json:"required"`
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(JSONSchema, &s); err != nil {
+		t.Fatal(err)
+	}
+	check := func(name string, typ reflect.Type, required []string, props map[string]json.RawMessage) {
+		t.Helper()
+		var fields, wantRequired []string
+		for i := 0; i < typ.NumField(); i++ {
+			tag := typ.Field(i).Tag.Get("json")
+			field, opts, _ := strings.Cut(tag, ",")
+			fields = append(fields, field)
+			if opts != "omitempty" {
+				wantRequired = append(wantRequired, field)
+			}
+		}
+		var gotFields []string
+		for field := range props {
+			gotFields = append(gotFields, field)
+		}
+		sort.Strings(fields)
+		sort.Strings(gotFields)
+		sort.Strings(wantRequired)
+		sort.Strings(required)
+		if !reflect.DeepEqual(gotFields, fields) {
+			t.Errorf("%s: schema has properties %q; want %q", name, gotFields, fields)
+		}
+		if !reflect.DeepEqual(required, wantRequired) {
+			t.Errorf("%s: schema requires %q; want %q", name, required, wantRequired)
+		}
+	}
+	check("report", reflect.TypeOf(Report{}), s.Required, s.Properties)
+	for name, typ := range map[string]reflect.Type{
+		"dep":         reflect.TypeOf(Dep{}),
+		"orgs":        reflect.TypeOf(Orgs{}),
+		"violation":   reflect.TypeOf(Violation{}),
+		"nearDup":     reflect.TypeOf(NearDup{}),
+		"blankImport": reflect.TypeOf(BlankImport{}),
+		"moduleGraph": reflect.TypeOf(ModuleGraph{}),
+		"provenance":  reflect.TypeOf(Provenance{}),
+	} {
+		def, ok := s.Defs[name]
+		if !ok {
+			t.Errorf("schema has no definition of %s", name)
+			continue
+		}
+		check(name, typ, def.Required, def.Properties)
+	}
+	if !strings.Contains(string(JSONSchema), "schema version "+Version+".") {
+		t.Errorf("schema description doesn't mention version %s", Version)
+	}
+}
+
+func TestCompatible(t *testing.T) {
+	for v, want := range map[string]bool{
+		"":      true,
+		Version: true,
+		"1.7":   true,
+		"2.0":   false,
+		"10.0":  false,
+	} {
+		if got := Compatible(v); got != want {
+			t.Errorf("Compatible(%q) = %v; want %v", v, got, want)
+		}
+	}
+}
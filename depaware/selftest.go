@@ -0,0 +1,116 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/diff"
+)
+
+// runSelftest implements "depaware selftest", which analyzes packages
+// twice, first serially with a cold build cache and then in parallel
+// with the now warm cache, and verifies that the results are identical.
+// It's a quick way to validate a new depaware version against a repo
+// before upgrading the pinned tool. The packages default to those of
+// the depaware.txt files tracked by git in or below the current
+// directory.
+//
+// Usage:
+//
+//	depaware selftest [packages]
+func (r *runner) runSelftest(args []string) error {
+	var ipaths []string
+	var err error
+	if len(args) == 0 {
+		ipaths, err = r.trackedPackages()
+		if err == nil && len(ipaths) == 0 {
+			err = fmt.Errorf("no %s files tracked by git here; name the packages to test", r.File)
+		}
+	} else {
+		ipaths, err = pkgPaths(args...)
+	}
+	if err != nil {
+		return err
+	}
+	cache, err := ioutil.TempDir("", "depaware-selftest")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(cache)
+	return r.selftest(ipaths, cache, r.selftestOutput)
+}
+
+// trackedPackages returns the packages of the trackedDepsFiles, as
+// recorded in their headers.
+func (r *runner) trackedPackages() ([]string, error) {
+	files, err := r.trackedDepsFiles()
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []string
+	for _, name := range files {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parseDepsFile(bytes.NewReader(data), r.MaxLineBytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		pkgs = append(pkgs, f.Pkg)
+	}
+	return pkgs, nil
+}
+
+// selftest compares the results of analyzing each of ipaths cold and
+// serially, with the build cache in the empty directory cache, and
+// then warm and in parallel, using output to get the results.
+func (r *runner) selftest(ipaths []string, cache string, output func(pkg string, geese []string, conf loadConfig) ([]byte, error)) error {
+	geese := strings.Split(r.GOOS, ",")
+	cold := loadConfig{Env: []string{"GOCACHE=" + cache}}
+	warm := loadConfig{Env: cold.Env, Parallel: true}
+	failed := 0
+	for _, pkg := range ipaths {
+		before, err := output(pkg, geese, cold)
+		if err != nil {
+			return err
+		}
+		after, err := output(pkg, geese, warm)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(before, after) {
+			fmt.Fprintf(r.stdout, "ok\t%s\n", pkg)
+			continue
+		}
+		failed++
+		fmt.Fprintf(r.stdout, "FAIL\t%s\n", pkg)
+		if err := diff.Text("cold/serial", "warm/parallel", before, after, r.stdout); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d packages had different results", failed, len(ipaths))
+	}
+	return nil
+}
+
+// selftestOutput returns the depaware.txt contents for pkg, loaded
+// with conf.
+func (r *runner) selftestOutput(pkg string, geese []string, conf loadConfig) ([]byte, error) {
+	d, _, err := r.loadDepsConfig(pkg, geese, conf)
+	if err != nil {
+		return nil, err
+	}
+	d.hideDeps(r.visibility(nil))
+	var buf bytes.Buffer
+	writeDepsFile(&buf, &depsFile{Pkg: pkg, Entries: d.Entries(geese, nil)})
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,41 @@
+package depaware
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSelftest(t *testing.T) {
+	// The fake analysis finds an extra dependency of example.com/flaky
+	// when loading in parallel.
+	output := func(pkg string, geese []string, conf loadConfig) ([]byte, error) {
+		if len(conf.Env) != 1 || conf.Env[0] != "GOCACHE=/cache" {
+			t.Errorf("%s: env = %q; want the temporary cache", pkg, conf.Env)
+		}
+		out := pkg + " dependencies:\n\n        errors\n"
+		if pkg == "example.com/flaky" && conf.Parallel {
+			out += "        unsafe\n"
+		}
+		return []byte(out), nil
+	}
+	var stdout bytes.Buffer
+	r := &runner{Options: *NewOptions(), stdout: &stdout}
+	if err := r.selftest([]string{"example.com/ok"}, "/cache", output); err != nil {
+		t.Errorf("selftest of a stable package: %v", err)
+	}
+	if got, want := stdout.String(), "ok\texample.com/ok\n"; got != want {
+		t.Errorf("output = %q; want %q", got, want)
+	}
+
+	stdout.Reset()
+	err := r.selftest([]string{"example.com/flaky", "example.com/ok"}, "/cache", output)
+	if err == nil || err.Error() != "1 of 2 packages had different results" {
+		t.Errorf("selftest of a flaky package: err = %v", err)
+	}
+	got := stdout.String()
+	if !strings.HasPrefix(got, "FAIL\texample.com/flaky\n--- cold/serial\n+++ warm/parallel\n") ||
+		!strings.Contains(got, "+        unsafe\n") || !strings.HasSuffix(got, "ok\texample.com/ok\n") {
+		t.Errorf("output:\n%s", got)
+	}
+}
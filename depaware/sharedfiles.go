@@ -0,0 +1,26 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// sharedFileName returns the name of the depaware.txt file of pkg,
+// relative to its directory, for the -file name. A file outside the
+// package's directory, such as ../depaware.txt, would be shared by
+// sibling packages, so that each would overwrite the others' file;
+// instead, each gets its own, named after the last element of its
+// import path: ../depaware.foo.txt for package .../foo. The name only
+// depends on pkg, so that runs on any set of packages agree on it.
+func sharedFileName(pkg, name string) string {
+	clean := filepath.Clean(name)
+	if clean != ".." && !strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return name
+	}
+	ext := filepath.Ext(clean)
+	return strings.TrimSuffix(clean, ext) + "." + lastElem(pkg) + ext
+}
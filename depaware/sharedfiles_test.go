@@ -0,0 +1,37 @@
+package depaware
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSharedFileName(t *testing.T) {
+	tests := []struct{ pkg, name, want string }{
+		{"example.com/cmd/a", "depaware.txt", "depaware.txt"},
+		{"example.com/cmd/a", "deps/depaware.txt", "deps/depaware.txt"},
+		{"example.com/cmd/a", "../depaware.txt", "../depaware.a.txt"},
+		{"example.com/cmd/a/v2", "../../deps", "../../deps.a"},
+	}
+	for _, tt := range tests {
+		name := filepath.FromSlash(tt.name)
+		if got, want := sharedFileName(tt.pkg, name), filepath.FromSlash(tt.want); got != want {
+			t.Errorf("sharedFileName(%q, %q) = %q; want %q", tt.pkg, tt.name, got, want)
+		}
+	}
+}
+
+func TestDepawareFileShared(t *testing.T) {
+	r := &runner{Options: *NewOptions(), outputFiles: make(map[string]string)}
+	r.File = filepath.FromSlash("../../depaware.txt")
+	a, err := r.depawareFile("example.com/x/foo", filepath.FromSlash("/src/x/foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.FromSlash("/src/depaware.foo.txt"); a != want {
+		t.Errorf("file = %q; want %q", a, want)
+	}
+	if _, err := r.depawareFile("example.com/y/foo", filepath.FromSlash("/src/y/foo")); err == nil || !strings.Contains(err.Error(), "example.com/x/foo and example.com/y/foo would both write") {
+		t.Errorf("same last element: got error %v", err)
+	}
+}
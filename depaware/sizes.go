@@ -0,0 +1,259 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sizeGrowthMinBytes is the smallest size increase that -sizes reports,
+// so that small packages don't trip the percentage check by gaining a
+// function or two.
+const sizeGrowthMinBytes = 4 << 10
+
+// symbolStat is what the linked symbols of a package contribute to a
+// binary.
+type symbolStat struct {
+	Size  int64 // bytes
+	Count int   // number of symbols
+}
+
+// sizesFileName returns the name of the .sizes file that accompanies
+// the depaware.txt file named daFile.
+func sizesFileName(daFile string) string {
+	return strings.TrimSuffix(daFile, filepath.Ext(daFile)) + ".sizes"
+}
+
+// sizeGOOS returns the GOOS to build binaries for when attributing
+// sizes: the first of geese. It doesn't depend on the OS depaware runs
+// on, so that the sizes recorded on one developer's machine match
+// those computed by -check on another's, or in CI.
+func sizeGOOS(geese []string) string {
+	return geese[0]
+}
+
+// symbolsDirectiveValue returns the value of the symbols directive of
+// a depaware.txt file with symbol counts attributed on goos, such as
+// "count on linux".
+func symbolsDirectiveValue(goos string) string {
+	return "count on " + goos
+}
+
+// parseSymbolsDirective parses the value of a symbols directive,
+// returning whether it turns on symbol counts and the GOOS they're for.
+// The GOOS is empty for files from before it was recorded.
+func parseSymbolsDirective(v string) (on bool, goos string) {
+	f := strings.Fields(v)
+	if len(f) == 0 || f[0] != "count" {
+		return false, ""
+	}
+	if len(f) == 3 && f[1] == "on" {
+		return true, f[2]
+	}
+	return true, ""
+}
+
+// allPackages returns the set of all packages loaded in d, including
+// pkg itself and the internal packages that d.Deps omits.
+func (d *deps) allPackages(pkg string) map[string]bool {
+	m := map[string]bool{pkg: true}
+	for p, imps := range d.Imports {
+		m[p] = true
+		for _, imp := range imps {
+			m[imp] = true
+		}
+	}
+	return m
+}
+
+// binarySymbols builds the main package pkg for goos and returns the
+// stats of its linked symbols, keyed by the package in known that each
+// symbol belongs to. Symbols of other packages are ignored.
+func (r *runner) binarySymbols(pkg, goos string, known map[string]bool) (map[string]symbolStat, error) {
+	dir, err := ioutil.TempDir("", "depaware-sizes")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	bin := filepath.Join(dir, "bin")
+	args := []string{"build", "-o", bin}
+	if r.Tags != "" {
+		args = append(args, "-tags", r.Tags)
+	}
+	build := exec.Command("go", append(args, pkg)...)
+	build.Env = goEnv(goos)
+	if out, err := build.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("go build for GOOS=%s: %v\n%s", goos, err, out)
+	}
+	out, err := exec.Command("go", "tool", "nm", "-size", bin).Output()
+	if err != nil {
+		return nil, fmt.Errorf("go tool nm: %v", err)
+	}
+	return attributeSymbols(bytes.NewReader(out), known)
+}
+
+// setSymbolCounts sets the Symbols field of entries from stats as
+// returned by binarySymbols. Module entries, and directories collapsed
+// by -own-internal=collapse, get the total of their packages in d.Deps.
+func (d *deps) setSymbolCounts(entries []fileEntry, stats map[string]symbolStat) {
+	byMod := make(map[string]int)
+	for _, pkg := range d.Deps {
+		mod := stdModule
+		if m, ok := d.Module[pkg]; ok {
+			mod = m.Path
+		}
+		byMod[mod] += stats[pkg].Count
+	}
+	for i, e := range entries {
+		switch {
+		case e.Count > 0 && strings.HasSuffix(e.Pkg, "/..."):
+			// A directory collapsed by -own-internal=collapse.
+			match := matchPattern(e.Pkg)
+			for _, pkg := range d.Deps {
+				if match(pkg) {
+					entries[i].Symbols += stats[pkg].Count
+				}
+			}
+		case e.Count > 0:
+			entries[i].Symbols = byMod[e.Pkg]
+		default:
+			entries[i].Symbols = stats[e.Pkg].Count
+		}
+	}
+}
+
+// attributeSymbols parses the output of "go tool nm -size" and returns
+// the stats of the symbols of each package in known.
+func attributeSymbols(r io.Reader, known map[string]bool) (map[string]symbolStat, error) {
+	stats := make(map[string]symbolStat)
+	scan := bufio.NewScanner(r)
+	for scan.Scan() {
+		// Lines are "address size type name", where name may contain spaces.
+		fields := strings.Fields(scan.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || fields[2] == "U" {
+			continue
+		}
+		name := strings.Join(fields[3:], " ")
+		if pkg := symbolPackage(name, known); pkg != "" {
+			s := stats[pkg]
+			s.Size += size
+			s.Count++
+			stats[pkg] = s
+		}
+	}
+	return stats, scan.Err()
+}
+
+// symbolPackage returns the package in known that the linker symbol
+// name belongs to, or the empty string if there's none. For instance,
+// "github.com/foo/bar.(*T).Method" and "type:*github.com/foo/bar.T"
+// belong to "github.com/foo/bar".
+func symbolPackage(name string, known map[string]bool) string {
+	for _, prefix := range []string{"type:", "go:itab.", "go:"} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	name = strings.TrimLeft(name, "*[]")
+	best := ""
+	for i := 0; i < len(name); i++ {
+		if name[i] == '.' && known[name[:i]] {
+			best = name[:i]
+		}
+	}
+	return best
+}
+
+// writeSizes writes the .sizes file for pkg, with the size attributed
+// to each package in stats, to w.
+func writeSizes(w io.Writer, pkg, goos string, stats map[string]symbolStat) {
+	fmt.Fprintf(w, "%s binary size by package for GOOS=%s (generated by github.com/tailscale/depaware)\n\n", pkg, goos)
+	pkgs := make([]string, 0, len(stats))
+	for p := range stats {
+		pkgs = append(pkgs, p)
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return depLess(pkgs[i], pkgs[j]) })
+	for _, p := range pkgs {
+		fmt.Fprintf(w, "%10d %s\n", stats[p].Size, p)
+	}
+}
+
+// parseSizes parses a .sizes file as written by writeSizes, returning
+// the GOOS the sizes are for and the size of each package.
+func parseSizes(r io.Reader) (goos string, sizes map[string]int64, err error) {
+	sizes = make(map[string]int64)
+	scan := bufio.NewScanner(r)
+	for lineNum := 1; scan.Scan(); lineNum++ {
+		if lineNum == 1 {
+			_, rest, ok := strings.Cut(scan.Text(), " for GOOS=")
+			if goos, _, _ = strings.Cut(rest, " "); !ok || goos == "" {
+				return "", nil, fmt.Errorf("line 1: malformed header %q", scan.Text())
+			}
+			continue
+		}
+		if scan.Text() == "" {
+			continue
+		}
+		f := strings.Fields(scan.Text())
+		if len(f) != 2 {
+			return "", nil, fmt.Errorf("line %d: malformed size %q", lineNum, scan.Text())
+		}
+		n, err := strconv.ParseInt(f[0], 10, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		sizes[f[1]] = n
+	}
+	if err := scan.Err(); err != nil {
+		return "", nil, err
+	}
+	if goos == "" {
+		return "", nil, errors.New("empty file")
+	}
+	return goos, sizes, nil
+}
+
+// sizeRegressions returns a message for each package whose size in cur
+// is more than maxGrowth percent (and sizeGrowthMinBytes) larger than
+// in old. Packages that are new in cur aren't reported; depaware.txt
+// already shows them.
+func sizeRegressions(old map[string]int64, cur map[string]symbolStat, maxGrowth int) []string {
+	var msgs []string
+	pkgs := make([]string, 0, len(cur))
+	for p := range cur {
+		pkgs = append(pkgs, p)
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return depLess(pkgs[i], pkgs[j]) })
+	for _, p := range pkgs {
+		was, ok := old[p]
+		now := cur[p].Size
+		if !ok || now-was < sizeGrowthMinBytes || (now-was)*100 <= was*int64(maxGrowth) {
+			continue
+		}
+		msgs = append(msgs, fmt.Sprintf("%s grew from %d to %d bytes (+%d%%; at most %d%% allowed)",
+			p, was, now, (now-was)*100/maxInt64(was, 1), maxGrowth))
+	}
+	return msgs
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
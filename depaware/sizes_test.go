@@ -0,0 +1,134 @@
+package depaware
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAttributeSymbols(t *testing.T) {
+	const nm = `  4a3880          8 r $f64.3eb0000000000000
+  401000        120 T github.com/foo/bar.(*T).Method
+  402000         80 T github.com/foo/bar.init.0
+  403000         40 R type:*github.com/foo/bar.T
+  404000         64 T github.com/foo/bar/baz.F
+  405000       1000 T fmt.Println
+  406000         16 D gopkg.in/yaml.v2.version
+         0          U _cgo_something
+`
+	known := map[string]bool{
+		"github.com/foo/bar":     true,
+		"github.com/foo/bar/baz": true,
+		"fmt":                    true,
+		"gopkg.in/yaml.v2":       true,
+	}
+	got, err := attributeSymbols(strings.NewReader(nm), known)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]symbolStat{
+		"github.com/foo/bar":     {Size: 240, Count: 3},
+		"github.com/foo/bar/baz": {Size: 64, Count: 1},
+		"fmt":                    {Size: 1000, Count: 1},
+		"gopkg.in/yaml.v2":       {Size: 16, Count: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestSizesRoundTrip(t *testing.T) {
+	stats := map[string]symbolStat{
+		"github.com/foo/bar": {Size: 240, Count: 3},
+		"fmt":                {Size: 1000, Count: 1},
+	}
+	var buf bytes.Buffer
+	writeSizes(&buf, "example.com/cmd", "linux", stats)
+	goos, got, err := parseSizes(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{"github.com/foo/bar": 240, "fmt": 1000}
+	if goos != "linux" || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s, %v; want linux, %v", goos, got, want)
+	}
+}
+
+func TestParseSizesBadHeader(t *testing.T) {
+	for _, in := range []string{"", "example.com/cmd binary size by package\n\n        10 fmt\n"} {
+		if _, _, err := parseSizes(strings.NewReader(in)); err == nil {
+			t.Errorf("parseSizes(%q) succeeded; want an error", in)
+		}
+	}
+}
+
+func TestSizeGOOS(t *testing.T) {
+	// Whatever the OS depaware runs on.
+	if got := sizeGOOS([]string{"windows", "linux", "darwin"}); got != "windows" {
+		t.Errorf("sizeGOOS = %q; want windows", got)
+	}
+}
+
+func TestSizeRegressions(t *testing.T) {
+	old := map[string]int64{
+		"github.com/big/grew":   100000,
+		"github.com/big/ok":     100000,
+		"github.com/small/grew": 100,
+	}
+	cur := map[string]symbolStat{
+		"github.com/big/grew":   {Size: 120000},
+		"github.com/big/ok":     {Size: 105000},
+		"github.com/small/grew": {Size: 1000}, // +900%, but under sizeGrowthMinBytes
+		"github.com/new/dep":    {Size: 50000},
+	}
+	got := sizeRegressions(old, cur, 10)
+	want := []string{"github.com/big/grew grew from 100000 to 120000 bytes (+20%; at most 10% allowed)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestSetSymbolCounts(t *testing.T) {
+	d := testModuleDeps()
+	stats := map[string]symbolStat{
+		"github.com/foo/bar":     {Count: 10},
+		"github.com/foo/bar/sub": {Count: 5},
+		"golang.org/x/sys/unix":  {Count: 7},
+		"bytes":                  {Count: 3},
+		"os":                     {Count: 4},
+	}
+	entries := d.HybridEntries([]string{"linux", "windows"}, nil)
+	d.setSymbolCounts(entries, stats)
+	got := map[string]int{}
+	for _, e := range entries {
+		got[e.Pkg] = e.Symbols
+	}
+	want := map[string]int{
+		"github.com/foo/bar":    15,
+		"golang.org/x/sys/unix": 7,
+		"bytes":                 3,
+		"os":                    4,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestParseSymbolsDirective(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		on   bool
+		goos string
+	}{
+		{symbolsDirectiveValue("linux"), true, "linux"},
+		{"count", true, ""}, // before the GOOS was recorded
+		{"", false, ""},
+		{"something else", false, ""},
+	} {
+		on, goos := parseSymbolsDirective(tt.in)
+		if on != tt.on || goos != tt.goos {
+			t.Errorf("parseSymbolsDirective(%q) = %v, %q; want %v, %q", tt.in, on, goos, tt.on, tt.goos)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// snapshotVersion is the version of the snapshot format written by
+// writeSnapshot. readSnapshot rejects other versions.
+const snapshotVersion = 1
+
+// snapshot is the full merged import graph of a package, as written by
+// -snapshot, for queries that don't need to load packages again.
+type snapshot struct {
+	Version    int                       `json:"version"`
+	Package    string                    `json:"package"`
+	GOOS       []string                  `json:"goos"`
+	Deps       []string                  `json:"deps"`    // as listed in depaware.txt
+	DepOnOS    map[string][]string       `json:"depOnOS"` // dep -> GOOS values it's a dependency on
+	Imports    map[string][]string       `json:"imports"` // including hidden packages
+	UsesUnsafe []string                  `json:"usesUnsafe,omitempty"`
+	UsesCGO    []string                  `json:"usesCGO,omitempty"`
+	Modules    map[string]module.Version `json:"modules,omitempty"` // pkg -> module
+	MainModule string                    `json:"mainModule,omitempty"`
+	Provenance *provenance               `json:"provenance,omitempty"`
+}
+
+// newSnapshot returns the snapshot of d, the dependencies of pkg on
+// geese.
+func newSnapshot(pkg string, geese []string, d *deps) *snapshot {
+	s := &snapshot{
+		Version:    snapshotVersion,
+		Package:    pkg,
+		GOOS:       geese,
+		Deps:       d.Deps,
+		DepOnOS:    make(map[string][]string),
+		Imports:    d.Imports,
+		UsesUnsafe: trueKeys(d.UsesUnsafe),
+		UsesCGO:    trueKeys(d.UsesCGO),
+		Modules:    d.Module,
+		MainModule: d.MainModule,
+	}
+	for _, dep := range d.Deps {
+		for _, goos := range geese {
+			if d.DepOnOS[pkgGOOS{dep, goos}] {
+				s.DepOnOS[dep] = append(s.DepOnOS[dep], goos)
+			}
+		}
+	}
+	return s
+}
+
+// trueKeys returns the sorted keys of m whose values are true.
+func trueKeys(m map[string]bool) []string {
+	var keys []string
+	for k, v := range m {
+		if v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// deps returns the dependencies recorded in s.
+func (s *snapshot) deps() *deps {
+	d := &deps{
+		Deps:       s.Deps,
+		DepOnOS:    make(map[pkgGOOS]bool),
+		DepTo:      make(map[string][]string),
+		Imports:    make(map[string][]string),
+		UsesUnsafe: make(map[string]bool),
+		UsesCGO:    make(map[string]bool),
+		Module:     s.Modules,
+		MainModule: s.MainModule,
+	}
+	for from, imps := range s.Imports {
+		for _, to := range imps {
+			d.AddEdge(from, to)
+		}
+	}
+	for _, p := range s.UsesUnsafe {
+		d.UsesUnsafe[p] = true
+	}
+	for _, p := range s.UsesCGO {
+		d.UsesCGO[p] = true
+	}
+	for dep, geese := range s.DepOnOS {
+		for _, goos := range geese {
+			d.DepOnOS[pkgGOOS{dep, goos}] = true
+		}
+	}
+	d.normalize()
+	return d
+}
+
+// writeSnapshot writes s to the named file as gzipped JSON.
+func writeSnapshot(name string, s *snapshot) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(f)
+	if err := json.NewEncoder(zw).Encode(s); err != nil {
+		f.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readSnapshot reads a snapshot written by writeSnapshot.
+func readSnapshot(name string) (*snapshot, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	s := new(snapshot)
+	if err := json.NewDecoder(zr).Decode(s); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if s.Version != snapshotVersion {
+		return nil, fmt.Errorf("%s: unsupported snapshot version %d", name, s.Version)
+	}
+	return s, nil
+}
+
+// queryFlags adds the flags common to the snapshot query commands to
+// fs, returning a function that parses them along with args and
+// returns the dependencies to query and the remaining arguments.
+// Without -from-snapshot, the first argument is the package whose
+// dependencies are loaded.
+func (r *runner) queryFlags(fs *flag.FlagSet) func(args []string) (root string, d *deps, rest []string, err error) {
+	from := fs.String("from-snapshot", "", "name of a snapshot file written by -snapshot to query instead of loading packages")
+	return func(args []string) (string, *deps, []string, error) {
+		if err := fs.Parse(args); err != nil {
+			return "", nil, nil, err
+		}
+		if *from != "" {
+			s, err := readSnapshot(*from)
+			if err != nil {
+				return "", nil, nil, err
+			}
+			r.recordSnapshotRead()
+			return s.Package, s.deps(), fs.Args(), nil
+		}
+		if fs.NArg() == 0 {
+			return "", nil, nil, errors.New("need a package or -from-snapshot")
+		}
+		root := fs.Arg(0)
+		d, _, err := r.loadDeps(root, strings.Split(r.GOOS, ","))
+		if err != nil {
+			return "", nil, nil, err
+		}
+		d.hideDeps(r.visibility(nil))
+		return root, d, fs.Args()[1:], nil
+	}
+}
+
+// runWhy implements "depaware why", which prints the shortest import
+// chain from the root package to a dependency, and its direct
+// importers.
+//
+// Usage:
+//
+//	depaware why -from-snapshot=file dep
+//	depaware why root dep
+func (r *runner) runWhy(args []string) error {
+	fs := flag.NewFlagSet("why", flag.ContinueOnError)
+	fs.SetOutput(r.stderr)
+	parse := r.queryFlags(fs)
+	root, d, rest, err := parse(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return errors.New("usage: depaware why [-from-snapshot=file | root] dep")
+	}
+	dep := rest[0]
+	chain := d.shortestChain(root, dep)
+	if chain == nil {
+		return fmt.Errorf("%s doesn't depend on %s", root, dep)
+	}
+	fmt.Fprintln(r.stdout, strings.Join(chain, " -> "))
+	fmt.Fprintf(r.stdout, "(imported by %s)\n", strings.Join(d.DepTo[dep], ", "))
+	return nil
+}
+
+// runRdeps implements "depaware rdeps", which lists the packages that
+// import a dependency, directly or indirectly.
+//
+// Usage:
+//
+//	depaware rdeps -from-snapshot=file dep
+//	depaware rdeps root dep
+func (r *runner) runRdeps(args []string) error {
+	fs := flag.NewFlagSet("rdeps", flag.ContinueOnError)
+	fs.SetOutput(r.stderr)
+	parse := r.queryFlags(fs)
+	_, d, rest, err := parse(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return errors.New("usage: depaware rdeps [-from-snapshot=file | root] dep")
+	}
+	for _, p := range d.reverseDeps(rest[0]) {
+		fmt.Fprintln(r.stdout, p)
+	}
+	return nil
+}
+
+// runTop implements "depaware top", which lists the dependencies that
+// pull in the most other packages.
+//
+// Usage:
+//
+//	depaware top [-n=N] -from-snapshot=file
+//	depaware top [-n=N] root
+func (r *runner) runTop(args []string) error {
+	fs := flag.NewFlagSet("top", flag.ContinueOnError)
+	fs.SetOutput(r.stderr)
+	n := fs.Int("n", 20, "number of dependencies to list")
+	parse := r.queryFlags(fs)
+	root, d, rest, err := parse(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errors.New("usage: depaware top [-n=N] [-from-snapshot=file | root]")
+	}
+	writeTop(r.stdout, root, d.heaviestDeps(), *n)
+	return nil
+}
+
+// shortestChain returns the shortest chain of imports from root, or
+// one of d.OtherRoots, to dep, or nil if none of them depends on dep.
+// Ties are broken by the import paths, so the result is deterministic.
+func (d *deps) shortestChain(root, dep string) []string {
+	prev := map[string]string{root: ""}
+	queue := []string{root}
+	for _, other := range d.OtherRoots {
+		prev[other] = ""
+		queue = append(queue, other)
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if p == dep {
+			var chain []string
+			for ; p != ""; p = prev[p] {
+				chain = append([]string{p}, chain...)
+			}
+			return chain
+		}
+		for _, imp := range d.Imports[p] {
+			if _, ok := prev[imp]; !ok {
+				prev[imp] = p
+				queue = append(queue, imp)
+			}
+		}
+	}
+	return nil
+}
+
+// reverseDeps returns the packages that import dep, directly or
+// indirectly, sorted as for depLess.
+func (d *deps) reverseDeps(dep string) []string {
+	seen := map[string]bool{dep: true}
+	queue := []string{dep}
+	var rdeps []string
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, from := range d.DepTo[p] {
+			if !seen[from] {
+				seen[from] = true
+				rdeps = append(rdeps, from)
+				queue = append(queue, from)
+			}
+		}
+	}
+	sort.Slice(rdeps, func(i, j int) bool { return depLess(rdeps[i], rdeps[j]) })
+	return rdeps
+}
+
+// depWeight is the number of packages a dependency imports, directly
+// or indirectly.
+type depWeight struct {
+	Pkg   string
+	Count int
+}
+
+// heaviestDeps returns the weight of each of d.Deps, sorted by
+// decreasing weight.
+func (d *deps) heaviestDeps() []depWeight {
+	weights := make([]depWeight, 0, len(d.Deps))
+	for _, dep := range d.Deps {
+		weights = append(weights, depWeight{dep, d.TransitiveCount(dep) - 1})
+	}
+	sort.SliceStable(weights, func(i, j int) bool { return weights[i].Count > weights[j].Count })
+	return weights
+}
+
+// writeTop writes the first n of weights, the dependencies of root, to w.
+func writeTop(w io.Writer, root string, weights []depWeight, n int) {
+	fmt.Fprintf(w, "%s dependencies by number of packages they import:\n\n", root)
+	for i, dw := range weights {
+		if i == n {
+			break
+		}
+		fmt.Fprintf(w, " %5d %s\n", dw.Count, dw.Pkg)
+	}
+}
@@ -0,0 +1,80 @@
+package depaware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testSnapshotDeps() *deps {
+	d := new(deps)
+	for _, e := range [][2]string{
+		{"example.com/cmd", "github.com/a/lib"},
+		{"example.com/cmd", "fmt"},
+		{"github.com/a/lib", "github.com/a/lib/util"},
+		{"github.com/a/lib", "unsafe"},
+		{"github.com/a/lib/util", "fmt"},
+		{"fmt", "io"},
+	} {
+		d.AddEdge(e[0], e[1])
+	}
+	for _, dep := range []string{"github.com/a/lib", "github.com/a/lib/util", "fmt", "io"} {
+		d.AddDep(dep, "linux")
+	}
+	d.AddDep("github.com/a/lib/util", "windows")
+	d.normalize()
+	return d
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	d := testSnapshotDeps()
+	dir, err := ioutil.TempDir("", "depaware-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "deps.snap")
+	geese := []string{"linux", "windows"}
+	if err := writeSnapshot(name, newSnapshot("example.com/cmd", geese, d)); err != nil {
+		t.Fatal(err)
+	}
+	s, err := readSnapshot(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.deps()
+	var want, gotBuf bytes.Buffer
+	writeDepsFile(&want, &depsFile{Pkg: "example.com/cmd", Entries: d.Entries(geese, nil)})
+	writeDepsFile(&gotBuf, &depsFile{Pkg: s.Package, Entries: got.Entries(s.GOOS, nil)})
+	if want.String() != gotBuf.String() {
+		t.Errorf("snapshot entries differ; got:\n%s\nwant:\n%s", gotBuf.String(), want.String())
+	}
+	if !reflect.DeepEqual(got.Imports, d.Imports) {
+		t.Errorf("Imports = %v; want %v", got.Imports, d.Imports)
+	}
+}
+
+func TestSnapshotQueries(t *testing.T) {
+	d := testSnapshotDeps()
+	if got, want := d.shortestChain("example.com/cmd", "io"), []string{"example.com/cmd", "fmt", "io"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("shortestChain = %q; want %q", got, want)
+	}
+	if got := d.shortestChain("example.com/cmd", "os"); got != nil {
+		t.Errorf("shortestChain to non-dependency = %q; want nil", got)
+	}
+	if got, want := d.reverseDeps("fmt"), []string{"example.com/cmd", "github.com/a/lib", "github.com/a/lib/util"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("reverseDeps = %q; want %q", got, want)
+	}
+	want := []depWeight{
+		{"github.com/a/lib", 4},
+		{"github.com/a/lib/util", 2},
+		{"fmt", 1},
+		{"io", 0},
+	}
+	if got := d.heaviestDeps(); !reflect.DeepEqual(got, want) {
+		t.Errorf("heaviestDeps = %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// runSnapshotDiff implements "depaware snapshot-diff", which reports
+// the structural differences between two snapshots written by
+// -snapshot, such as nightly artifacts: not only the dependencies added
+// and removed, but also the import edges, platforms, unsafe and cgo
+// use, and module versions that changed.
+//
+// Usage:
+//
+//	depaware snapshot-diff old.snap new.snap
+func (r *runner) runSnapshotDiff(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: depaware snapshot-diff old.snap new.snap")
+	}
+	a, err := readSnapshot(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := readSnapshot(args[1])
+	if err != nil {
+		return err
+	}
+	writeSnapshotDiff(r.stdout, diffSnapshots(a, b))
+	return nil
+}
+
+// snapshotDiff is the difference between two snapshots. Each field is a
+// list of human-readable lines, sorted.
+type snapshotDiff struct {
+	Old, New     string // packages of the snapshots
+	AddedDeps    []string
+	RemovedDeps  []string
+	Platforms    []string // "pkg: linux,darwin -> linux"
+	Unsafe       []string // "pkg: now uses unsafe", etc.
+	Modules      []string // "path: v1.0.0 -> v1.1.0"
+	AddedEdges   []string // "from -> to"
+	RemovedEdges []string
+}
+
+// diffSnapshots returns the differences from a to b.
+func diffSnapshots(a, b *snapshot) *snapshotDiff {
+	diff := &snapshotDiff{Old: a.Package, New: b.Package}
+	diff.AddedDeps, diff.RemovedDeps = setDiff(a.Deps, b.Deps)
+
+	for _, dep := range b.Deps {
+		was, ok := a.DepOnOS[dep]
+		if !ok {
+			continue
+		}
+		if now := b.DepOnOS[dep]; strings.Join(was, ",") != strings.Join(now, ",") {
+			diff.Platforms = append(diff.Platforms, fmt.Sprintf("%s: %s -> %s", dep, strings.Join(was, ","), strings.Join(now, ",")))
+		}
+	}
+
+	for _, use := range []struct {
+		what     string
+		old, new []string
+	}{
+		{"unsafe", a.UsesUnsafe, b.UsesUnsafe},
+		{"cgo", a.UsesCGO, b.UsesCGO},
+	} {
+		added, removed := setDiff(use.old, use.new)
+		for _, p := range added {
+			diff.Unsafe = append(diff.Unsafe, fmt.Sprintf("%s: now uses %s", p, use.what))
+		}
+		for _, p := range removed {
+			diff.Unsafe = append(diff.Unsafe, fmt.Sprintf("%s: no longer uses %s", p, use.what))
+		}
+	}
+
+	oldMods, newMods := snapshotModules(a), snapshotModules(b)
+	for path, v := range newMods {
+		if ov, ok := oldMods[path]; !ok {
+			diff.Modules = append(diff.Modules, fmt.Sprintf("%s: added at %s", path, versionOrNone(v)))
+		} else if ov != v {
+			diff.Modules = append(diff.Modules, fmt.Sprintf("%s: %s -> %s", path, versionOrNone(ov), versionOrNone(v)))
+		}
+	}
+	for path, v := range oldMods {
+		if _, ok := newMods[path]; !ok {
+			diff.Modules = append(diff.Modules, fmt.Sprintf("%s: removed (was %s)", path, versionOrNone(v)))
+		}
+	}
+
+	diff.AddedEdges, diff.RemovedEdges = setDiff(edges(a), edges(b))
+
+	for _, lines := range [][]string{diff.Platforms, diff.Unsafe, diff.Modules} {
+		sort.Strings(lines)
+	}
+	return diff
+}
+
+// setDiff returns the elements of b that aren't in a and those of a
+// that aren't in b, sorted.
+func setDiff(a, b []string) (added, removed []string) {
+	inA := make(map[string]bool, len(a))
+	for _, s := range a {
+		inA[s] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+		if !inA[s] {
+			added = append(added, s)
+		}
+	}
+	for _, s := range a {
+		if !inB[s] {
+			removed = append(removed, s)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// snapshotModules returns the version of each module in s, other than
+// the main module.
+func snapshotModules(s *snapshot) map[string]string {
+	m := make(map[string]string)
+	for _, mv := range s.Modules {
+		if mv.Path != s.MainModule {
+			m[mv.Path] = mv.Version
+		}
+	}
+	return m
+}
+
+// edges returns the import edges of s as "from -> to" strings.
+func edges(s *snapshot) []string {
+	var es []string
+	for from, imps := range s.Imports {
+		for _, to := range imps {
+			es = append(es, from+" -> "+to)
+		}
+	}
+	return es
+}
+
+// writeSnapshotDiff writes diff to w, one section per kind of change.
+func writeSnapshotDiff(w io.Writer, diff *snapshotDiff) {
+	if diff.Old == diff.New {
+		fmt.Fprintf(w, "%s snapshot changes:\n", diff.New)
+	} else {
+		fmt.Fprintf(w, "snapshot changes from %s to %s:\n", diff.Old, diff.New)
+	}
+	n := 0
+	for _, sec := range []struct {
+		title  string
+		prefix string
+		lines  []string
+	}{
+		{"Added dependencies", "+ ", diff.AddedDeps},
+		{"Removed dependencies", "- ", diff.RemovedDeps},
+		{"Changed platforms", "", diff.Platforms},
+		{"Changed unsafe and cgo use", "", diff.Unsafe},
+		{"Changed modules", "", diff.Modules},
+		{"Added imports", "+ ", diff.AddedEdges},
+		{"Removed imports", "- ", diff.RemovedEdges},
+	} {
+		if len(sec.lines) == 0 {
+			continue
+		}
+		n += len(sec.lines)
+		fmt.Fprintf(w, "\n%s:\n", sec.title)
+		for _, l := range sec.lines {
+			fmt.Fprintf(w, "\t%s%s\n", sec.prefix, l)
+		}
+	}
+	if n == 0 {
+		fmt.Fprintln(w, "\nNo changes.")
+	}
+}
@@ -0,0 +1,56 @@
+package depaware
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	geese := []string{"linux", "windows"}
+	a := newSnapshot("example.com/cmd", geese, testSnapshotDeps())
+	a.Modules = map[string]module.Version{
+		"github.com/a/lib":      {Path: "github.com/a/lib", Version: "v1.0.0"},
+		"github.com/a/lib/util": {Path: "github.com/a/lib", Version: "v1.0.0"},
+	}
+
+	d := testSnapshotDeps()
+	d.AddEdge("github.com/a/lib/util", "os")
+	d.AddEdge("github.com/a/lib/util", "runtime/cgo")
+	d.AddDep("os", "linux")
+	d.AddDep("io", "windows")
+	d.normalize()
+	b := newSnapshot("example.com/cmd", geese, d)
+	b.Modules = map[string]module.Version{
+		"github.com/a/lib":      {Path: "github.com/a/lib", Version: "v1.1.0"},
+		"github.com/a/lib/util": {Path: "github.com/a/lib", Version: "v1.1.0"},
+	}
+
+	got := diffSnapshots(a, b)
+	want := &snapshotDiff{
+		Old:        "example.com/cmd",
+		New:        "example.com/cmd",
+		AddedDeps:  []string{"os"},
+		Platforms:  []string{"io: linux -> linux,windows"},
+		Unsafe:     []string{"github.com/a/lib/util: now uses cgo"},
+		Modules:    []string{"github.com/a/lib: v1.0.0 -> v1.1.0"},
+		AddedEdges: []string{"github.com/a/lib/util -> os", "github.com/a/lib/util -> runtime/cgo"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v; want %+v", got, want)
+	}
+
+	var buf bytes.Buffer
+	writeSnapshotDiff(&buf, got)
+	if !strings.Contains(buf.String(), "\nAdded imports:\n\t+ github.com/a/lib/util -> os\n") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+	buf.Reset()
+	writeSnapshotDiff(&buf, diffSnapshots(a, a))
+	if !strings.HasSuffix(buf.String(), "No changes.\n") {
+		t.Errorf("unexpected output for identical snapshots:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,324 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// loadCounts are how a run got the import graphs it worked on.
+type loadCounts struct {
+	Loads         int `json:"loads"`                   // graphs loaded with the go command
+	ParallelLoads int `json:"parallelLoads,omitempty"` // of Loads, those that loaded several GOOS values concurrently
+	SnapshotReads int `json:"snapshotReads,omitempty"` // graphs read from -from-snapshot files instead
+}
+
+// runRecord is a line of the -stats-file file: how a single run went.
+type runRecord struct {
+	Time     time.Time     `json:"time"`
+	Version  string        `json:"version"` // of depaware
+	Command  string        `json:"command"` // the subcommand, or "check", "update" or "print"
+	Failed   bool          `json:"failed,omitempty"`
+	Duration time.Duration `json:"duration"` // in nanoseconds, like the rest
+
+	// Load, Merge and Write are the total time spent in each phase of
+	// processing packages. Concurrent loads overlap, so Load can
+	// exceed Duration.
+	Load  time.Duration `json:"load"`
+	Merge time.Duration `json:"merge"`
+	Write time.Duration `json:"write"`
+
+	loadCounts
+}
+
+// recordLoad records that an import graph was loaded with the go
+// command, for -stats-file, and whether its GOOS values were loaded
+// concurrently.
+func (r *runner) recordLoad(parallel bool) {
+	if r.StatsFile == "" {
+		return
+	}
+	r.timingsMu.Lock()
+	defer r.timingsMu.Unlock()
+	r.loads.Loads++
+	if parallel {
+		r.loads.ParallelLoads++
+	}
+}
+
+// recordSnapshotRead records that an import graph was read from a
+// snapshot rather than loaded, for -stats-file.
+func (r *runner) recordSnapshotRead() {
+	if r.StatsFile == "" {
+		return
+	}
+	r.timingsMu.Lock()
+	defer r.timingsMu.Unlock()
+	r.loads.SnapshotReads++
+}
+
+// runCommand returns the name of what a run with args does, for
+// runRecord.Command.
+func (r *runner) runCommand(args []string) string {
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			return args[0]
+		}
+	}
+	switch {
+	case r.Check:
+		return "check"
+	case r.Update:
+		return "update"
+	}
+	return "print"
+}
+
+// saveStats appends the stats of the run with args that started at
+// start and failed with err, if non-nil, to the -stats-file file.
+// Failing to doesn't fail the run, which has done its job. Runs of
+// "depaware stats" aren't recorded, so as not to skew what they
+// report.
+func (r *runner) saveStats(args []string, start time.Time, err error) {
+	st := runRecord{
+		Time:     start.UTC(),
+		Version:  toolVersion(),
+		Command:  r.runCommand(args),
+		Failed:   err != nil,
+		Duration: time.Since(start),
+	}
+	if st.Command == "stats" {
+		return
+	}
+	r.timingsMu.Lock()
+	for _, t := range r.timings {
+		switch t.Phase {
+		case "load":
+			st.Load += t.Dur
+		case "merge":
+			st.Merge += t.Dur
+		case "write":
+			st.Write += t.Dur
+		}
+	}
+	st.loadCounts = r.loads
+	r.timingsMu.Unlock()
+	if err := appendStats(r.StatsFile, st); err != nil {
+		r.logger.Warn("-stats-file: " + err.Error())
+	}
+}
+
+// appendStats appends st to the named stats file as a line of JSON,
+// creating the file and its directory if need be.
+func appendStats(name string, st runRecord) error {
+	line, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readStats parses the lines of a stats file.
+func readStats(r io.Reader) ([]runRecord, error) {
+	var stats []runRecord
+	scan := bufio.NewScanner(r)
+	lineNum := 0
+	for scan.Scan() {
+		lineNum++
+		if len(scan.Bytes()) == 0 {
+			continue
+		}
+		var st runRecord
+		if err := json.Unmarshal(scan.Bytes(), &st); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		stats = append(stats, st)
+	}
+	return stats, scan.Err()
+}
+
+// commandStats summarizes the runs of one command.
+type commandStats struct {
+	Command  string        `json:"command"`
+	Runs     int           `json:"runs"`
+	Failed   int           `json:"failed,omitempty"`
+	Mean     time.Duration `json:"mean"`
+	Max      time.Duration `json:"max"`
+	MeanLoad time.Duration `json:"meanLoad"`
+}
+
+// statsSummary is what "depaware stats -self" reports.
+type statsSummary struct {
+	Runs     int            `json:"runs"`
+	From     time.Time      `json:"from"` // of the first run
+	To       time.Time      `json:"to"`   // of the last run
+	Commands []commandStats `json:"commands"`
+
+	loadCounts // totals over all runs
+
+	// SnapshotHitRate is the fraction of import graphs read from
+	// snapshots rather than loaded.
+	SnapshotHitRate float64 `json:"snapshotHitRate"`
+
+	// MeanParallel and MeanSerial are the mean durations of the runs
+	// that loaded import graphs, all of them with several GOOS values
+	// loaded concurrently, or none of them.
+	MeanParallel time.Duration `json:"meanParallel,omitempty"`
+	MeanSerial   time.Duration `json:"meanSerial,omitempty"`
+}
+
+// summarizeStats summarizes stats, the runs recorded in a stats file.
+func summarizeStats(stats []runRecord) statsSummary {
+	s := statsSummary{Runs: len(stats), Commands: []commandStats{}}
+	byCmd := make(map[string]*commandStats)
+	total := make(map[string]time.Duration)
+	load := make(map[string]time.Duration)
+	var parallel, serial []time.Duration
+	for _, st := range stats {
+		if s.From.IsZero() || st.Time.Before(s.From) {
+			s.From = st.Time
+		}
+		if st.Time.After(s.To) {
+			s.To = st.Time
+		}
+		c, ok := byCmd[st.Command]
+		if !ok {
+			c = &commandStats{Command: st.Command}
+			byCmd[st.Command] = c
+		}
+		c.Runs++
+		if st.Failed {
+			c.Failed++
+		}
+		total[st.Command] += st.Duration
+		load[st.Command] += st.Load
+		if st.Duration > c.Max {
+			c.Max = st.Duration
+		}
+		s.Loads += st.Loads
+		s.ParallelLoads += st.ParallelLoads
+		s.SnapshotReads += st.SnapshotReads
+		switch {
+		case st.Loads == 0:
+		case st.ParallelLoads == st.Loads:
+			parallel = append(parallel, st.Duration)
+		case st.ParallelLoads == 0:
+			serial = append(serial, st.Duration)
+		}
+	}
+	for _, c := range byCmd {
+		c.Mean = total[c.Command] / time.Duration(c.Runs)
+		c.MeanLoad = load[c.Command] / time.Duration(c.Runs)
+		s.Commands = append(s.Commands, *c)
+	}
+	sort.Slice(s.Commands, func(i, j int) bool { return s.Commands[i].Command < s.Commands[j].Command })
+	if n := s.Loads + s.SnapshotReads; n > 0 {
+		s.SnapshotHitRate = float64(s.SnapshotReads) / float64(n)
+	}
+	s.MeanParallel = meanDuration(parallel)
+	s.MeanSerial = meanDuration(serial)
+	return s
+}
+
+// meanDuration returns the mean of ds, or zero if there are none.
+func meanDuration(ds []time.Duration) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, d := range ds {
+		sum += d
+	}
+	return sum / time.Duration(len(ds))
+}
+
+// writeStatsSummary writes s to w as a table of the commands followed
+// by how the runs got their import graphs.
+func writeStatsSummary(w io.Writer, s statsSummary) {
+	if s.Runs == 0 {
+		fmt.Fprintln(w, "no runs recorded")
+		return
+	}
+	fmt.Fprintf(w, "%d runs from %s to %s\n\n", s.Runs, s.From.Format("2006-01-02"), s.To.Format("2006-01-02"))
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "command\truns\tfailed\tmean\tmax\tmean load\n")
+	for _, c := range s.Commands {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%v\t%v\t%v\n", c.Command, c.Runs, c.Failed,
+			c.Mean.Round(time.Millisecond), c.Max.Round(time.Millisecond), c.MeanLoad.Round(time.Millisecond))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "import graphs: %d loaded, %d read from snapshots (%.0f%% snapshot hit rate)\n",
+		s.Loads, s.SnapshotReads, 100*s.SnapshotHitRate)
+	fmt.Fprintf(w, "concurrent GOOS loads: %d of %d", s.ParallelLoads, s.Loads)
+	if s.MeanParallel > 0 && s.MeanSerial > 0 {
+		fmt.Fprintf(w, "; mean run %v with them, %v without", s.MeanParallel.Round(time.Millisecond), s.MeanSerial.Round(time.Millisecond))
+	}
+	fmt.Fprintln(w)
+}
+
+// runStats implements "depaware stats -self", which summarizes the
+// runs recorded in the -stats-file file: how long each command takes,
+// and how often import graphs came from snapshots or were loaded with
+// their GOOS values concurrently, to see what those save and to attach
+// to performance issues. The file is only ever written and read
+// locally.
+//
+// Usage:
+//
+//	depaware -stats-file=file stats -self [-json]
+func (r *runner) runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.SetOutput(r.stderr)
+	self := fs.Bool("self", false, "summarize depaware's own runs, as recorded by -stats-file")
+	asJSON := fs.Bool("json", false, "print the summary as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 || !*self {
+		return errors.New("usage: depaware -stats-file=file stats -self [-json]")
+	}
+	if r.StatsFile == "" {
+		return errors.New("-self requires -stats-file, the file runs were recorded in")
+	}
+	f, err := os.Open(r.StatsFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stats, err := readStats(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", r.StatsFile, err)
+	}
+	s := summarizeStats(stats)
+	if *asJSON {
+		enc := json.NewEncoder(r.stdout)
+		enc.SetIndent("", "\t")
+		return enc.Encode(s)
+	}
+	writeStatsSummary(r.stdout, s)
+	return nil
+}
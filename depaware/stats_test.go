@@ -0,0 +1,69 @@
+package depaware
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsRoundTrip(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "depaware", "stats.jsonl")
+	day := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	recs := []runRecord{
+		{Time: day, Command: "check", Duration: 2 * time.Second, Load: 1500 * time.Millisecond, loadCounts: loadCounts{Loads: 2}},
+		{Time: day.Add(48 * time.Hour), Command: "check", Failed: true, Duration: 4 * time.Second, Load: 2500 * time.Millisecond, loadCounts: loadCounts{Loads: 2}},
+		{Time: day.Add(time.Hour), Command: "count", Duration: time.Second, loadCounts: loadCounts{Loads: 1, ParallelLoads: 1}},
+		{Time: day.Add(2 * time.Hour), Command: "why", Duration: 100 * time.Millisecond, loadCounts: loadCounts{SnapshotReads: 1}},
+	}
+	for _, rec := range recs {
+		if err := appendStats(name, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := &runner{Options: Options{StatsFile: name}}
+	var out bytes.Buffer
+	r.stdout = &out
+	if err := r.runStats([]string{"-self"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `4 runs from 2026-10-01 to 2026-10-03
+
+command  runs  failed  mean   max    mean load
+check    2     1       3s     4s     2s
+count    1     0       1s     1s     0s
+why      1     0       100ms  100ms  0s
+
+import graphs: 5 loaded, 1 read from snapshots (17% snapshot hit rate)
+concurrent GOOS loads: 1 of 5; mean run 1s with them, 3s without
+`
+	if got := out.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	for _, tt := range []struct {
+		opts Options
+		args []string
+		want string
+	}{
+		{Options{Check: true}, []string{"./cmd/..."}, "check"},
+		{Options{Update: true}, nil, "update"},
+		{Options{}, []string{"."}, "print"},
+		{Options{Check: true}, []string{"workspace"}, "workspace"},
+	} {
+		r := &runner{Options: tt.opts}
+		if got := r.runCommand(tt.args); got != tt.want {
+			t.Errorf("runCommand(%q) with %+v = %q; want %q", tt.args, tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestReadStatsBadLine(t *testing.T) {
+	_, err := readStats(strings.NewReader("{\"command\":\"check\"}\nnot json\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("readStats error = %v; want one for line 2", err)
+	}
+}
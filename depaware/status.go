@@ -0,0 +1,164 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// statusRow is a line of the "depaware status" dashboard: one
+// depaware.txt file.
+type statusRow struct {
+	File       string
+	Pkg        string
+	Deps       int    // packages, or in module files, the packages of all modules
+	Unsafe     int    // entries that use unsafe
+	CGO        int    // entries that use cgo
+	Violations int    // of the policy, at warning severity or more, not in the baseline
+	Updated    string // date of the last commit of the file, if known
+	Version    string // of depaware that generated the file, with -record-version
+}
+
+// runStatus implements "depaware status", which prints a one-screen
+// dashboard of all the depaware.txt files committed to the current git
+// repo, for a periodic look at the health of a repo's dependencies
+// without opening each file. Like "depaware policy test", it reads the
+// committed files and doesn't load any packages. Violations are of the
+// -policy file, if any, not counting those in the -baseline file.
+//
+// Usage:
+//
+//	depaware [-policy=file [-baseline=file]] status
+func (r *runner) runStatus(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: depaware [-policy=file [-baseline=file]] status")
+	}
+	var p *policy
+	var b baseline
+	var err error
+	if r.Policy != "" {
+		if p, err = readPolicy(r.Policy); err != nil {
+			return err
+		}
+	}
+	if r.Baseline != "" {
+		if b, err = readBaseline(r.Baseline); err != nil {
+			return err
+		}
+	}
+	files, err := r.trackedDepsFiles()
+	if err != nil {
+		return err
+	}
+	var rows []statusRow
+	for _, name := range files {
+		row, err := r.fileStatus(name, p, b)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+	writeStatus(r.stdout, rows, p != nil)
+	return nil
+}
+
+// fileStatus returns the dashboard row of the named depaware.txt file,
+// with violations of p, if non-nil, that aren't in b.
+func (r *runner) fileStatus(name string, p *policy, b baseline) (statusRow, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return statusRow{}, err
+	}
+	f, err := parseDepsFile(bytes.NewReader(data), r.MaxLineBytes)
+	if err != nil {
+		return statusRow{}, fmt.Errorf("%s: %v", name, err)
+	}
+	row := statusRow{
+		File:    name,
+		Pkg:     f.Pkg,
+		Updated: lastCommitDate(name),
+		Version: f.Directives[versionDirective],
+	}
+	for _, e := range f.Entries {
+		if e.Count > 0 {
+			row.Deps += e.Count
+		} else {
+			row.Deps++
+		}
+		if e.Unsafe {
+			row.Unsafe++
+		}
+		if e.CGO {
+			row.CGO++
+		}
+	}
+	if p != nil {
+		vs := b.Filter(f.Pkg, p.Evaluate(&policyInput{
+			Pkg:        f.Pkg,
+			Entries:    f.Entries,
+			MainModule: modulePathFor(filepath.Dir(name)),
+		}))
+		for _, v := range vs {
+			if v.Severity >= severityWarn {
+				row.Violations++
+			}
+		}
+	}
+	return row, nil
+}
+
+// lastCommitDate returns the date of the last git commit of the named
+// file, such as "2024-05-01", or the empty string if it's unknown.
+func lastCommitDate(name string) string {
+	out, err := exec.Command("git", "log", "-1", "--format=%cd", "--date=short", "--", name).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// writeStatus writes the dashboard of rows to w, with a violations
+// column if withPolicy.
+func writeStatus(w io.Writer, rows []statusRow, withPolicy bool) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	header := "deps\tunsafe\tcgo\t"
+	if withPolicy {
+		header += "new violations\t"
+	}
+	fmt.Fprintf(tw, "%supdated\tdepaware\tfile\n", header)
+	violations, withViolations := 0, 0
+	for _, r := range rows {
+		line := fmt.Sprintf("%d\t%d\t%d\t", r.Deps, r.Unsafe, r.CGO)
+		if withPolicy {
+			line += fmt.Sprintf("%d\t", r.Violations)
+		}
+		fmt.Fprintf(tw, "%s%s\t%s\t%s\n", line, orDash(r.Updated), orDash(r.Version), r.File)
+		violations += r.Violations
+		if r.Violations > 0 {
+			withViolations++
+		}
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n%d files", len(rows))
+	if withPolicy {
+		fmt.Fprintf(w, ", %d new violations in %d of them", violations, withViolations)
+	}
+	fmt.Fprintln(w)
+}
+
+// orDash returns s, or "-" if it's empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,74 @@
+package depaware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileStatus(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "depaware.txt")
+	var buf bytes.Buffer
+	writeDepsFile(&buf, &depsFile{
+		Pkg:        "example.com/cmd",
+		Directives: map[string]string{versionDirective: "v0.1.0"},
+		Entries: []fileEntry{
+			{Pkg: "github.com/a/lib", Unsafe: true, Why: "example.com/cmd"},
+			{Pkg: "github.com/evil/thing", CGO: true, Why: "github.com/a/lib"},
+			{Pkg: "fmt", Why: "example.com/cmd"},
+		},
+	})
+	contents := buf.String()
+	if err := ioutil.WriteFile(name, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := parsePolicy(strings.NewReader("deny github.com/evil/...\ndeny-cgo github.com/...\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &runner{Options: *NewOptions()}
+	row, err := r.fileStatus(name, p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := statusRow{File: name, Pkg: "example.com/cmd", Deps: 3, Unsafe: 1, CGO: 1, Violations: 2, Version: "v0.1.0"}
+	if row != want {
+		t.Errorf("got %+v; want %+v", row, want)
+	}
+
+	// Violations in the baseline aren't new.
+	vs := p.Evaluate(&policyInput{Pkg: "example.com/cmd", Entries: mustParse(t, contents).Entries})
+	b := baseline{}
+	b.Add("example.com/cmd", vs[:1])
+	if row, _ := r.fileStatus(name, p, b); row.Violations != 1 {
+		t.Errorf("with a baseline, got %d violations; want 1", row.Violations)
+	}
+}
+
+func mustParse(t *testing.T, contents string) *depsFile {
+	t.Helper()
+	f, err := parseDepsFile(strings.NewReader(contents), defaultMaxLineBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestWriteStatus(t *testing.T) {
+	var buf bytes.Buffer
+	writeStatus(&buf, []statusRow{
+		{File: "cmd/a/depaware.txt", Deps: 120, Unsafe: 4, CGO: 1, Violations: 2, Updated: "2024-05-01", Version: "v0.1.0"},
+		{File: "cmd/b/depaware.txt", Deps: 9},
+	}, true)
+	want := `deps  unsafe  cgo  new violations  updated     depaware  file
+120   4       1    2               2024-05-01  v0.1.0    cmd/a/depaware.txt
+9     0       0    0               -           -         cmd/b/depaware.txt
+
+2 files, 2 new violations in 1 of them
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
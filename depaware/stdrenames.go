@@ -0,0 +1,182 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// stdRename is a standard library package (and the packages under it)
+// renamed or removed by a Go release.
+type stdRename struct {
+	Release string // the Go release that made the change, such as "go1.23"
+	Old     string
+	New     string // empty if Old was removed without a replacement
+}
+
+// defaultStdRenames are the known standard library renames that
+// -std-renames normalizes. Most are internal packages, which only
+// matter with -internal or -show, or moves of vendored packages.
+var defaultStdRenames = []stdRename{
+	{"go1.20", "vendor/golang.org/x/crypto/internal/subtle", "vendor/golang.org/x/crypto/internal/alias"},
+	{"go1.23", "runtime/internal/atomic", "internal/runtime/atomic"},
+	{"go1.23", "runtime/internal/syscall", "internal/runtime/syscall"},
+	{"go1.24", "runtime/internal/math", "internal/runtime/math"},
+	{"go1.24", "runtime/internal/sys", "internal/runtime/sys"},
+	{"go1.24", "crypto/internal/bigmod", "crypto/internal/fips140/bigmod"},
+	{"go1.24", "crypto/internal/edwards25519", "crypto/internal/fips140/edwards25519"},
+	{"go1.24", "crypto/internal/mlkem768", "crypto/internal/fips140/mlkem"},
+	{"go1.24", "crypto/internal/nistec", "crypto/internal/fips140/nistec"},
+}
+
+// loadStdRenames returns the renames for the -std-renames value flag:
+// none if it's empty, defaultStdRenames if it's "default", and
+// otherwise defaultStdRenames overridden by the renames in the named
+// file.
+func loadStdRenames(flag string) ([]stdRename, error) {
+	switch flag {
+	case "":
+		return nil, nil
+	case "default":
+		return sortRenames(defaultStdRenames), nil
+	}
+	f, err := os.Open(flag)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	extra, err := parseStdRenames(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", flag, err)
+	}
+	return sortRenames(mergeRenames(defaultStdRenames, extra)), nil
+}
+
+// parseStdRenames parses a -std-renames file. Each line is a Go release
+// and the old and new package paths, such as
+//
+//	go1.23 runtime/internal/atomic internal/runtime/atomic
+//
+// where a new path of "-" means the old package was removed. Blank lines
+// and lines starting with "#" are ignored.
+func parseStdRenames(r io.Reader) ([]stdRename, error) {
+	var renames []stdRename
+	scan := bufio.NewScanner(r)
+	for lineNum := 1; scan.Scan(); lineNum++ {
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) != 3 || !semver.IsValid(releaseSemver(f[0])) {
+			return nil, fmt.Errorf("line %d: want \"goX.Y old new\", got %q", lineNum, line)
+		}
+		if f[2] == "-" {
+			f[2] = ""
+		}
+		renames = append(renames, stdRename{f[0], f[1], f[2]})
+	}
+	return renames, scan.Err()
+}
+
+// mergeRenames returns base with the renames of the same packages in
+// override replaced, and the others in override added.
+func mergeRenames(base, override []stdRename) []stdRename {
+	replaced := make(map[string]bool)
+	for _, r := range override {
+		replaced[r.Old] = true
+	}
+	var merged []stdRename
+	for _, r := range base {
+		if !replaced[r.Old] {
+			merged = append(merged, r)
+		}
+	}
+	return append(merged, override...)
+}
+
+// sortRenames returns a copy of renames in the order of their Go
+// releases, so that a package renamed twice is renamed in turn.
+func sortRenames(renames []stdRename) []stdRename {
+	sorted := append([]stdRename(nil), renames...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return semver.Compare(releaseSemver(sorted[i].Release), releaseSemver(sorted[j].Release)) < 0
+	})
+	return sorted
+}
+
+// releaseSemver returns the semantic version for the Go release r,
+// such as "v1.23" for "go1.23".
+func releaseSemver(r string) string {
+	return "v" + strings.TrimPrefix(r, "go")
+}
+
+// renameStd returns the latest name of the standard library package
+// pkg after renames, sorted by sortRenames, or the empty string if it
+// was removed.
+func renameStd(pkg string, renames []stdRename) string {
+	for _, r := range renames {
+		switch {
+		case pkg == r.Old:
+			pkg = r.New
+		case strings.HasPrefix(pkg, r.Old+"/") && r.New == "":
+			pkg = ""
+		case strings.HasPrefix(pkg, r.Old+"/"):
+			pkg = r.New + pkg[len(r.Old):]
+		}
+		if pkg == "" {
+			return ""
+		}
+	}
+	return pkg
+}
+
+// sameUpToRenames reports whether the depaware.txt contents old list the
+// same dependencies as f, once standard library packages in both are
+// renamed by renames. That's the case when old was generated by an
+// older Go release and nothing changed but the standard library's
+// internal layout. Lines of old may be up to maxLine bytes long.
+func sameUpToRenames(old []byte, f *depsFile, renames []stdRename, maxLine int) bool {
+	of, err := parseDepsFile(bytes.NewReader(old), maxLine)
+	if err != nil || of.Pkg != f.Pkg || !reflect.DeepEqual(of.Directives, f.Directives) {
+		return false
+	}
+	return reflect.DeepEqual(renamedEntries(of.Entries, renames), renamedEntries(f.Entries, renames))
+}
+
+// renamedEntries returns entries with renames applied, in a canonical
+// order, without the details that renames can change: whether another
+// package imports each one, and the number of packages in std.
+func renamedEntries(entries []fileEntry, renames []stdRename) []fileEntry {
+	var out []fileEntry
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if e.Pkg = renameStd(e.Pkg, renames); e.Pkg == "" || seen[e.Pkg] {
+			continue
+		}
+		seen[e.Pkg] = true
+		if e.Why != "" {
+			if why := renameStd(e.Why, renames); why != "" {
+				e.Why = why
+			}
+		}
+		e.More = false
+		if e.Pkg == stdModule {
+			e.Count = 0
+		}
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return depLess(out[i].Pkg, out[j].Pkg) })
+	return out
+}
@@ -0,0 +1,69 @@
+package depaware
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRenameStd(t *testing.T) {
+	renames := sortRenames([]stdRename{
+		{"go1.24", "internal/b", "internal/c"},
+		{"go1.23", "internal/a", "internal/b"},
+		{"go1.23", "internal/gone", ""},
+	})
+	for pkg, want := range map[string]string{
+		"internal/a":        "internal/c",
+		"internal/a/sub":    "internal/c/sub",
+		"internal/ab":       "internal/ab",
+		"internal/gone":     "",
+		"internal/gone/sub": "",
+		"fmt":               "fmt",
+	} {
+		if got := renameStd(pkg, renames); got != want {
+			t.Errorf("renameStd(%q) = %q; want %q", pkg, got, want)
+		}
+	}
+}
+
+func TestParseStdRenames(t *testing.T) {
+	got, err := parseStdRenames(strings.NewReader("# comment\n\ngo1.25 internal/x internal/y\ngo1.25 internal/z -\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []stdRename{{"go1.25", "internal/x", "internal/y"}, {"go1.25", "internal/z", ""}}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got %v; want %v", got, want)
+	}
+	if _, err := parseStdRenames(strings.NewReader("internal/x internal/y\n")); err == nil {
+		t.Error("accepted a line without a release")
+	}
+}
+
+func TestSameUpToRenames(t *testing.T) {
+	gen := func(entries ...fileEntry) []byte {
+		var buf bytes.Buffer
+		writeDepsFile(&buf, &depsFile{Pkg: "example.com/cmd", Entries: entries})
+		return buf.Bytes()
+	}
+	old := gen(
+		fileEntry{Pkg: "fmt", Why: "example.com/cmd"},
+		fileEntry{Pkg: "runtime/internal/atomic", Why: "sync", More: true},
+		fileEntry{Pkg: "sync", Why: "fmt"},
+	)
+	cur := &depsFile{Pkg: "example.com/cmd", Entries: []fileEntry{
+		{Pkg: "fmt", Why: "example.com/cmd"},
+		{Pkg: "internal/runtime/atomic", Why: "sync"},
+		{Pkg: "sync", Why: "fmt"},
+	}}
+	if !sameUpToRenames(old, cur, defaultStdRenames, defaultMaxLineBytes) {
+		t.Error("files differing by a rename aren't the same")
+	}
+	if sameUpToRenames(old, cur, nil, defaultMaxLineBytes) {
+		t.Error("files differing by a rename are the same without renames")
+	}
+	cur.Entries = append(cur.Entries, fileEntry{Pkg: "os", Why: "fmt"})
+	if sameUpToRenames(old, cur, defaultStdRenames, defaultMaxLineBytes) {
+		t.Error("files with a new dependency are the same")
+	}
+}
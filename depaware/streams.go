@@ -0,0 +1,108 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// openOutput opens the destination name of -diff-output or -explain:
+// "stderr", "stdout" (or "-"), "none", or the name of a file to create.
+// It returns a nil writer for stderr, and a function to call when done
+// writing.
+func (r *runner) openOutput(name string) (io.Writer, func() error, error) {
+	nop := func() error { return nil }
+	switch name {
+	case "", "stderr":
+		return nil, nop, nil
+	case "stdout", "-":
+		return r.stdout, nop, nil
+	case "none":
+		return ioutil.Discard, nop, nil
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// reportOutOfDate reports that daFile, whose checked-in contents are
+// old, is out of date: with the diff from old to cur (the generated
+// contents, listing entries) on stderr, or with a summary on stderr and
+// the diff, labeled with daFile, in diffOut. diffText makes the diff
+// with the given labels.
+func (r *runner) reportOutOfDate(daFile string, old []byte, entries []fileEntry, diffText func(from, to string) (string, error)) error {
+	if r.diffOut == nil {
+		text, err := diffText("before", "after")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(r.stderr, "The list of dependencies in %s is out of date.\n\n", daFile)
+		_, err = io.WriteString(r.stderr, text)
+		return err
+	}
+	text, err := diffText(daFile, daFile)
+	if err != nil {
+		return err
+	}
+	added, removed := depChanges(old, entries)
+	msg := fmt.Sprintf("The list of dependencies in %s is out of date: %d added, %d removed", daFile, len(added), len(removed))
+	switch r.DiffOutput {
+	case "none":
+	case "stdout", "-":
+		msg += "; diff on stdout"
+	default:
+		msg += "; diff in " + r.DiffOutput
+	}
+	fmt.Fprintln(r.stderr, msg+".")
+	_, err = io.WriteString(r.diffOut, text)
+	return err
+}
+
+// depChanges returns the packages (or modules) of entries that aren't
+// listed in the depaware.txt contents old, and those listed in old that
+// aren't in entries.
+func depChanges(old []byte, entries []fileEntry) (added, removed []string) {
+	oldDeps := entryPkgs(old)
+	cur := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		cur[e.Pkg] = true
+		if !oldDeps[e.Pkg] {
+			added = append(added, e.Pkg)
+		}
+	}
+	for _, e := range parseEntriesLoosely(old) {
+		if !cur[e.Pkg] {
+			removed = append(removed, e.Pkg)
+		}
+	}
+	return added, removed
+}
+
+// sameDeps reports whether the depaware.txt contents old list the
+// same dependencies as entries, whatever their format.
+func sameDeps(old []byte, entries []fileEntry) bool {
+	added, removed := depChanges(old, entries)
+	return len(added) == 0 && len(removed) == 0
+}
+
+// stdoutHeader returns the header for the contents of daFile on
+// stdout, in the style of head(1) and tail(1), such as
+// "==> cmd/foo/depaware.txt <==". The name is relative to the current
+// directory if possible, and slash-separated on all systems.
+func stdoutHeader(daFile string) string {
+	name := daFile
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, daFile); err == nil {
+			name = rel
+		}
+	}
+	return fmt.Sprintf("==> %s <==\n", filepath.ToSlash(name))
+}
@@ -0,0 +1,65 @@
+package depaware
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDepChanges(t *testing.T) {
+	old := []byte("example.com/cmd dependencies: (generated by github.com/tailscale/depaware)\n\n" +
+		"        bytes          from example.com/cmd\n" +
+		"        errors         from bytes\n")
+	entries := []fileEntry{{Pkg: "bytes"}, {Pkg: "encoding/json"}}
+	added, removed := depChanges(old, entries)
+	if !reflect.DeepEqual(added, []string{"encoding/json"}) || !reflect.DeepEqual(removed, []string{"errors"}) {
+		t.Errorf("got added %q, removed %q; want [encoding/json], [errors]", added, removed)
+	}
+}
+
+func TestSameDeps(t *testing.T) {
+	// Narrower columns, and importers chosen differently.
+	old := []byte("example.com/cmd dependencies: (generated by github.com/tailscale/depaware)\n\n" +
+		"        bytes  from example.com/cmd\n" +
+		"        errors from example.com/cmd\n")
+	if !sameDeps(old, []fileEntry{{Pkg: "errors", Why: "bytes"}, {Pkg: "bytes", Why: "example.com/cmd"}}) {
+		t.Error("same dependencies in another format aren't the same")
+	}
+	if sameDeps(old, []fileEntry{{Pkg: "bytes"}}) {
+		t.Error("a removed dependency isn't a difference")
+	}
+}
+
+func TestReportOutOfDate(t *testing.T) {
+	var out, stderr bytes.Buffer
+	r := &runner{Options: Options{DiffOutput: "deps.patch"}, diffOut: &out, stderr: &stderr}
+	var labels []string
+	err := r.reportOutOfDate("/src/depaware.txt", nil, nil, func(from, to string) (string, error) {
+		labels = append(labels, from, to)
+		return "the diff\n", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "the diff\n" {
+		t.Errorf("diff output = %q; want the diff", out.String())
+	}
+	if want := "The list of dependencies in /src/depaware.txt is out of date: 0 added, 0 removed; diff in deps.patch.\n"; stderr.String() != want {
+		t.Errorf("stderr = %q; want %q", stderr.String(), want)
+	}
+	if want := []string{"/src/depaware.txt", "/src/depaware.txt"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("diff labels = %q; want %q", labels, want)
+	}
+}
+
+func TestStdoutHeader(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdoutHeader(filepath.Join(wd, "cmd", "foo", "depaware.txt")), "==> cmd/foo/depaware.txt <==\n"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
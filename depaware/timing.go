@@ -0,0 +1,61 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// A timing is how long a phase of processing a package took, recorded
+// with -v or -stats-file.
+type timing struct {
+	Pkg   string
+	GOOS  string // for the "load" phase; empty otherwise
+	Phase string // "load", "merge" or "write" (formatting and writing the file)
+	Dur   time.Duration
+}
+
+// recordTiming records that phase of pkg (for goos, if non-empty)
+// started at start and just ended. It does nothing without -v or
+// -stats-file.
+func (r *runner) recordTiming(pkg, goos, phase string, start time.Time) {
+	if !r.Verbose && r.StatsFile == "" {
+		return
+	}
+	d := time.Since(start)
+	r.timingsMu.Lock()
+	defer r.timingsMu.Unlock()
+	r.timings = append(r.timings, timing{Pkg: pkg, GOOS: goos, Phase: phase, Dur: d})
+}
+
+// writeSlowest writes the n slowest of ts to w, slowest first, along
+// with the total time per phase.
+func writeSlowest(w io.Writer, ts []timing, n int) {
+	ts = append([]timing(nil), ts...)
+	sort.SliceStable(ts, func(i, j int) bool { return ts[i].Dur > ts[j].Dur })
+	total := map[string]time.Duration{}
+	for _, t := range ts {
+		total[t.Phase] += t.Dur
+	}
+	fmt.Fprintf(w, "time: load %v, merge %v, write %v\n",
+		total["load"].Round(time.Millisecond), total["merge"].Round(time.Millisecond), total["write"].Round(time.Millisecond))
+	if n > len(ts) {
+		n = len(ts)
+	}
+	if n <= 0 {
+		return
+	}
+	fmt.Fprintf(w, "slowest:\n")
+	for _, t := range ts[:n] {
+		phase := t.Phase
+		if t.GOOS != "" {
+			phase += " GOOS=" + t.GOOS
+		}
+		fmt.Fprintf(w, "\t%8v  %s (%s)\n", t.Dur.Round(time.Millisecond), t.Pkg, phase)
+	}
+}
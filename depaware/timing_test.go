@@ -0,0 +1,27 @@
+package depaware
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriteSlowest(t *testing.T) {
+	ts := []timing{
+		{Pkg: "example.com/a", GOOS: "linux", Phase: "load", Dur: 300 * time.Millisecond},
+		{Pkg: "example.com/a", GOOS: "windows", Phase: "load", Dur: 900 * time.Millisecond},
+		{Pkg: "example.com/a", Phase: "merge", Dur: 20 * time.Millisecond},
+		{Pkg: "example.com/a", Phase: "write", Dur: 5 * time.Millisecond},
+		{Pkg: "example.com/b", GOOS: "linux", Phase: "load", Dur: 400 * time.Millisecond},
+	}
+	var buf bytes.Buffer
+	writeSlowest(&buf, ts, 2)
+	want := `time: load 1.6s, merge 20ms, write 5ms
+slowest:
+	   900ms  example.com/a (load GOOS=windows)
+	   400ms  example.com/b (load GOOS=linux)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
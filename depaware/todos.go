@@ -0,0 +1,123 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dateFormat is the format of dates in annotations.
+const dateFormat = "2006-01-02"
+
+// parseComments returns the comments of the entries in an existing
+// depaware.txt file, keyed by package. Like parsePreferredWhy, it's
+// best effort only: lines it doesn't understand are skipped.
+func parseComments(r io.Reader, maxLine int) (map[string]string, error) {
+	m := make(map[string]string)
+	scan := lineScanner(r, maxLine)
+	lineNum := 0
+	for ; scan.Scan(); lineNum++ {
+		e, err := parseFileEntry(scan.Text())
+		if err == nil && e.Comment != "" {
+			m[e.Pkg] = e.Comment
+		}
+	}
+	return m, scanErr(scan, lineNum, maxLine)
+}
+
+// annotationValue returns the value of the first "key:value" word in
+// comment, or the empty string if there isn't one. For instance, the
+// "remove-by" value of "temporary; remove-by:2025-06-30" is "2025-06-30".
+func annotationValue(comment, key string) string {
+	for _, w := range strings.Fields(comment) {
+		if strings.HasPrefix(w, key+":") {
+			return strings.TrimPrefix(w, key+":")
+		}
+	}
+	return ""
+}
+
+// removeBy returns the date in e's "remove-by" annotation, if it has a
+// valid one.
+func removeBy(e fileEntry) (t time.Time, ok bool) {
+	v := annotationValue(e.Comment, "remove-by")
+	if v == "" {
+		return t, false
+	}
+	t, err := time.Parse(dateFormat, v)
+	return t, err == nil
+}
+
+// overdueEntries returns the entries whose remove-by date is before the
+// day of now. Dependencies may stay through their remove-by date.
+func overdueEntries(entries []fileEntry, now time.Time) []fileEntry {
+	today := dateOf(now)
+	var overdue []fileEntry
+	for _, e := range entries {
+		if t, ok := removeBy(e); ok && t.Before(today) {
+			overdue = append(overdue, e)
+		}
+	}
+	return overdue
+}
+
+// dateOf returns midnight UTC of t's date, for comparisons with
+// dates parsed from annotations.
+func dateOf(t time.Time) time.Time {
+	d, _ := time.Parse(dateFormat, t.Format(dateFormat))
+	return d
+}
+
+// runTodos implements "depaware todos", which lists the dependencies
+// annotated with a "remove-by:YYYY-MM-DD" comment in the depaware.txt
+// files committed to the current git repo, soonest first.
+func (r *runner) runTodos(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: depaware todos")
+	}
+	files, err := r.trackedDepsFiles()
+	if err != nil {
+		return err
+	}
+	type todo struct {
+		file string
+		e    fileEntry
+		date time.Time
+	}
+	var todos []todo
+	for _, name := range files {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		f, err := parseDepsFile(bytes.NewReader(data), r.MaxLineBytes)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		for _, e := range f.Entries {
+			if t, ok := removeBy(e); ok {
+				todos = append(todos, todo{name, e, t})
+			}
+		}
+	}
+	sort.SliceStable(todos, func(i, j int) bool {
+		return todos[i].date.Before(todos[j].date)
+	})
+	today := dateOf(time.Now())
+	for _, t := range todos {
+		status := ""
+		if t.date.Before(today) {
+			status = " (overdue)"
+		}
+		fmt.Fprintf(r.stdout, "%s  %s: %s%s\n", t.date.Format(dateFormat), t.file, t.e.Pkg, status)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package depaware
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommentsRoundTrip(t *testing.T) {
+	in := `example.com/cmd dependencies: (generated by github.com/tailscale/depaware)
+
+        github.com/a/b                                               from example.com/cmd # remove-by:2025-06-30
+        github.com/a/c                                               from github.com/a/b+
+        github.com/a/d                                                # temporary, see issue 12
+`
+	f, err := parseDepsFile(strings.NewReader(in), defaultMaxLineBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.Entries[0].Comment; got != "remove-by:2025-06-30" {
+		t.Errorf("comment = %q", got)
+	}
+	if got := f.Entries[2].Comment; got != "temporary, see issue 12" {
+		t.Errorf("comment = %q", got)
+	}
+	var buf bytes.Buffer
+	writeDepsFile(&buf, f)
+	if buf.String() != in {
+		t.Errorf("round trip:\n%s\nwant:\n%s", buf.String(), in)
+	}
+
+	comments, err := parseComments(strings.NewReader(in), defaultMaxLineBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 2 || comments["github.com/a/b"] != "remove-by:2025-06-30" {
+		t.Errorf("parseComments = %v", comments)
+	}
+}
+
+func TestOverdueEntries(t *testing.T) {
+	entries := []fileEntry{
+		{Pkg: "past", Comment: "remove-by:2025-06-29"},
+		{Pkg: "today", Comment: "remove-by:2025-06-30"},
+		{Pkg: "future", Comment: "x remove-by:2025-07-01 y"},
+		{Pkg: "bogus", Comment: "remove-by:soon"},
+		{Pkg: "none"},
+	}
+	now := time.Date(2025, 6, 30, 23, 0, 0, 0, time.UTC)
+	got := overdueEntries(entries, now)
+	if len(got) != 1 || got[0].Pkg != "past" {
+		t.Errorf("overdueEntries = %v; want just past", got)
+	}
+}
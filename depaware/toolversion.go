@@ -0,0 +1,54 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// versionDirective is the depaware.txt directive that records the
+// version of depaware that generated the file, with -record-version.
+const versionDirective = "depaware-version"
+
+// withinCompat reports whether -check -compat=n accepts a file in the
+// format of depaware version recorded when this is version running:
+// whether recorded is older than running, with the same major version
+// and at most n minor versions behind. Development builds have no
+// version to compare.
+func withinCompat(recorded, running string, n int) bool {
+	if n <= 0 || !semver.IsValid(recorded) || !semver.IsValid(running) || semver.Compare(recorded, running) >= 0 {
+		return false
+	}
+	if semver.Major(recorded) != semver.Major(running) {
+		return false
+	}
+	return minorVersion(running)-minorVersion(recorded) <= n
+}
+
+// minorVersion returns the minor version of the valid semantic version
+// v, such as 3 for v1.3.0.
+func minorVersion(v string) int {
+	mm := strings.TrimPrefix(semver.MajorMinor(v), semver.Major(v)+".")
+	n, _ := strconv.Atoi(mm)
+	return n
+}
+
+// versionMismatch returns a warning if a file recorded as generated by
+// depaware version recorded is being generated or checked by version
+// running, or the empty string if they match or nothing is recorded.
+func versionMismatch(recorded, running string) string {
+	if recorded == "" || recorded == running {
+		return ""
+	}
+	msg := fmt.Sprintf("was generated by depaware %s, but this is depaware %s", recorded, running)
+	if semver.IsValid(recorded) && (!semver.IsValid(running) || semver.Compare(running, recorded) < 0) {
+		msg += "; is a stray, older depaware installed instead of the version pinned in go.mod? Try 'go run github.com/tailscale/depaware'"
+	}
+	return msg
+}
@@ -0,0 +1,51 @@
+package depaware
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVersionMismatch(t *testing.T) {
+	tests := []struct {
+		recorded, running string
+		want              string // substring of the warning, or empty for none
+	}{
+		{"", "v0.1.0", ""},
+		{"v0.1.0", "v0.1.0", ""},
+		{"v0.2.0", "v0.1.0", "is a stray, older depaware installed"},
+		{"v0.2.0", "(devel)", "is a stray, older depaware installed"},
+		{"v0.1.0", "v0.2.0", "was generated by depaware v0.1.0, but this is depaware v0.2.0"},
+	}
+	for _, tt := range tests {
+		got := versionMismatch(tt.recorded, tt.running)
+		if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+			t.Errorf("versionMismatch(%q, %q) = %q; want %q", tt.recorded, tt.running, got, tt.want)
+		}
+		if tt.recorded == "v0.1.0" && tt.running == "v0.2.0" && strings.Contains(got, "stray") {
+			t.Errorf("versionMismatch(%q, %q) blames an older depaware", tt.recorded, tt.running)
+		}
+	}
+}
+
+func TestWithinCompat(t *testing.T) {
+	tests := []struct {
+		recorded, running string
+		n                 int
+		want              bool
+	}{
+		{"v0.3.0", "v0.5.1", 2, true},
+		{"v0.3.0", "v0.5.1", 1, false},
+		{"v0.5.0", "v0.5.1", 1, true},
+		{"v0.5.1", "v0.5.1", 1, false}, // not older
+		{"v0.6.0", "v0.5.1", 1, false}, // newer
+		{"v0.9.0", "v1.0.0", 5, false}, // different major version
+		{"v0.3.0", "v0.4.0", 0, false},
+		{"v0.3.0", "(devel)", 1, false},
+		{"", "v0.4.0", 1, false},
+	}
+	for _, tt := range tests {
+		if got := withinCompat(tt.recorded, tt.running, tt.n); got != tt.want {
+			t.Errorf("withinCompat(%q, %q, %d) = %v; want %v", tt.recorded, tt.running, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Treemap dimensions, in pixels.
+const (
+	treemapWidth  = 1200
+	treemapHeight = 800
+)
+
+// treemapColors are the fill colors of the treemap groups, in order of
+// decreasing group size. They repeat if there are more groups.
+var treemapColors = []string{
+	"#8dd3c7", "#ffffb3", "#bebada", "#fb8072", "#80b1d3", "#fdb462",
+	"#b3de69", "#fccde5", "#d9d9d9", "#bc80bd", "#ccebc5", "#ffed6f",
+}
+
+// rect is a rectangle in treemap coordinates.
+type rect struct {
+	X, Y, W, H float64
+}
+
+// treemapItem is a weighted, labeled cell of a treemap.
+type treemapItem struct {
+	Label  string
+	Weight float64
+}
+
+// treemapGroup is a set of cells that are laid out together.
+type treemapGroup struct {
+	Label  string
+	Weight float64
+	Items  []treemapItem
+}
+
+// writeTreemap writes a standalone HTML page to w containing an SVG
+// treemap of the dependencies of pkg. Each dependency is sized by the
+// number of d.Deps it transitively pulls in (including itself), so that
+// packages hidden from the listing don't count, or if sizes is non-nil,
+// for -sizes, by the bytes it contributes to the binary, as returned by
+// binarySymbols; dependencies without any aren't shown then.
+// Dependencies are grouped by owner as returned by depOwner. If
+// fileGeese is non-nil, for -deep, the page also lists the files each
+// dependency is compiled from on those GOOS values.
+func writeTreemap(w io.Writer, pkg string, d *deps, fileGeese []string, sizes map[string]symbolStat) {
+	visible := make(map[string]bool)
+	for _, dep := range d.Deps {
+		visible[dep] = true
+	}
+	unit, desc := "packages", "the number of listed packages each transitively imports"
+	weight := func(dep string) float64 {
+		n := 0
+		for p := range d.reachable(dep) {
+			if visible[p] {
+				n++
+			}
+		}
+		return float64(n)
+	}
+	if sizes != nil {
+		unit, desc = "bytes", "the bytes each contributes to the binary"
+		weight = func(dep string) float64 { return float64(sizes[dep].Size) }
+	}
+
+	byOwner := map[string]*treemapGroup{}
+	var groups []*treemapGroup
+	shown := 0
+	for _, dep := range d.Deps {
+		n := weight(dep)
+		if n == 0 {
+			continue
+		}
+		shown++
+		owner := depOwner(dep)
+		g, ok := byOwner[owner]
+		if !ok {
+			g = &treemapGroup{Label: owner}
+			byOwner[owner] = g
+			groups = append(groups, g)
+		}
+		g.Items = append(g.Items, treemapItem{Label: dep, Weight: n})
+		g.Weight += n
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Weight != groups[j].Weight {
+			return groups[i].Weight > groups[j].Weight
+		}
+		return groups[i].Label < groups[j].Label
+	})
+	groupWeights := make([]float64, len(groups))
+	for i, g := range groups {
+		sort.SliceStable(g.Items, func(i, j int) bool {
+			if g.Items[i].Weight != g.Items[j].Weight {
+				return g.Items[i].Weight > g.Items[j].Weight
+			}
+			return g.Items[i].Label < g.Items[j].Label
+		})
+		groupWeights[i] = g.Weight
+	}
+
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>%s dependencies</title></head><body>\n", html.EscapeString(pkg))
+	fmt.Fprintf(w, "<h1>%s dependencies</h1>\n", html.EscapeString(pkg))
+	fmt.Fprintf(w, "<p>%d packages, sized by %s.</p>\n", shown, desc)
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"sans-serif\" font-size=\"10\">\n", treemapWidth, treemapHeight)
+	groupRects := layoutTreemap(groupWeights, rect{0, 0, treemapWidth, treemapHeight})
+	for i, g := range groups {
+		gr := groupRects[i]
+		color := treemapColors[i%len(treemapColors)]
+		fmt.Fprintf(w, "<g><title>%s (%d packages)</title>\n", html.EscapeString(g.Label), len(g.Items))
+		weights := make([]float64, len(g.Items))
+		for j, it := range g.Items {
+			weights[j] = it.Weight
+		}
+		for j, r := range layoutTreemap(weights, gr) {
+			it := g.Items[j]
+			fmt.Fprintf(w, "<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=\"%s\" stroke=\"#fff\"><title>%s: %d %s</title></rect>\n",
+				r.X, r.Y, r.W, r.H, color, html.EscapeString(it.Label), int64(it.Weight), unit)
+		}
+		fmt.Fprintf(w, "<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=\"none\" stroke=\"#333\"/>\n", gr.X, gr.Y, gr.W, gr.H)
+		// Only label groups that have room for it; the tooltips cover the rest.
+		if gr.W > 60 && gr.H > 14 {
+			fmt.Fprintf(w, "<text x=\"%.1f\" y=\"%.1f\">%s</text>\n", gr.X+3, gr.Y+12, html.EscapeString(g.Label))
+		}
+		fmt.Fprintf(w, "</g>\n")
+	}
+	fmt.Fprintf(w, "</svg>\n")
+	if fileGeese != nil {
+		writeFileLists(w, d, fileGeese)
+	}
+	fmt.Fprintf(w, "</body></html>\n")
+}
+
+// writeFileLists writes HTML listing the files each of d.Deps is
+// compiled from on each of geese.
+func writeFileLists(w io.Writer, d *deps, geese []string) {
+	fmt.Fprintf(w, "<h2>Files</h2>\n")
+	for _, dep := range d.Deps {
+		fmt.Fprintf(w, "<details><summary>%s</summary>\n", html.EscapeString(dep))
+		for _, goos := range geese {
+			files := d.CompiledFiles[pkgGOOS{dep, goos}]
+			if len(files) == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "<p>%s:</p>\n<ul>\n", html.EscapeString(goos))
+			for _, f := range files {
+				fmt.Fprintf(w, "<li><code>%s</code></li>\n", html.EscapeString(f))
+			}
+			fmt.Fprintf(w, "</ul>\n")
+		}
+		fmt.Fprintf(w, "</details>\n")
+	}
+}
+
+// TransitiveCount returns the number of distinct packages reachable
+// from pkg through imports, including pkg itself.
+func (d *deps) TransitiveCount(pkg string) int {
+	return len(d.reachable(pkg))
+}
+
+// reachable returns the set of packages reachable from pkg through
+// imports, including pkg itself.
+func (d *deps) reachable(pkg string) map[string]bool {
+	seen := map[string]bool{pkg: true}
+	queue := []string{pkg}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, imp := range d.Imports[p] {
+			if !seen[imp] {
+				seen[imp] = true
+				queue = append(queue, imp)
+			}
+		}
+	}
+	return seen
+}
+
+// depOwner returns the owner of a package for grouping purposes:
+// "std" for the standard library, the host and user or organization
+// for code hosting sites (such as "github.com/tailscale"), and the
+// host otherwise (such as "golang.org/x" or "k8s.io").
+func depOwner(pkg string) string {
+	elems := strings.Split(pkg, "/")
+	if !strings.Contains(elems[0], ".") {
+		return "std"
+	}
+	if len(elems) > 1 && (codeHosts[elems[0]] || elems[0] == "golang.org" && elems[1] == "x") {
+		return elems[0] + "/" + elems[1]
+	}
+	return elems[0]
+}
+
+// layoutTreemap divides r into one rectangle per weight, with areas
+// proportional to the weights, using the squarified treemap algorithm
+// of Bruls, Huizing and van Wijk. The weights should be sorted in
+// decreasing order for best results.
+func layoutTreemap(weights []float64, r rect) []rect {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	rects := make([]rect, 0, len(weights))
+	if total <= 0 {
+		for range weights {
+			rects = append(rects, rect{X: r.X, Y: r.Y})
+		}
+		return rects
+	}
+	scale := r.W * r.H / total
+	areas := make([]float64, len(weights))
+	for i, w := range weights {
+		areas[i] = w * scale
+	}
+
+	var row []float64
+	for len(areas) > 0 {
+		a := areas[0]
+		side := r.W
+		if r.H < side {
+			side = r.H
+		}
+		if len(row) == 0 || worstRatio(append(row, a), side) <= worstRatio(row, side) {
+			row = append(row, a)
+			areas = areas[1:]
+			continue
+		}
+		rects, r = layoutRow(rects, row, r)
+		row = nil
+	}
+	if len(row) > 0 {
+		rects, _ = layoutRow(rects, row, r)
+	}
+	return rects
+}
+
+// worstRatio returns the worst aspect ratio of the areas in row when
+// laid out along a side of the given length.
+func worstRatio(row []float64, side float64) float64 {
+	var sum, min, max float64
+	for i, a := range row {
+		sum += a
+		if i == 0 || a < min {
+			min = a
+		}
+		if a > max {
+			max = a
+		}
+	}
+	if sum == 0 || min == 0 {
+		return 1e300
+	}
+	s2, sum2 := side*side, sum*sum
+	r1, r2 := s2*max/sum2, sum2/(s2*min)
+	if r1 > r2 {
+		return r1
+	}
+	return r2
+}
+
+// layoutRow lays out the areas in row along the shorter side of r,
+// appending their rectangles to rects. It returns the new rects and the
+// remaining free part of r.
+func layoutRow(rects []rect, row []float64, r rect) ([]rect, rect) {
+	var sum float64
+	for _, a := range row {
+		sum += a
+	}
+	if r.W >= r.H {
+		// Column on the left.
+		w := sum / r.H
+		y := r.Y
+		for _, a := range row {
+			h := a / w
+			rects = append(rects, rect{r.X, y, w, h})
+			y += h
+		}
+		r.X += w
+		r.W -= w
+	} else {
+		// Row on the top.
+		h := sum / r.W
+		x := r.X
+		for _, a := range row {
+			w := a / h
+			rects = append(rects, rect{x, r.Y, w, h})
+			x += w
+		}
+		r.Y += h
+		r.H -= h
+	}
+	return rects, r
+}
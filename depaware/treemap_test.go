@@ -0,0 +1,95 @@
+package depaware
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLayoutTreemap(t *testing.T) {
+	weights := []float64{6, 6, 4, 3, 2, 2, 1}
+	bounds := rect{0, 0, 6, 4}
+	rects := layoutTreemap(weights, bounds)
+	if len(rects) != len(weights) {
+		t.Fatalf("got %d rects; want %d", len(rects), len(weights))
+	}
+	for i, r := range rects {
+		if area := r.W * r.H; math.Abs(area-weights[i]) > 1e-9 {
+			t.Errorf("rect %d has area %v; want %v", i, area, weights[i])
+		}
+		if r.X < 0 || r.Y < 0 || r.X+r.W > bounds.W+1e-9 || r.Y+r.H > bounds.H+1e-9 {
+			t.Errorf("rect %d = %+v is out of bounds", i, r)
+		}
+	}
+}
+
+func TestDepOwner(t *testing.T) {
+	tests := map[string]string{
+		"bytes":                          "std",
+		"net/http":                       "std",
+		"golang.org/x/sys/unix":          "golang.org/x",
+		"github.com/tailscale/depaware":  "github.com/tailscale",
+		"google.golang.org/protobuf/pkg": "google.golang.org",
+		"k8s.io/client-go/kubernetes":    "k8s.io",
+	}
+	for pkg, want := range tests {
+		if got := depOwner(pkg); got != want {
+			t.Errorf("depOwner(%q) = %q; want %q", pkg, got, want)
+		}
+	}
+}
+
+func TestTransitiveCount(t *testing.T) {
+	var d deps
+	d.AddEdge("a", "b")
+	d.AddEdge("a", "c")
+	d.AddEdge("b", "c")
+	d.AddEdge("c", "d")
+	for pkg, want := range map[string]int{"a": 4, "b": 3, "c": 2, "d": 1} {
+		if got := d.TransitiveCount(pkg); got != want {
+			t.Errorf("TransitiveCount(%q) = %d; want %d", pkg, got, want)
+		}
+	}
+}
+
+func TestWriteTreemapFiles(t *testing.T) {
+	d := new(deps)
+	d.AddEdge("example.com/cmd", "github.com/foo/bar")
+	d.AddDep("github.com/foo/bar", "linux")
+	d.AddCompiledFiles("github.com/foo/bar", "linux", []string{"/mod/bar/bar.go"})
+
+	var buf bytes.Buffer
+	writeTreemap(&buf, "example.com/cmd", d, nil, nil)
+	if strings.Contains(buf.String(), "bar.go") {
+		t.Errorf("file list without -deep:\n%s", buf.String())
+	}
+	buf.Reset()
+	writeTreemap(&buf, "example.com/cmd", d, []string{"linux"}, nil)
+	if !strings.Contains(buf.String(), "<li><code>/mod/bar/bar.go</code></li>") || !strings.HasSuffix(buf.String(), "</body></html>\n") {
+		t.Errorf("missing file list:\n%s", buf.String())
+	}
+}
+
+func TestWriteTreemapWeights(t *testing.T) {
+	d := new(deps)
+	d.AddEdge("example.com/cmd", "github.com/foo/bar")
+	d.AddEdge("github.com/foo/bar", "github.com/foo/bar/internal/x")
+	d.AddEdge("github.com/foo/bar", "fmt")
+	for _, pkg := range []string{"github.com/foo/bar", "github.com/foo/bar/internal/x", "fmt"} {
+		d.AddDep(pkg, "linux")
+	}
+	d.hideDeps(newVisibility("github.com/foo/bar/internal/...", "", nil))
+
+	var buf bytes.Buffer
+	writeTreemap(&buf, "example.com/cmd", d, nil, nil)
+	if !strings.Contains(buf.String(), "<title>github.com/foo/bar: 2 packages</title>") {
+		t.Errorf("hidden package counted:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	writeTreemap(&buf, "example.com/cmd", d, nil, map[string]symbolStat{"github.com/foo/bar": {Size: 1234}})
+	if got := buf.String(); !strings.Contains(got, "<title>github.com/foo/bar: 1234 bytes</title>") || strings.Contains(got, "<title>fmt:") || !strings.Contains(got, "1 packages, sized by the bytes") {
+		t.Errorf("treemap by bytes:\n%s", got)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// vexReport is the -format=vex output: the dependencies of a package,
+// grouped by module, in a form meant to feed VEX (Vulnerability
+// Exploitability eXchange) statements. When an advisory names a
+// package of a module, it tells whether that package is linked into
+// the binary at all, and on which platforms.
+type vexReport struct {
+	Product    string         `json:"product"` // the package analyzed
+	GOOS       []string       `json:"goos"`
+	Components []vexComponent `json:"components"`
+	Provenance *provenance    `json:"provenance,omitempty"`
+}
+
+// vexComponent is a module the product depends on.
+type vexComponent struct {
+	Module   string       `json:"module"`            // module path, or "std"
+	Version  string       `json:"version,omitempty"` // empty for std
+	PURL     string       `json:"purl"`              // package URL, such as "pkg:golang/github.com/foo/bar@v1.2.3"
+	Packages []vexPackage `json:"packages"`          // the module's packages in the binary
+}
+
+// vexPackage is a package of a module that's linked into the product.
+type vexPackage struct {
+	Package string   `json:"package"`
+	GOOS    []string `json:"goos"` // platforms on which it's linked
+}
+
+// newVEXReport returns the VEX report for pkg, whose dependencies on
+// geese are d. Packages of the main module aren't components.
+func newVEXReport(pkg string, d *deps, geese []string) *vexReport {
+	r := &vexReport{Product: pkg, GOOS: geese, Components: []vexComponent{}}
+	byMod := make(map[string]int) // module path -> index in r.Components
+	for _, dep := range d.Deps {
+		m, ok := d.Module[dep]
+		if ok && m.Path == d.MainModule {
+			continue
+		}
+		if !ok {
+			m.Path = stdModule
+		}
+		i, ok := byMod[m.Path]
+		if !ok {
+			i = len(r.Components)
+			byMod[m.Path] = i
+			purl := "pkg:golang/" + m.Path
+			if m.Version != "" {
+				purl += "@" + m.Version
+			}
+			r.Components = append(r.Components, vexComponent{Module: m.Path, Version: m.Version, PURL: purl})
+		}
+		vp := vexPackage{Package: dep}
+		for _, goos := range geese {
+			if d.DepOnOS[pkgGOOS{dep, goos}] {
+				vp.GOOS = append(vp.GOOS, goos)
+			}
+		}
+		r.Components[i].Packages = append(r.Components[i].Packages, vp)
+	}
+	sort.SliceStable(r.Components, func(i, j int) bool {
+		if si, sj := r.Components[i].Module == stdModule, r.Components[j].Module == stdModule; si != sj {
+			return sj
+		}
+		return r.Components[i].Module < r.Components[j].Module
+	})
+	return r
+}
+
+func writeVEXReport(w io.Writer, r *vexReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(r)
+}
@@ -0,0 +1,49 @@
+package depaware
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+func TestNewVEXReport(t *testing.T) {
+	d := testModuleDeps()
+	d.MainModule = "example.com/cmd"
+	d.Module["example.com/cmd/util"] = module.Version{Path: "example.com/cmd"}
+	d.Deps = append(d.Deps, "example.com/cmd/util")
+	geese := []string{"linux", "windows"}
+	got := newVEXReport("example.com/cmd", d, geese)
+	want := &vexReport{
+		Product: "example.com/cmd",
+		GOOS:    geese,
+		Components: []vexComponent{
+			{
+				Module:  "github.com/foo/bar",
+				Version: "v1.2.3",
+				PURL:    "pkg:golang/github.com/foo/bar@v1.2.3",
+				Packages: []vexPackage{
+					{"github.com/foo/bar", []string{"linux", "windows"}},
+					{"github.com/foo/bar/sub", []string{"linux"}},
+				},
+			},
+			{
+				Module:   "golang.org/x/sys",
+				Version:  "v0.1.0",
+				PURL:     "pkg:golang/golang.org/x/sys@v0.1.0",
+				Packages: []vexPackage{{"golang.org/x/sys/unix", []string{"linux"}}},
+			},
+			{
+				Module: "std",
+				PURL:   "pkg:golang/std",
+				Packages: []vexPackage{
+					{"bytes", []string{"linux", "windows"}},
+					{"os", []string{"linux", "windows"}},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import "strings"
+
+// visibility decides which dependencies are hidden from the output.
+// By default, internal packages are, as reported by isInternalPackage,
+// unless Internal (-internal) is set.
+type visibility struct {
+	Internal bool       // whether to show internal packages
+	Hide     patternSet // packages to hide as well
+	Show     patternSet // packages to show even if hidden otherwise
+}
+
+// visibility returns the visibility for r's -internal, -hide and -show
+// flags, falling back to directives as newVisibility does.
+func (r *runner) visibility(directives map[string]string) visibility {
+	v := newVisibility(r.Hide, r.Show, directives)
+	v.Internal = r.Internal || directives["internal"] == "show"
+	return v
+}
+
+// newVisibility returns the visibility for the -hide and -show flag
+// values hide and show, falling back to the "hide" and "show"
+// directives of the existing depaware.txt file for empty values.
+func newVisibility(hide, show string, directives map[string]string) visibility {
+	if hide == "" {
+		hide = directives["hide"]
+	}
+	if show == "" {
+		show = directives["show"]
+	}
+	return visibility{
+		Hide: newPatternSet(splitPatterns(hide)),
+		Show: newPatternSet(splitPatterns(show)),
+	}
+}
+
+// splitPatterns splits a comma-separated list of package patterns.
+func splitPatterns(s string) []string {
+	var patterns []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// Hidden reports whether pkg is hidden from the output. Show patterns
+// take precedence over Hide patterns.
+func (v visibility) Hidden(pkg string) bool {
+	if v.Show.Match(pkg) {
+		return false
+	}
+	if v.Hide.Match(pkg) {
+		return true
+	}
+	return !v.Internal && isInternalPackage(pkg)
+}
+
+// addDirectives records v in the depaware.txt directives m, so that a
+// later -check hides the same packages, and returns m.
+func (v visibility) addDirectives(m map[string]string) map[string]string {
+	if !v.Internal && len(v.Hide.patterns) == 0 && len(v.Show.patterns) == 0 {
+		return m
+	}
+	if m == nil {
+		m = make(map[string]string)
+	}
+	if v.Internal {
+		m["internal"] = "show"
+	}
+	if len(v.Hide.patterns) > 0 {
+		m["hide"] = v.Hide.String()
+	}
+	if len(v.Show.patterns) > 0 {
+		m["show"] = v.Show.String()
+	}
+	return m
+}
+
+// hideDeps removes the dependencies hidden by v from d.Deps. They stay
+// in the import graph, so why chains still go through them.
+func (d *deps) hideDeps(v visibility) {
+	hidden := make(map[string]bool)
+	kept := d.Deps[:0]
+	for _, pkg := range d.Deps {
+		if v.Hidden(pkg) {
+			hidden[pkg] = true
+		} else {
+			kept = append(kept, pkg)
+		}
+	}
+	d.Deps = kept
+	for k := range d.DepOnOS {
+		if hidden[k.pkg] {
+			delete(d.DepOnOS, k)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package depaware
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVisibilityHidden(t *testing.T) {
+	v := newVisibility("example.com/wrappers/...", "", map[string]string{"show": "runtime/cgo"})
+	tests := []struct {
+		pkg  string
+		want bool
+	}{
+		{"runtime", true},
+		{"runtime/cgo", false},
+		{"internal/bytealg", true},
+		{"golang.org/x/net/internal/socks", true},
+		{"example.com/wrappers", true},
+		{"example.com/wrappers/log", true},
+		{"example.com/wrappersx", false},
+		{"github.com/foo/internal/bar", false},
+		{"fmt", false},
+	}
+	for _, tt := range tests {
+		if got := v.Hidden(tt.pkg); got != tt.want {
+			t.Errorf("Hidden(%q) = %v; want %v", tt.pkg, got, tt.want)
+		}
+	}
+}
+
+func TestVisibilityDirectives(t *testing.T) {
+	if got := (visibility{}).addDirectives(nil); got != nil {
+		t.Errorf("default visibility added directives %v", got)
+	}
+	v := newVisibility(" a/..., b ", "c", nil)
+	v.Internal = true
+	got := v.addDirectives(map[string]string{"granularity": "module"})
+	want := map[string]string{"granularity": "module", "hide": "a/...,b", "internal": "show", "show": "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+	r := &runner{Options: *NewOptions()}
+	back := r.visibility(got)
+	if !back.Internal || back.Hide.String() != v.Hide.String() || back.Show.String() != v.Show.String() {
+		t.Errorf("round trip: got %+v; want %+v", back, v)
+	}
+	if !back.Hidden("a/x") || back.Hidden("internal/bytealg") {
+		t.Errorf("round trip doesn't hide the same packages")
+	}
+}
+
+func TestHideDeps(t *testing.T) {
+	d := &deps{
+		Deps: []string{"example.com/wrappers/log", "fmt", "runtime"},
+		DepOnOS: map[pkgGOOS]bool{
+			{"example.com/wrappers/log", "linux"}: true,
+			{"fmt", "linux"}:                      true,
+			{"runtime", "linux"}:                  true,
+		},
+	}
+	d.hideDeps(newVisibility("example.com/wrappers/...", "", nil))
+	if want := []string{"fmt"}; !reflect.DeepEqual(d.Deps, want) {
+		t.Errorf("Deps = %q; want %q", d.Deps, want)
+	}
+	if want := map[pkgGOOS]bool{{"fmt", "linux"}: true}; !reflect.DeepEqual(d.DepOnOS, want) {
+		t.Errorf("DepOnOS = %v; want %v", d.DepOnOS, want)
+	}
+}
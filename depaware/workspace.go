@@ -0,0 +1,200 @@
+// Copyright (c) 2020 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package depaware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/mod/semver"
+)
+
+// sharedModule is a module required by several modules of a go.work
+// workspace.
+type sharedModule struct {
+	Path string `json:"path"`
+
+	// Versions maps each workspace module that requires Path to the
+	// version it requires.
+	Versions map[string]string `json:"versions"`
+
+	// Selected is the version the workspace builds with, the highest
+	// of Versions. A workspace module released on its own builds with
+	// the version it requires instead.
+	Selected string `json:"selected"`
+}
+
+// Skewed reports whether the workspace modules require different
+// versions of m.
+func (m sharedModule) Skewed() bool {
+	for _, v := range m.Versions {
+		if v != m.Selected {
+			return true
+		}
+	}
+	return false
+}
+
+// runWorkspace implements "depaware workspace", which reports the
+// modules required by more than one module of the current go.work
+// workspace, flagging those they require at different versions: the
+// workspace builds all its modules with the highest version, but each
+// module released on its own builds with the version its go.mod
+// requires, which may not be what it was tested with. With -check, the
+// command fails if there's any such skew.
+//
+// Usage:
+//
+//	depaware [-check] workspace [-json]
+func (r *runner) runWorkspace(args []string) error {
+	fs := flag.NewFlagSet("workspace", flag.ContinueOnError)
+	fs.SetOutput(r.stderr)
+	asJSON := fs.Bool("json", false, "print all shared modules as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("usage: depaware [-check] workspace [-json]")
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	work := goWorkFile(wd)
+	if work == "" {
+		return errors.New("not in a go.work workspace")
+	}
+	mods, err := readWorkspace(work)
+	if err != nil {
+		return err
+	}
+	shared := sharedModules(mods)
+	if *asJSON {
+		enc := json.NewEncoder(r.stdout)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(shared); err != nil {
+			return err
+		}
+	} else {
+		writeSharedModules(r.stdout, shared, len(mods))
+	}
+	if r.Check {
+		for _, m := range shared {
+			if m.Skewed() {
+				return errors.New("workspace modules require different versions of shared modules")
+			}
+		}
+	}
+	return nil
+}
+
+// readWorkspace returns the go.mod files of the modules the named
+// go.work file uses.
+func readWorkspace(work string) ([]*goMod, error) {
+	dirs, err := workspaceDirs(work)
+	if err != nil {
+		return nil, err
+	}
+	var mods []*goMod
+	for _, dir := range dirs {
+		f, err := readGoMod(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			return nil, err
+		}
+		mods = append(mods, f)
+	}
+	return mods, nil
+}
+
+// workspaceDirs returns the directories of the modules the named
+// go.work file uses.
+func workspaceDirs(work string) ([]string, error) {
+	out, err := exec.Command("go", "work", "edit", "-json", work).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("go work edit -json %s: %s", work, bytes.TrimSpace(ee.Stderr))
+		}
+		return nil, err
+	}
+	var w struct {
+		Use []struct{ DiskPath string }
+	}
+	if err := json.Unmarshal(out, &w); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", work, err)
+	}
+	var dirs []string
+	for _, u := range w.Use {
+		dir := filepath.FromSlash(u.DiskPath)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(work), dir)
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// sharedModules returns the modules that more than one of mods
+// requires, sorted by path.
+func sharedModules(mods []*goMod) []sharedModule {
+	byPath := make(map[string]*sharedModule)
+	for _, f := range mods {
+		for _, req := range f.Require {
+			m, ok := byPath[req.Path]
+			if !ok {
+				m = &sharedModule{Path: req.Path, Versions: make(map[string]string)}
+				byPath[req.Path] = m
+			}
+			m.Versions[f.Module.Path] = req.Version
+			m.Selected = semver.Max(m.Selected, req.Version)
+		}
+	}
+	var shared []sharedModule
+	for _, m := range byPath {
+		if len(m.Versions) > 1 {
+			shared = append(shared, *m)
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool { return shared[i].Path < shared[j].Path })
+	return shared
+}
+
+// writeSharedModules writes the skewed modules of shared, the modules
+// shared by the n modules of a workspace, to w, followed by a summary.
+func writeSharedModules(w io.Writer, shared []sharedModule, n int) {
+	skewed := 0
+	for _, m := range shared {
+		if !m.Skewed() {
+			continue
+		}
+		skewed++
+		fmt.Fprintf(w, "%s: workspace builds with %s\n", m.Path, m.Selected)
+		requirers := make([]string, 0, len(m.Versions))
+		for mod := range m.Versions {
+			requirers = append(requirers, mod)
+		}
+		sort.Slice(requirers, func(i, j int) bool {
+			vi, vj := m.Versions[requirers[i]], m.Versions[requirers[j]]
+			if c := semver.Compare(vi, vj); c != 0 {
+				return c < 0
+			}
+			return requirers[i] < requirers[j]
+		})
+		for _, mod := range requirers {
+			fmt.Fprintf(w, "\t%-10s required by %s\n", m.Versions[mod], mod)
+		}
+	}
+	if skewed > 0 {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d modules shared by the %d workspace modules, %d at different versions\n", len(shared), n, skewed)
+}
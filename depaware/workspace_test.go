@@ -0,0 +1,55 @@
+package depaware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSharedModules(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.work":  "go 1.21\n\nuse (\n\t./a\n\t./b\n\t./c\n)\n",
+		"a/go.mod": "module example.com/a\n\ngo 1.21\n\nrequire (\n\texample.com/x v1.2.0\n\texample.com/y v0.1.0\n)\n",
+		"b/go.mod": "module example.com/b\n\ngo 1.21\n\nrequire (\n\texample.com/x v1.10.0\n\texample.com/y v0.1.0\n)\n",
+		"c/go.mod": "module example.com/c\n\ngo 1.21\n\nrequire example.com/z v1.0.0\n",
+	}
+	for name, contents := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mods, err := readWorkspace(filepath.Join(dir, "go.work"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := sharedModules(mods)
+	want := []sharedModule{
+		{Path: "example.com/x", Versions: map[string]string{"example.com/a": "v1.2.0", "example.com/b": "v1.10.0"}, Selected: "v1.10.0"},
+		{Path: "example.com/y", Versions: map[string]string{"example.com/a": "v0.1.0", "example.com/b": "v0.1.0"}, Selected: "v0.1.0"},
+	}
+	if !reflect.DeepEqual(shared, want) {
+		t.Fatalf("got %+v; want %+v", shared, want)
+	}
+	if !shared[0].Skewed() || shared[1].Skewed() {
+		t.Errorf("Skewed = %v, %v; want true, false", shared[0].Skewed(), shared[1].Skewed())
+	}
+
+	var buf bytes.Buffer
+	writeSharedModules(&buf, shared, len(mods))
+	const wantOut = "example.com/x: workspace builds with v1.10.0\n" +
+		"\tv1.2.0     required by example.com/a\n" +
+		"\tv1.10.0    required by example.com/b\n" +
+		"\n" +
+		"2 modules shared by the 3 workspace modules, 1 at different versions\n"
+	if buf.String() != wantOut {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), wantOut)
+	}
+}
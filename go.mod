@@ -1,9 +1,11 @@
 module github.com/tailscale/depaware
 
-go 1.15
+go 1.21
 
 require (
 	github.com/pkg/diff v0.0.0-20200914180035-5b29258ca4f7
-	golang.org/x/mod v0.4.0 // indirect
+	golang.org/x/mod v0.4.0
 	golang.org/x/tools v0.0.0-20201211185031-d93e913c1a58
 )
+
+require golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect